@@ -0,0 +1,228 @@
+// cmd/server/app.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"voidcase/internal/config"
+	dbpkg "voidcase/internal/db"
+	"voidcase/internal/handlers"
+	"voidcase/internal/imaging"
+	"voidcase/internal/middleware"
+	"voidcase/internal/models"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+// app is the server's router together with the background workers that
+// write to its database
+type app struct {
+	db        *sql.DB
+	router    *mux.Router
+	auth      *handlers.AuthHandler
+	projects  *handlers.ProjectHandler
+	analytics *middleware.AnalyticsMiddleware
+}
+
+// newApp sets up the routes on a migrated db and starts the background
+// workers. Call close to stop them and close db.
+func newApp(cfg *config.Config, db *sql.DB) (*app, error) {
+	// Router setup
+	r := mux.NewRouter() // Move this up before using it
+	r.StrictSlash(true)  // enforce trailing slashes
+
+	// Update static file server to use new structure
+	fs := http.FileServer(http.Dir(cfg.StaticDir))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
+	uploadsFs := http.FileServer(http.Dir(cfg.UploadsDir()))
+	r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", uploadsFs))
+
+	store := dbpkg.New(db)
+
+	renditions := imaging.DefaultRenditions
+	if len(cfg.Renditions) > 0 {
+		renditions = make([]imaging.Rendition, len(cfg.Renditions))
+		for i, r := range cfg.Renditions {
+			renditions[i] = imaging.Rendition(r)
+		}
+	}
+	images, err := imaging.New(cfg.UploadsDir(), renditions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize middleware
+	authMiddleware := middleware.NewAuthMiddleware(store, cfg)
+	analyticsMiddleware := middleware.NewAnalyticsMiddleware(store, middleware.DefaultAnalyticsOptions)
+
+	// Initialize handlers
+	projectHandler := handlers.NewProjectHandler(store, images, cfg)
+	authHandler := handlers.NewAuthHandler(store, cfg)
+	adminHandler := handlers.NewAdminHandler(store, cfg)
+	analyticsHandler := handlers.NewAnalyticsHandler(store, cfg)
+	tagHandler := handlers.NewTagHandler(store, cfg)
+	categoryHandler := handlers.NewCategoryHandler(store, cfg)
+	configHandler := handlers.NewConfigHandler(store, cfg)
+	pageHandler := handlers.NewPageHandler(store, cfg)
+	mediaHandler := handlers.NewMediaHandler(store, images, cfg)
+	userHandler := handlers.NewUserHandler(store, cfg)
+	auditHandler := handlers.NewAuditHandler(store, cfg)
+
+	// Set up middleware. Client addresses are resolved first, and bodies are
+	// limited before csrf.Protect reads them. Only the forms that upload
+	// images may send multipart bodies.
+	r.Use(middleware.RealIP(cfg.TrustedProxyNets()))
+	r.Use(middleware.LimitBody(cfg.MaxUploadBytes,
+		"/admin/project/new", "/admin/project/{id}/edit", "/admin/media/upload"))
+	r.Use(csrf.Protect(cfg.CSRFKeyBytes(), csrf.Secure(cfg.Secure()), csrf.Path("/")))
+	r.Use(analyticsMiddleware.TrackPageView)
+
+	// Public routes
+	r.HandleFunc("/", projectHandler.HomeHandler)
+	r.HandleFunc("/work", projectHandler.WorkHandler)
+	r.HandleFunc("/work/{slug}", projectHandler.ProjectDetailHandler)
+	r.HandleFunc("/project/{id:[0-9]+}", projectHandler.ProjectRedirectHandler)
+	r.HandleFunc("/login", authHandler.LoginHandler)
+	r.HandleFunc("/login/verify", authHandler.VerifyHandler)
+	r.HandleFunc("/logout", authHandler.LogoutHandler)
+	r.HandleFunc("/tag/{tag}", tagHandler.TagHandler)
+	r.HandleFunc("/search", projectHandler.SearchHandler)
+	r.HandleFunc("/about", pageHandler.AboutHandler)
+	r.HandleFunc("/invite/{token}", userHandler.InviteHandler)
+	r.HandleFunc("/reset/{token}", userHandler.ResetHandler)
+
+	// Admin routes. Every signed-in user can view the admin; editing
+	// content needs an editor and managing users or settings an owner.
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(authMiddleware.RequireAuth)
+	editor := requireRole(authMiddleware, models.RoleEditor)
+	owner := requireRole(authMiddleware, models.RoleOwner)
+	admin.HandleFunc("/", adminHandler.DashboardHandler)
+	admin.HandleFunc("/projects", projectHandler.AdminProjectsHandler)
+	admin.Handle("/projects/reorder", editor(projectHandler.AdminReorderProjectsHandler))
+	admin.Handle("/project/new", editor(projectHandler.AdminNewProjectHandler))
+	admin.Handle("/project/{id}/edit", editor(projectHandler.AdminEditProjectHandler))
+	admin.Handle("/project/{id}/delete", editor(projectHandler.AdminDeleteProjectHandler))
+	admin.Handle("/project/{id}/revisions/{revisionID:[0-9]+}/restore", editor(projectHandler.AdminRestoreRevisionHandler))
+	admin.HandleFunc("/trash", projectHandler.AdminTrashHandler)
+	admin.Handle("/trash/{id:[0-9]+}/restore", editor(projectHandler.AdminRestoreProjectHandler))
+	admin.Handle("/trash/{id:[0-9]+}/purge", editor(projectHandler.AdminPurgeProjectHandler))
+	admin.Handle("/project/{id}/images/reorder", editor(projectHandler.AdminReorderImagesHandler))
+	admin.Handle("/project/{id}/images/{imageID:[0-9]+}", editor(projectHandler.AdminUpdateImageHandler))
+	admin.Handle("/project/{id}/images/{imageID:[0-9]+}/cover", editor(projectHandler.AdminSetCoverImageHandler))
+	admin.Handle("/project/{id}/images/{imageID:[0-9]+}/delete", editor(projectHandler.AdminDeleteImageHandler))
+	admin.HandleFunc("/media", mediaHandler.AdminMediaHandler)
+	admin.Handle("/media/upload", editor(mediaHandler.AdminUploadMediaHandler))
+	admin.Handle("/media/attach", editor(mediaHandler.AdminAttachMediaHandler))
+	admin.Handle("/media/{id:[0-9]+}/delete", editor(mediaHandler.AdminDeleteMediaHandler))
+	admin.HandleFunc("/tags", tagHandler.AdminTagsHandler)
+	admin.Handle("/tags/{id:[0-9]+}", editor(tagHandler.AdminEditTagHandler))
+	admin.Handle("/tags/{id:[0-9]+}/merge", editor(tagHandler.AdminMergeTagHandler))
+	admin.Handle("/tags/{id:[0-9]+}/delete", editor(tagHandler.AdminDeleteTagHandler))
+	admin.HandleFunc("/categories", categoryHandler.AdminCategoriesHandler)
+	admin.Handle("/categories/new", editor(categoryHandler.AdminCreateCategoryHandler))
+	admin.Handle("/categories/reorder", editor(categoryHandler.AdminReorderCategoriesHandler))
+	admin.Handle("/categories/{id:[0-9]+}", editor(categoryHandler.AdminUpdateCategoryHandler))
+	admin.Handle("/categories/{id:[0-9]+}/delete", editor(categoryHandler.AdminDeleteCategoryHandler))
+	admin.HandleFunc("/analytics", analyticsHandler.AdminAnalyticsHandler)
+	admin.HandleFunc("/account", userHandler.AccountHandler)
+	admin.HandleFunc("/account/sessions", userHandler.AccountSessionsHandler)
+	admin.HandleFunc("/account/sessions/{id:[0-9]+}/revoke", userHandler.RevokeSessionHandler)
+	admin.HandleFunc("/account/sessions/revoke-all", userHandler.RevokeAllSessionsHandler)
+	admin.HandleFunc("/account/totp/setup", userHandler.TOTPSetupHandler)
+	admin.HandleFunc("/account/totp/enable", userHandler.TOTPEnableHandler)
+	admin.HandleFunc("/account/totp/disable", userHandler.TOTPDisableHandler)
+	admin.HandleFunc("/account/totp/recovery", userHandler.TOTPRecoveryHandler)
+	admin.Handle("/settings", owner(configHandler.AdminConfigHandler))
+	admin.Handle("/users", owner(userHandler.AdminUsersHandler))
+	admin.Handle("/users/invite", owner(userHandler.AdminCreateInviteHandler))
+	admin.Handle("/users/{id:[0-9]+}/role", owner(userHandler.AdminSetUserRoleHandler))
+	admin.Handle("/users/{id:[0-9]+}/disable", owner(userHandler.AdminSetUserDisabledHandler))
+	admin.Handle("/users/{id:[0-9]+}/reset", owner(userHandler.AdminCreateResetHandler))
+	admin.Handle("/users/{id:[0-9]+}/delete", owner(userHandler.AdminDeleteUserHandler))
+	admin.Handle("/invites/{id:[0-9]+}/delete", owner(userHandler.AdminDeleteInviteHandler))
+	admin.Handle("/audit", owner(auditHandler.AdminAuditHandler))
+	admin.Handle("/audit.csv", owner(auditHandler.AdminAuditCSVHandler))
+
+	return &app{
+		db:        db,
+		router:    r,
+		auth:      authHandler,
+		projects:  projectHandler,
+		analytics: analyticsMiddleware,
+	}, nil
+}
+
+// run serves on ln until ctx is cancelled, waits for in-flight requests and
+// then closes the app
+func (a *app) run(ctx context.Context, ln net.Listener, cfg *config.Config) error {
+	srv := &http.Server{
+		Handler:           a.router,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Uploads up to max_upload_bytes must fit in the read timeout
+		ReadTimeout:  2 * time.Minute,
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	err := serve(ctx, srv, ln, cfg.TLSCert, cfg.TLSKey, cfg.ShutdownTimeout.Duration)
+	if closeErr := a.close(); closeErr != nil {
+		log.Printf("Failed to close database: %v", closeErr)
+	}
+	return err
+}
+
+// close stops the background workers, writing out queued page views, and
+// then closes the database they write to
+func (a *app) close() error {
+	a.auth.Shutdown()
+	a.projects.Shutdown()
+	a.analytics.Close()
+	return a.db.Close()
+}
+
+// requireRole adapts AuthMiddleware.RequireRole for wrapping individual
+// handler functions
+func requireRole(am *middleware.AuthMiddleware, min models.Role) func(http.HandlerFunc) http.Handler {
+	wrap := am.RequireRole(min)
+	return func(h http.HandlerFunc) http.Handler {
+		return wrap(h)
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then stops accepting connections
+// and waits up to timeout for in-flight requests to finish. It serves HTTPS
+// when certFile is set.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, certFile, keyFile string, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			errc <- srv.ServeTLS(ln, certFile, keyFile)
+			return
+		}
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for requests to finish", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// cmd/server/app_test.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/config"
+	dbpkg "voidcase/internal/db"
+)
+
+// browserAgent is a user agent that analytics counts as a visitor
+const browserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+// newTestApp returns an app on a fresh database in a temporary directory
+func newTestApp(t *testing.T) (*app, *config.Config) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.DBPath = filepath.Join(dir, "db", "test.db")
+	cfg.TemplateDir = filepath.Join(dir, "templates")
+	cfg.StaticDir = filepath.Join(dir, "static")
+	cfg.CSRFKey = strings.Repeat("ab", 32)
+	cfg.SessionKey = strings.Repeat("cd", 32)
+	cfg.ShutdownTimeout = config.Duration{Duration: 5 * time.Second}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initializeFileSystem(cfg); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabase(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dbpkg.HasFTS5(db) {
+		db.Close()
+		t.Fatal("SQLite was built without FTS5; run the tests with -tags sqlite_fts5 or make test")
+	}
+	if err := initializeDatabase(db, "correct horse"); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	a, err := newApp(cfg, db)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	return a, cfg
+}
+
+// workerFrames name the constructors that start the app's background
+// goroutines, as they appear in goroutine dumps
+var workerFrames = []string{
+	"created by voidcase/internal/handlers.NewAuthHandler",
+	"created by voidcase/internal/handlers.NewProjectHandler",
+	"created by voidcase/internal/middleware.NewAnalyticsMiddleware",
+}
+
+// runningWorkers returns the background goroutines that are still running
+func runningWorkers() []string {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	var running []string
+	for _, frame := range workerFrames {
+		if strings.Contains(stacks, frame) {
+			running = append(running, frame)
+		}
+	}
+	return running
+}
+
+func TestRunShutsDownGracefully(t *testing.T) {
+	a, cfg := newTestApp(t)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	// /slow holds its request open until released, and is counted as a page
+	// view once it finishes
+	started := make(chan struct{})
+	release := make(chan struct{})
+	a.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(w, "finished")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.run(ctx, ln, cfg) }()
+
+	if running := runningWorkers(); len(running) != len(workerFrames) {
+		t.Fatalf("running workers = %v; want all of %v", running, workerFrames)
+	}
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan response, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", base+"/slow", nil)
+		req.Header.Set("User-Agent", browserAgent)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			slow <- response{err: err}
+			return
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		slow <- response{res.StatusCode, string(body), err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow request never reached its handler")
+	}
+	cancel()
+
+	// The server waits for the slow request, but refuses new connections
+	select {
+	case err := <-runErr:
+		t.Fatalf("run returned with a request in flight: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	fresh := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if res, err := fresh.Get(base + "/"); err == nil {
+		res.Body.Close()
+		t.Error("server accepted a new request while shutting down")
+	}
+
+	close(release)
+	res := <-slow
+	if res.err != nil || res.status != http.StatusOK || res.body != "finished" {
+		t.Fatalf("slow request = %d %q, %v; want it to finish", res.status, res.body, res.err)
+	}
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not return after the last request finished")
+	}
+
+	if running := runningWorkers(); len(running) > 0 {
+		t.Errorf("workers still running after shutdown: %v", running)
+	}
+	if err := a.db.Ping(); err == nil {
+		t.Error("database was left open")
+	}
+	if strings.Contains(logs.String(), "database is closed") {
+		t.Errorf("a worker used the database after it was closed:\n%s", logs.String())
+	}
+
+	// The view of /slow was queued after shutdown began, so it is only
+	// stored if the analytics queue was flushed before the database closed
+	db, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var views int
+	if err := db.QueryRow("SELECT COUNT(*) FROM page_views WHERE page_path = '/slow'").Scan(&views); err != nil {
+		t.Fatal(err)
+	}
+	if views != 1 {
+		t.Errorf("stored %d views of /slow; want 1", views)
+	}
+}
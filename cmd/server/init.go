@@ -7,21 +7,26 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"voidcase/internal/config"
 )
 
 //go:embed static/* templates/*
 var content embed.FS
 
-var dirs = []string{
-	"data/db",
-	"data/uploads/images",
-	"data/uploads/thumbnails",
-	"templates/admin", // Non-themed admin templates
-	"templates/themes/default",
-	"templates/themes/minimal",
-}
+// initializeFileSystem creates the data directories and writes the embedded
+// templates and static files into the configured directories
+func initializeFileSystem(cfg *config.Config) error {
+	dirs := []string{
+		filepath.Dir(cfg.DBPath),
+		filepath.Join(cfg.UploadsDir(), "images"),
+		filepath.Join(cfg.UploadsDir(), "thumbnails"),
+		filepath.Join(cfg.TemplateDir, "admin"), // Non-themed admin templates
+		filepath.Join(cfg.TemplateDir, "themes", "default"),
+		filepath.Join(cfg.TemplateDir, "themes", "minimal"),
+		cfg.StaticDir,
+	}
 
-func initializeFileSystem() error {
 	// Create directories first
 	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
@@ -39,15 +44,17 @@ func initializeFileSystem() error {
 			return nil
 		}
 
-		destPath := path
-		if strings.HasPrefix(path, "templates/") {
-			if strings.Contains(path, "admin/") {
-				// Admin templates go to templates/admin
-				destPath = filepath.Join("templates", strings.TrimPrefix(path, "templates/"))
-			} else if !strings.Contains(path, "themes/") {
+		var destPath string
+		if rel, ok := strings.CutPrefix(path, "templates"); ok {
+			rel = strings.TrimPrefix(rel, "/")
+			if rel == "" || strings.Contains(path, "admin/") || strings.Contains(path, "themes/") {
+				destPath = filepath.Join(cfg.TemplateDir, rel)
+			} else {
 				// Non-admin templates go to default theme
-				destPath = filepath.Join("templates/themes/default", strings.TrimPrefix(path, "templates/"))
+				destPath = filepath.Join(cfg.TemplateDir, "themes", "default", rel)
 			}
+		} else {
+			destPath = filepath.Join(cfg.StaticDir, strings.TrimPrefix(strings.TrimPrefix(path, "static"), "/"))
 		}
 
 		if d.IsDir() {
@@ -2,27 +2,49 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	_ "embed"
+	"encoding/base64"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
-	"net/http"
-
-	"voidcase/internal/handlers"
-	"voidcase/internal/middleware"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"voidcase/internal/config"
+	dbpkg "voidcase/internal/db"
+	"voidcase/internal/migrate"
 	"voidcase/internal/models"
 
-	"github.com/gorilla/csrf"
-	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// applyMigrations brings the schema up to date. The search index needs
+// FTS5, so a driver built without it is refused before any migration runs.
+func applyMigrations(db *sql.DB) ([]migrate.Migration, error) {
+	if !dbpkg.HasFTS5(db) {
+		return nil, errors.New("SQLite was built without FTS5, which search needs; build with -tags sqlite_fts5, as make build does")
+	}
+	return migrate.Apply(db)
+}
+
 func initializeDatabase(db *sql.DB, adminPassword string) error {
-	// Use the embedded schema from models
-	if _, err := db.Exec(models.SchemaSQL); err != nil {
+	applied, err := applyMigrations(db)
+	if err != nil {
 		return err
 	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
 
 	// Create admin user if none exists
 	var count int
@@ -31,15 +53,23 @@ func initializeDatabase(db *sql.DB, adminPassword string) error {
 	}
 
 	if count == 0 {
+		if adminPassword == "" {
+			adminPassword, err = generatePassword()
+			if err != nil {
+				return err
+			}
+			log.Printf("Created user admin with password %s; change it after signing in", adminPassword)
+		}
+
 		hash, err := bcrypt.GenerateFromPassword([]byte(adminPassword), 12)
 		if err != nil {
 			return err
 		}
 
 		if _, err := db.Exec(`
-            INSERT INTO users (username, password_hash, created_at)
-            VALUES (?, ?, datetime('now'))
-        `, "admin", string(hash)); err != nil {
+            INSERT INTO users (username, password_hash, role, created_at)
+            VALUES (?, ?, ?, datetime('now'))
+        `, "admin", string(hash), models.RoleOwner); err != nil {
 			return err
 		}
 
@@ -66,72 +96,111 @@ func initializeDatabase(db *sql.DB, adminPassword string) error {
 	return nil
 }
 
+// generatePassword returns a random password for the first admin account
+func generatePassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// printMigrationStatus writes every known migration to w with whether it
+// has been applied to db
+func printMigrationStatus(w io.Writer, db *sql.DB) error {
+	statuses, err := migrate.GetStatus(db)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d  %-32s %s\n", s.Version, s.Name, state)
+	}
+	return nil
+}
+
+// openDatabase opens the SQLite database named by cfg, creating its
+// directory if needed
+func openDatabase(cfg *config.Config) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", cfg.DBPath+"?_timeout=5000&_busy_timeout=5000&_journal_mode=WAL")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		if err := runUserCommand(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Command line flags
-	dbPath := flag.String("db", "./data/db/filmcms.db", "Path to SQLite database")
-	adminPass := flag.String("adminpass", "admin", "Initial admin password")
-	port := flag.String("port", "8080", "Server port")
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "Path to TOML config file (default "+config.DefaultPath+" if present)")
+	migrateOnly := flag.Bool("migrate-only", false, "Apply pending migrations and exit")
+	migrateStatus := flag.Bool("migrate-status", false, "Print migration status and exit")
 	flag.Parse()
 
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize filesystem
-	if err := initializeFileSystem(); err != nil {
+	if err := initializeFileSystem(cfg); err != nil {
 		log.Fatal("Failed to initialize filesystem:", err)
 	}
 
 	// Initialize database
-	db, err := sql.Open("sqlite3", *dbPath+"?_timeout=5000&_busy_timeout=5000&_journal_mode=WAL")
+	db, err := openDatabase(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
-	if err := initializeDatabase(db, *adminPass); err != nil {
+	if *migrateStatus {
+		err := printMigrationStatus(os.Stdout, db)
+		db.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := initializeDatabase(db, cfg.AdminPassword); err != nil {
+		db.Close()
 		log.Fatal(err)
 	}
 
-	// Router setup
-	r := mux.NewRouter() // Move this up before using it
-	r.StrictSlash(true)  // enforce trailing slashes
-
-	// Update static file server to use new structure
-	fs := http.FileServer(http.Dir("static"))
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
-	uploadsFs := http.FileServer(http.Dir("data/uploads"))
-	r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", uploadsFs))
-
-	// Initialize handlers
-	projectHandler := handlers.NewProjectHandler(db)
-	authHandler := handlers.NewAuthHandler(db)
-	adminHandler := handlers.NewAdminHandler(db)
-	tagHandler := handlers.NewTagHandler(db)
-	configHandler := handlers.NewConfigHandler(db)
-	pageHandler := handlers.NewPageHandler(db)
-
-	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(db)
-	analyticsMiddleware := middleware.NewAnalyticsMiddleware(db)
-
-	// Set up middleware
-	r.Use(csrf.Protect([]byte("32-byte-long-auth-key")))
-	r.Use(analyticsMiddleware.TrackPageView)
-
-	// Public routes
-	r.HandleFunc("/", projectHandler.HomeHandler)
-	r.HandleFunc("/login", authHandler.LoginHandler)
-	r.HandleFunc("/logout", authHandler.LogoutHandler)
-	r.HandleFunc("/tag/{tag}", tagHandler.TagHandler)
-	r.HandleFunc("/about", pageHandler.AboutHandler)
-
-	// Admin routes
-	admin := r.PathPrefix("/admin").Subrouter()
-	admin.Use(authMiddleware.RequireAuth)
-	admin.HandleFunc("/", adminHandler.DashboardHandler)
-	admin.HandleFunc("/projects", projectHandler.AdminProjectsHandler)
-	admin.HandleFunc("/project/new", projectHandler.AdminNewProjectHandler)
-	admin.HandleFunc("/project/{id}/edit", projectHandler.AdminEditProjectHandler)
-	admin.HandleFunc("/project/{id}/delete", projectHandler.AdminDeleteProjectHandler)
-	admin.HandleFunc("/settings", configHandler.AdminConfigHandler)
-
-	log.Printf("Server starting on http://localhost:%s", *port)
-	log.Fatal(http.ListenAndServe(":"+*port, r))
+	if *migrateOnly {
+		db.Close()
+		log.Printf("Migrations complete")
+		return
+	}
+
+	a, err := newApp(cfg, db)
+	if err != nil {
+		db.Close()
+		log.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		a.close()
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Server starting on %s (https: %t)", cfg.ListenAddr, cfg.TLS())
+	if err := a.run(ctx, ln, cfg); err != nil {
+		log.Printf("Server error: %v", err)
+	}
+	log.Printf("Server stopped")
 }
@@ -0,0 +1,46 @@
+// cmd/server/main_test.go
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintMigrationStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var out bytes.Buffer
+	if err := printMigrationStatus(&out, db); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "0001  initial_schema ") || !strings.HasPrefix(lines[1], "0002  project_slugs ") {
+		t.Fatalf("status starts %q; want 0001 initial_schema then 0002 project_slugs", lines[:2])
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, " pending") {
+			t.Errorf("fresh database line %q; want pending", line)
+		}
+	}
+
+	appliedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (1, 'initial_schema', ?)", appliedAt); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := printMigrationStatus(&out, db); err != nil {
+		t.Fatal(err)
+	}
+	first, _, _ := strings.Cut(out.String(), "\n")
+	if want := "applied " + appliedAt.Format(time.RFC3339); !strings.HasSuffix(first, want) {
+		t.Errorf("applied line %q; want it to end %q", first, want)
+	}
+}
@@ -0,0 +1,187 @@
+// cmd/server/user.go
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"voidcase/internal/config"
+	dbpkg "voidcase/internal/db"
+	"voidcase/internal/handlers"
+	"voidcase/internal/models"
+
+	"golang.org/x/term"
+)
+
+const userUsage = `usage: voidcase user <command> [flags] [username]
+
+Commands:
+  add <username>       create an account (-role owner|editor|viewer, default editor)
+  passwd <username>    set a new password and end the account's sessions
+  list                 list accounts
+  disable <username>   disable an account and end its sessions
+  enable <username>    re-enable a disabled account
+  reset-2fa <username> turn off two-factor authentication for a lost device
+
+Passwords are prompted for on a terminal, or read from the first line of
+standard input otherwise. Every command accepts -config.`
+
+// runUserCommand manages accounts directly in the database, for operators
+// who cannot sign in to the admin. Passwords are read from stdin and
+// results written to stdout.
+func runUserCommand(args []string, stdin *os.File, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(userUsage)
+	}
+	command := args[0]
+
+	fs := flag.NewFlagSet("user "+command, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "Path to TOML config file")
+	role := fs.String("role", string(models.RoleEditor), "Role for new accounts")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	username := strings.ToLower(fs.Arg(0))
+	if command != "list" && (fs.NArg() != 1 || username == "") {
+		return errors.New(userUsage)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	if _, err := applyMigrations(sqlDB); err != nil {
+		return err
+	}
+	store := dbpkg.New(sqlDB)
+
+	switch command {
+	case "add":
+		r := models.Role(*role)
+		if !r.Valid() {
+			return fmt.Errorf("invalid role %q", *role)
+		}
+		hash, err := readNewPassword(stdin)
+		if err != nil {
+			return err
+		}
+		u := &models.User{Username: username, PasswordHash: hash, Role: r, CreatedAt: time.Now()}
+		if err := store.CreateUser(u); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created %s (%s)\n", u.Username, u.Role)
+
+	case "passwd":
+		u, err := lookupUser(store, username)
+		if err != nil {
+			return err
+		}
+		hash, err := readNewPassword(stdin)
+		if err != nil {
+			return err
+		}
+		if err := store.SetUserPassword(u.ID, hash); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Password changed for %s\n", u.Username)
+
+	case "disable", "enable":
+		u, err := lookupUser(store, username)
+		if err != nil {
+			return err
+		}
+		if err := store.SetUserDisabled(u.ID, command == "disable"); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s %sd\n", u.Username, command)
+
+	case "reset-2fa":
+		u, err := lookupUser(store, username)
+		if err != nil {
+			return err
+		}
+		if err := store.DisableTOTP(u.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Two-factor authentication turned off for %s\n", u.Username)
+
+	case "list":
+		users, err := store.ListUsers()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tROLE\tSTATUS\t2FA\tCREATED")
+		for _, u := range users {
+			status := "active"
+			if u.Disabled() {
+				status = "disabled"
+			}
+			twoFactor := "off"
+			if u.TOTPEnabled() {
+				twoFactor = "on"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.Username, u.Role, status, twoFactor,
+				u.CreatedAt.Format("2006-01-02"))
+		}
+		return tw.Flush()
+
+	default:
+		return errors.New(userUsage)
+	}
+	return nil
+}
+
+func lookupUser(store *dbpkg.DB, username string) (*models.User, error) {
+	u, err := store.GetUserByUsername(username)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no user named %q", username)
+	}
+	return u, err
+}
+
+// readNewPassword prompts twice if stdin is a terminal, or reads one line
+// from piped input, and returns the password's hash
+func readNewPassword(stdin *os.File) (string, error) {
+	var password string
+	fd := int(stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "New password: ")
+		first, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(os.Stderr, "Confirm password: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	return handlers.HashPassword(password)
+}
@@ -0,0 +1,142 @@
+// cmd/server/user_test.go
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dbpkg "voidcase/internal/db"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userCommand runs `voidcase user` against the database configured in
+// configPath, with password piped to standard input
+type userCommand struct {
+	t          *testing.T
+	configPath string
+	dbPath     string
+}
+
+func newUserCommand(t *testing.T) *userCommand {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "voidcase.db")
+	configPath := filepath.Join(dir, "voidcase.toml")
+	body := `data_dir = "` + filepath.ToSlash(dir) + `"
+db_path = "` + filepath.ToSlash(dbPath) + `"
+csrf_key = "` + strings.Repeat("ab", 32) + `"
+session_key = "` + strings.Repeat("cd", 32) + `"
+`
+	if err := os.WriteFile(configPath, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if !dbpkg.HasFTS5(db) {
+		t.Fatal("SQLite was built without FTS5; run the tests with -tags sqlite_fts5 or make test")
+	}
+	return &userCommand{t: t, configPath: configPath, dbPath: dbPath}
+}
+
+func (c *userCommand) run(password string, args ...string) (string, error) {
+	c.t.Helper()
+	stdin, err := os.CreateTemp(c.t.TempDir(), "stdin")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer stdin.Close()
+	if _, err := stdin.WriteString(password + "\n"); err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := stdin.Seek(0, 0); err != nil {
+		c.t.Fatal(err)
+	}
+
+	args = append([]string{args[0], "-config", c.configPath}, args[1:]...)
+	var out bytes.Buffer
+	err = runUserCommand(args, stdin, &out)
+	return out.String(), err
+}
+
+// store opens the command's database
+func (c *userCommand) store() *dbpkg.DB {
+	c.t.Helper()
+	db, err := sql.Open("sqlite3", c.dbPath)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() { db.Close() })
+	return dbpkg.New(db)
+}
+
+func TestUserCommand(t *testing.T) {
+	c := newUserCommand(t)
+
+	out, err := c.run("correct horse battery", "add", "-role", "owner", "Ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Created ada (owner)\n" {
+		t.Errorf("add printed %q; want the new account", out)
+	}
+	if _, err := c.run("correct horse battery", "add", "-role", "admin", "grace"); err == nil ||
+		!strings.Contains(err.Error(), "invalid role") {
+		t.Errorf("add with an unknown role error = %v; want invalid role", err)
+	}
+
+	if _, err := c.run("staple battery horse", "passwd", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	u, err := c.store().GetUserByUsername("ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("staple battery horse")) != nil {
+		t.Error("passwd did not set the piped password")
+	}
+
+	// The last active owner cannot be disabled
+	if _, err := c.run("", "disable", "ada"); err == nil {
+		t.Error("disabled the only owner")
+	}
+	if _, err := c.run("correct horse battery", "add", "-role", "owner", "grace"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := c.run("", "disable", "ada"); err != nil || out != "ada disabled\n" {
+		t.Fatalf("disable = %q, %v; want ada disabled", out, err)
+	}
+	out, err = c.run("", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "USERNAME") ||
+		strings.Join(strings.Fields(lines[1])[:4], " ") != "ada owner disabled off" {
+		t.Errorf("list printed %q; want a header and ada as a disabled owner without 2FA first", out)
+	}
+
+	if out, err := c.run("", "enable", "ada"); err != nil || out != "ada enabled\n" {
+		t.Errorf("enable = %q, %v; want ada enabled", out, err)
+	}
+	if out, err := c.run("", "reset-2fa", "ada"); err != nil || !strings.Contains(out, "turned off for ada") {
+		t.Errorf("reset-2fa = %q, %v; want two-factor turned off", out, err)
+	}
+
+	if _, err := c.run("", "disable", "linus"); err == nil || err.Error() != `no user named "linus"` {
+		t.Errorf("disable of a missing account error = %v; want no user named", err)
+	}
+	for _, args := range [][]string{{"passwd"}, {"rename", "ada"}} {
+		if _, err := c.run("", args...); err == nil || err.Error() != userUsage {
+			t.Errorf("user %s error = %v; want the usage", strings.Join(args, " "), err)
+		}
+	}
+}
@@ -3,13 +3,15 @@ module voidcase
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gorilla/csrf v1.7.2
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/securecookie v1.1.2
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 	golang.org/x/crypto v0.29.0
+	golang.org/x/term v0.26.0
+	rsc.io/qr v0.2.0
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
-
-require golang.org/x/exp v0.0.0-20240222234643-814bf88cf225
+require golang.org/x/sys v0.27.0 // indirect
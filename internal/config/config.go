@@ -0,0 +1,393 @@
+// internal/config/config.go
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DefaultPath is read when no config file is named and it exists
+const DefaultPath = "voidcase.toml"
+
+// EnvPrefix starts every environment override, e.g. VOIDCASE_LISTEN_ADDR
+const EnvPrefix = "VOIDCASE_"
+
+// secretsFile holds generated keys inside the data directory
+const secretsFile = "secrets.toml"
+
+// UploadMemory is the part of a multipart upload held in memory; the rest
+// of the request spills to temporary files
+const UploadMemory = 32 << 20
+
+// Config is the server configuration. Values are read from defaults, then
+// the config file, then VOIDCASE_* environment variables named after the
+// toml keys.
+type Config struct {
+	ListenAddr string `toml:"listen_addr"`
+	// DBPath defaults to db/filmcms.db inside DataDir
+	DBPath string `toml:"db_path"`
+	// DataDir holds the database, uploads and generated secrets
+	DataDir     string `toml:"data_dir"`
+	TemplateDir string `toml:"template_dir"`
+	StaticDir   string `toml:"static_dir"`
+
+	// CSRFKey and SessionKey are 32-byte keys written as 64 hex characters.
+	// When unset they are generated on first run and persisted in DataDir.
+	CSRFKey    string `toml:"csrf_key"`
+	SessionKey string `toml:"session_key"`
+	// TLSCert and TLSKey are PEM files. When both are set the server
+	// listens with HTTPS.
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies in
+	// front of the server. Requests from them are attributed to the client
+	// they name in X-Forwarded-For.
+	TrustedProxies []string `toml:"trusted_proxies"`
+	// SecureCookies marks cookies HTTPS-only. When unset it follows whether
+	// TLS is configured; set it to true behind a proxy that terminates TLS.
+	SecureCookies *bool `toml:"secure_cookies"`
+
+	// SessionIdleTimeout ends a session that has not been used for that
+	// long. Each use pushes the expiry back, up to SessionMaxAge after
+	// signing in.
+	SessionIdleTimeout Duration `toml:"session_idle_timeout"`
+	SessionMaxAge      Duration `toml:"session_max_age"`
+
+	// MaxUploadBytes bounds the size of a single upload request, and of any
+	// other request body
+	MaxUploadBytes int64 `toml:"max_upload_bytes"`
+	// MaxUploadFiles bounds the number of files in a single upload request
+	MaxUploadFiles int `toml:"max_upload_files"`
+	// Renditions are the image sizes generated for every upload, replacing
+	// the built-in thumb, medium, large and original set. They can only be
+	// set in the config file.
+	Renditions []Rendition `toml:"renditions"`
+
+	// AdminPassword is used for the first account when the database has
+	// none. If empty a random password is generated and logged once.
+	AdminPassword string `toml:"admin_password"`
+
+	// LoginMaxFailures is the number of failed sign-ins, per username or per
+	// IP address, after which further attempts are refused for LoginLockout.
+	// Earlier failures past the first few add an exponential delay.
+	LoginMaxFailures int      `toml:"login_max_failures"`
+	LoginLockout     Duration `toml:"login_lockout"`
+
+	// TrashRetention is how long deleted projects stay in the trash before
+	// they and their unused media are removed for good
+	TrashRetention Duration `toml:"trash_retention"`
+
+	ShutdownTimeout Duration `toml:"shutdown_timeout"`
+}
+
+// Rendition is one generated image size, written as a [[renditions]] table
+type Rendition struct {
+	Name string `toml:"name"`
+	// MaxWidth bounds the output width; 0 keeps the source dimensions
+	MaxWidth int `toml:"max_width"`
+	// Format is "jpeg", "png" or "gif"; empty keeps the source format
+	Format  string `toml:"format"`
+	Quality int    `toml:"quality"`
+}
+
+// Duration is a time.Duration written as a string such as "15s"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		ListenAddr:         ":8080",
+		DataDir:            "data",
+		TemplateDir:        "templates",
+		StaticDir:          "static",
+		SessionIdleTimeout: Duration{24 * time.Hour},
+		SessionMaxAge:      Duration{30 * 24 * time.Hour},
+		MaxUploadBytes:     64 << 20,
+		MaxUploadFiles:     50,
+		LoginMaxFailures:   10,
+		LoginLockout:       Duration{15 * time.Minute},
+		TrashRetention:     Duration{30 * 24 * time.Hour},
+		ShutdownTimeout:    Duration{15 * time.Second},
+	}
+}
+
+// Load builds the configuration from path, or DefaultPath if path is empty
+// and that file exists, followed by environment overrides. Keys that are
+// still unset are loaded from, or generated into, the data directory.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); err == nil {
+			path = DefaultPath
+		}
+	}
+	if path != "" {
+		meta, err := toml.DecodeFile(path, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config: unknown key %q in %s", undecoded[0].String(), path)
+		}
+	}
+
+	if err := cfg.applyEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = filepath.Join(cfg.DataDir, "db", "filmcms.db")
+	}
+
+	if err := cfg.ensureSecrets(); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// applyEnv sets fields from VOIDCASE_<TOML KEY> variables
+func (c *Config) applyEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix) {
+			env[k] = v
+		}
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("toml")
+		name := EnvPrefix + strings.ToUpper(key)
+		raw, ok := env[name]
+		if !ok {
+			continue
+		}
+
+		field := v.Field(i)
+		var err error
+		switch ptr := field.Addr().Interface().(type) {
+		case *string:
+			*ptr = raw
+		case *bool:
+			*ptr, err = strconv.ParseBool(raw)
+		case **bool:
+			var b bool
+			b, err = strconv.ParseBool(raw)
+			*ptr = &b
+		case *int:
+			*ptr, err = strconv.Atoi(raw)
+		case *int64:
+			*ptr, err = strconv.ParseInt(raw, 10, 64)
+		case *Duration:
+			err = ptr.UnmarshalText([]byte(raw))
+		case *[]string:
+			*ptr = nil
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*ptr = append(*ptr, item)
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported type %s", field.Type())
+		}
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// secrets is the layout of the generated secrets file
+type secrets struct {
+	CSRFKey    string `toml:"csrf_key"`
+	SessionKey string `toml:"session_key"`
+}
+
+// ensureSecrets fills unset keys from the secrets file in the data
+// directory, generating and saving any that are missing there too
+func (c *Config) ensureSecrets() error {
+	if c.CSRFKey != "" && c.SessionKey != "" {
+		return nil
+	}
+
+	path := filepath.Join(c.DataDir, secretsFile)
+	var saved secrets
+	if _, err := toml.DecodeFile(path, &saved); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	changed := false
+	for _, key := range []*string{&saved.CSRFKey, &saved.SessionKey} {
+		if *key == "" {
+			generated, err := generateKey()
+			if err != nil {
+				return err
+			}
+			*key = generated
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := os.MkdirAll(c.DataDir, 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("config: saving generated keys: %w", err)
+		}
+		if err := toml.NewEncoder(f).Encode(saved); err != nil {
+			f.Close()
+			return fmt.Errorf("config: saving generated keys: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if c.CSRFKey == "" {
+		c.CSRFKey = saved.CSRFKey
+	}
+	if c.SessionKey == "" {
+		c.SessionKey = saved.SessionKey
+	}
+	return nil
+}
+
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Validate reports the first setting that cannot be used
+func (c *Config) Validate() error {
+	for name, key := range map[string]string{"csrf_key": c.CSRFKey, "session_key": c.SessionKey} {
+		if b, err := hex.DecodeString(key); err != nil || len(b) != 32 {
+			return fmt.Errorf("config: %s must be 64 hex characters", name)
+		}
+	}
+	if c.MaxUploadBytes <= 0 {
+		return fmt.Errorf("config: max_upload_bytes must be positive")
+	}
+	if c.MaxUploadFiles <= 0 {
+		return fmt.Errorf("config: max_upload_files must be positive")
+	}
+	seen := make(map[string]bool)
+	for _, r := range c.Renditions {
+		if r.Name == "" || seen[r.Name] {
+			return fmt.Errorf("config: every rendition needs a unique name")
+		}
+		seen[r.Name] = true
+		if r.MaxWidth < 0 || r.Quality < 0 || r.Quality > 100 {
+			return fmt.Errorf("config: rendition %q: max_width must not be negative and quality must be 0-100", r.Name)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("config: tls_cert and tls_key must be set together")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if parseNet(proxy) == nil {
+			return fmt.Errorf("config: trusted_proxies: %q is not an address or CIDR range", proxy)
+		}
+	}
+	if c.SessionIdleTimeout.Duration <= 0 || c.SessionMaxAge.Duration <= 0 {
+		return fmt.Errorf("config: session_idle_timeout and session_max_age must be positive")
+	}
+	if c.LoginMaxFailures <= 0 {
+		return fmt.Errorf("config: login_max_failures must be positive")
+	}
+	if c.LoginLockout.Duration <= 0 {
+		return fmt.Errorf("config: login_lockout must be positive")
+	}
+	if c.TrashRetention.Duration <= 0 {
+		return fmt.Errorf("config: trash_retention must be positive")
+	}
+	return nil
+}
+
+// TLS reports whether the server listens with HTTPS
+func (c *Config) TLS() bool {
+	return c.TLSCert != ""
+}
+
+// Secure reports whether cookies are sent over HTTPS only
+func (c *Config) Secure() bool {
+	if c.SecureCookies != nil {
+		return *c.SecureCookies
+	}
+	return c.TLS()
+}
+
+// TrustedProxyNets returns the parsed trusted_proxies
+func (c *Config) TrustedProxyNets() []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if n := parseNet(proxy); n != nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+// parseNet parses a CIDR range, or a single address as a range holding only
+// it, returning nil if s is neither
+func parseNet(s string) *net.IPNet {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// CSRFKeyBytes returns the decoded CSRF key
+func (c *Config) CSRFKeyBytes() []byte {
+	b, _ := hex.DecodeString(c.CSRFKey)
+	return b
+}
+
+// SessionKeyBytes returns the decoded session key
+func (c *Config) SessionKeyBytes() []byte {
+	b, _ := hex.DecodeString(c.SessionKey)
+	return b
+}
+
+// UploadsDir is the directory served at /uploads/
+func (c *Config) UploadsDir() string {
+	return filepath.Join(c.DataDir, "uploads")
+}
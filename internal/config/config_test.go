@@ -0,0 +1,160 @@
+// internal/config/config_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig saves a config file in a fresh directory whose data_dir is
+// that directory's "data"
+func writeConfig(t *testing.T, body string) (path, dataDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	dataDir = filepath.Join(dir, "data")
+	path = filepath.Join(dir, "voidcase.toml")
+	body = "data_dir = " + `"` + filepath.ToSlash(dataDir) + `"` + "\n" + body
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, dataDir
+}
+
+func TestLoad(t *testing.T) {
+	path, dataDir := writeConfig(t, `
+listen_addr = ":9000"
+max_upload_files = 5
+session_idle_timeout = "2h"
+trusted_proxies = ["10.0.0.0/8"]
+`)
+	t.Setenv("VOIDCASE_LISTEN_ADDR", "127.0.0.1:9001")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ListenAddr != "127.0.0.1:9001" {
+		t.Errorf("listen_addr = %q; want the environment to win over the file", cfg.ListenAddr)
+	}
+	if cfg.MaxUploadFiles != 5 || cfg.SessionIdleTimeout.Duration != 2*time.Hour {
+		t.Errorf("max_upload_files, session_idle_timeout = %d, %v; want the file's 5, 2h",
+			cfg.MaxUploadFiles, cfg.SessionIdleTimeout)
+	}
+	if cfg.MaxUploadBytes != Default().MaxUploadBytes {
+		t.Errorf("max_upload_bytes = %d; want the default", cfg.MaxUploadBytes)
+	}
+	if want := filepath.Join(dataDir, "db", "filmcms.db"); cfg.DBPath != want {
+		t.Errorf("db_path = %q; want %q under the data directory", cfg.DBPath, want)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path, _ := writeConfig(t, "listen_adress = \":9000\"\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "listen_adress") {
+		t.Errorf("Load error = %v; want the unknown key named", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := Default()
+	err := cfg.applyEnv([]string{
+		"VOIDCASE_DATA_DIR=/srv/voidcase",
+		"VOIDCASE_SECURE_COOKIES=true",
+		"VOIDCASE_MAX_UPLOAD_BYTES=1048576",
+		"VOIDCASE_LOGIN_MAX_FAILURES=3",
+		"VOIDCASE_LOGIN_LOCKOUT=1m30s",
+		"VOIDCASE_TRUSTED_PROXIES=10.0.0.1, 192.168.0.0/16,",
+		"OTHER_LISTEN_ADDR=:1",
+		"PATH=/usr/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DataDir != "/srv/voidcase" || cfg.SecureCookies == nil || !*cfg.SecureCookies ||
+		cfg.MaxUploadBytes != 1<<20 || cfg.LoginMaxFailures != 3 ||
+		cfg.LoginLockout.Duration != 90*time.Second {
+		t.Errorf("config = %+v; want every override applied", cfg)
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[1] != "192.168.0.0/16" {
+		t.Errorf("trusted_proxies = %q; want the two trimmed entries", cfg.TrustedProxies)
+	}
+	if cfg.ListenAddr != Default().ListenAddr {
+		t.Errorf("listen_addr = %q; want variables without the prefix ignored", cfg.ListenAddr)
+	}
+
+	for _, bad := range []string{"VOIDCASE_MAX_UPLOAD_FILES=many", "VOIDCASE_LOGIN_LOCKOUT=soon", "VOIDCASE_SECURE_COOKIES=maybe"} {
+		if err := Default().applyEnv([]string{bad}); err == nil || !strings.Contains(err.Error(), strings.Split(bad, "=")[0]) {
+			t.Errorf("applyEnv(%s) error = %v; want it to name the variable", bad, err)
+		}
+	}
+}
+
+func TestEnsureSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.DataDir = filepath.Join(t.TempDir(), "data")
+	if err := cfg.ensureSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.CSRFKey) != 64 || len(cfg.SessionKey) != 64 || cfg.CSRFKey == cfg.SessionKey {
+		t.Errorf("keys = %q, %q; want two different generated keys", cfg.CSRFKey, cfg.SessionKey)
+	}
+
+	info, err := os.Stat(filepath.Join(cfg.DataDir, secretsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("secrets file mode = %v; want 0600", mode)
+	}
+
+	// A restart reads the saved keys back, and a key set in the config wins
+	again := Default()
+	again.DataDir = cfg.DataDir
+	again.SessionKey = strings.Repeat("ab", 32)
+	if err := again.ensureSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if again.CSRFKey != cfg.CSRFKey || again.SessionKey != strings.Repeat("ab", 32) {
+		t.Errorf("keys after restart = %q, %q; want the saved CSRF key and the configured session key",
+			again.CSRFKey, again.SessionKey)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.CSRFKey = strings.Repeat("01", 32)
+		cfg.SessionKey = strings.Repeat("23", 32)
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Validate of the defaults = %v; want nil", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*Config)
+	}{
+		{"short key", func(c *Config) { c.CSRFKey = "abcd" }},
+		{"non-hex key", func(c *Config) { c.SessionKey = strings.Repeat("zz", 32) }},
+		{"zero upload size", func(c *Config) { c.MaxUploadBytes = 0 }},
+		{"zero upload files", func(c *Config) { c.MaxUploadFiles = 0 }},
+		{"duplicate rendition", func(c *Config) { c.Renditions = []Rendition{{Name: "thumb"}, {Name: "thumb"}} }},
+		{"rendition quality", func(c *Config) { c.Renditions = []Rendition{{Name: "thumb", Quality: 101}} }},
+		{"certificate without key", func(c *Config) { c.TLSCert = "cert.pem" }},
+		{"bad proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/33"} }},
+		{"zero idle timeout", func(c *Config) { c.SessionIdleTimeout.Duration = 0 }},
+		{"zero login failures", func(c *Config) { c.LoginMaxFailures = 0 }},
+		{"zero lockout", func(c *Config) { c.LoginLockout.Duration = 0 }},
+		{"zero retention", func(c *Config) { c.TrashRetention.Duration = 0 }},
+	} {
+		cfg := valid()
+		tc.change(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate = nil; want an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// internal/db/analytics.go
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// analyticsTopN bounds the ranked lists in an analytics summary
+const analyticsTopN = 10
+
+// newSalt returns 32 random bytes, hex encoded
+func newSalt() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// analyticsCutoff returns the start of the reporting window
+func analyticsCutoff(days int) time.Time {
+	return time.Now().UTC().AddDate(0, 0, -days)
+}
+
+func (db *DB) SavePageViews(views []models.PageView) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+        INSERT INTO page_views (page_path, referrer, project_id, ip_hash, browser, platform, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, view := range views {
+		if _, err := stmt.Exec(view.PagePath, view.Referrer, view.ProjectID, view.IPHash,
+			view.Browser, view.Platform, view.ViewedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to save page view: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DailySalt(day string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(
+		"INSERT OR IGNORE INTO analytics_salts (day, salt) VALUES (?, ?)", day, salt); err != nil {
+		return "", err
+	}
+	if err := tx.QueryRow(
+		"SELECT salt FROM analytics_salts WHERE day = ?", day).Scan(&salt); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("DELETE FROM analytics_salts WHERE day != ?", day); err != nil {
+		return "", err
+	}
+	return salt, tx.Commit()
+}
+
+func (db *DB) GetAnalyticsSummary(days int) (*models.AnalyticsSummary, error) {
+	summary := &models.AnalyticsSummary{
+		ProjectViews: make(map[int64]int),
+	}
+	cutoff := analyticsCutoff(days)
+
+	// Get total page views and unique visitors. Views carried over from
+	// before visitors were hashed have an empty ip_hash.
+	err := db.QueryRow(`
+        SELECT COUNT(*), COUNT(DISTINCT NULLIF(ip_hash, ''))
+        FROM page_views
+        WHERE created_at >= ?
+    `, cutoff).Scan(&summary.TotalViews, &summary.UniqueViews)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page views: %w", err)
+	}
+
+	// Get project views
+	rows, err := db.Query(`
+        SELECT project_id, COUNT(*) as views
+        FROM page_views
+        WHERE project_id IS NOT NULL
+        AND created_at >= ?
+        GROUP BY project_id
+        ORDER BY views DESC
+        LIMIT ?
+    `, cutoff, analyticsTopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project views: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var projectID int64
+		var views int
+		if err := rows.Scan(&projectID, &views); err != nil {
+			return nil, fmt.Errorf("failed to scan project views: %w", err)
+		}
+		summary.ProjectViews[projectID] = views
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get top pages
+	rows, err = db.Query(`
+        SELECT page_path, COUNT(*) as count
+        FROM page_views
+        WHERE created_at >= ?
+        GROUP BY page_path
+        ORDER BY count DESC, page_path
+        LIMIT ?
+    `, cutoff, analyticsTopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top pages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var page models.PageCount
+		if err := rows.Scan(&page.Path, &page.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan top pages: %w", err)
+		}
+		summary.TopPages = append(summary.TopPages, page)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Get top referrers
+	rows, err = db.Query(`
+        SELECT referrer, COUNT(*) as count
+        FROM page_views
+        WHERE created_at >= ?
+        AND referrer != ''
+        GROUP BY referrer
+        ORDER BY count DESC, referrer
+        LIMIT ?
+    `, cutoff, analyticsTopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get referrers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var referrer models.ReferrerCount
+		if err := rows.Scan(&referrer.Referrer, &referrer.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan referrer: %w", err)
+		}
+		summary.TopReferrers = append(summary.TopReferrers, referrer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if summary.Browsers, err = db.countByColumn("browser", cutoff); err != nil {
+		return nil, fmt.Errorf("failed to get browsers: %w", err)
+	}
+	if summary.Platforms, err = db.countByColumn("platform", cutoff); err != nil {
+		return nil, fmt.Errorf("failed to get platforms: %w", err)
+	}
+
+	// Get daily views
+	rows, err = db.Query(`
+        SELECT date(created_at) as view_date, COUNT(*) as views
+        FROM page_views
+        WHERE created_at >= ?
+        GROUP BY view_date
+        ORDER BY view_date
+    `, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily views: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var viewDate string
+		var count int
+		if err := rows.Scan(&viewDate, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan daily views: %w", err)
+		}
+		date, err := time.Parse("2006-01-02", viewDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse view date %q: %w", viewDate, err)
+		}
+		summary.DailyViews = append(summary.DailyViews, models.DailyViews{
+			Date:  date,
+			Count: count,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// countByColumn groups views since cutoff by a user agent column
+func (db *DB) countByColumn(column string, cutoff time.Time) ([]models.NameCount, error) {
+	rows, err := db.Query(`
+        SELECT `+column+`, COUNT(*) as count
+        FROM page_views
+        WHERE created_at >= ? AND `+column+` != ''
+        GROUP BY `+column+`
+        ORDER BY count DESC, `+column+`
+        LIMIT ?`, cutoff, analyticsTopN)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.NameCount
+	for rows.Next() {
+		var c models.NameCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
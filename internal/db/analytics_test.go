@@ -0,0 +1,101 @@
+// internal/db/analytics_test.go
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestDailySaltRotates(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		monday, err := s.DailySalt("2024-03-04")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := s.DailySalt("2024-03-04"); again != monday {
+			t.Errorf("second salt for the same day = %q; want %q", again, monday)
+		}
+
+		tuesday, err := s.DailySalt("2024-03-05")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tuesday == monday || len(tuesday) != 64 {
+			t.Errorf("next day's salt = %q; want a new 32-byte hex salt", tuesday)
+		}
+
+		// Asking for the next day deleted the old salt, so hashes from that
+		// day can no longer be recomputed
+		if old, _ := s.DailySalt("2024-03-04"); old == monday {
+			t.Error("old day's salt was kept after rotating")
+		}
+	})
+}
+
+func TestGetAnalyticsSummary(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Night Drive")
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		yesterday := today.AddDate(0, 0, -1)
+		view := func(at time.Time, path, visitor, referrer, browser string, project *int64) models.PageView {
+			return models.PageView{PagePath: path, Referrer: referrer, ViewedAt: at.Add(time.Minute),
+				ProjectID: project, IPHash: visitor, Browser: browser, Platform: "Linux"}
+		}
+		if err := s.SavePageViews([]models.PageView{
+			view(yesterday, "/", "a", "https://news.example.com/", "Firefox", nil),
+			view(yesterday, "/work/night-drive", "a", "", "Firefox", &p.ID),
+			view(today, "/work/night-drive", "b", "https://news.example.com/", "Chrome", &p.ID),
+			view(today, "/work/night-drive", "c", "", "Chrome", &p.ID),
+			// Carried over from before visitors were hashed
+			view(today, "/about", "", "", "", nil),
+			// Outside the window
+			view(today.AddDate(0, 0, -40), "/", "d", "https://old.example.com/", "Safari", nil),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		summary, err := s.GetAnalyticsSummary(30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalViews != 5 || summary.UniqueViews != 3 {
+			t.Errorf("total, unique = %d, %d; want 5, 3", summary.TotalViews, summary.UniqueViews)
+		}
+		if len(summary.ProjectViews) != 1 || summary.ProjectViews[p.ID] != 3 {
+			t.Errorf("project views = %v; want %d: 3", summary.ProjectViews, p.ID)
+		}
+		if len(summary.TopPages) != 3 || summary.TopPages[0] != (models.PageCount{Path: "/work/night-drive", Count: 3}) {
+			t.Errorf("top pages = %+v; want /work/night-drive first with 3 of 3 pages", summary.TopPages)
+		}
+		if len(summary.TopReferrers) != 1 || summary.TopReferrers[0].Count != 2 {
+			t.Errorf("top referrers = %+v; want news.example.com with 2", summary.TopReferrers)
+		}
+		if want := []models.NameCount{{Name: "Chrome", Count: 2}, {Name: "Firefox", Count: 2}}; !equalCounts(summary.Browsers, want) {
+			t.Errorf("browsers = %+v; want %+v", summary.Browsers, want)
+		}
+		if want := []models.NameCount{{Name: "Linux", Count: 5}}; !equalCounts(summary.Platforms, want) {
+			t.Errorf("platforms = %+v; want %+v", summary.Platforms, want)
+		}
+		if len(summary.DailyViews) != 2 ||
+			!summary.DailyViews[0].Date.Equal(yesterday) || summary.DailyViews[0].Count != 2 ||
+			!summary.DailyViews[1].Date.Equal(today) || summary.DailyViews[1].Count != 3 {
+			t.Errorf("daily views = %+v; want 2 yesterday and 3 today", summary.DailyViews)
+		}
+	})
+}
+
+func equalCounts(got, want []models.NameCount) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
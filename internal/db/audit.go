@@ -0,0 +1,264 @@
+// internal/db/audit.go
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// systemActor names changes made without an actor, such as from the
+// command line
+const systemActor = "system"
+
+// auditFields is a snapshot of the audited fields of an entity. Values must
+// marshal to JSON. Secrets such as password hashes are never included.
+type auditFields map[string]interface{}
+
+// newAuditEntry builds the entry for a change from before to after, keeping
+// only the fields that differ. A nil before is a creation and a nil after a
+// deletion; both nil records an action without fields, such as a password
+// change. It reports false for an update that changed nothing.
+func newAuditEntry(actor *models.Actor, action, entityType string, entityID int64,
+	before, after auditFields) (*models.AuditEntry, bool, error) {
+	if before != nil && after != nil {
+		var changed bool
+		before, after, changed = diffFields(before, after)
+		if !changed {
+			return nil, false, nil
+		}
+	}
+
+	entry := &models.AuditEntry{
+		ActorName:  systemActor,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		CreatedAt:  time.Now(),
+	}
+	if actor != nil {
+		entry.ActorID = actor.UserID
+		entry.IP = actor.IP
+		if actor.Username != "" {
+			entry.ActorName = actor.Username
+		}
+	}
+
+	var err error
+	if entry.Before, err = marshalFields(before); err != nil {
+		return nil, false, err
+	}
+	if entry.After, err = marshalFields(after); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// diffFields reduces before and after to the fields whose JSON differs
+func diffFields(before, after auditFields) (auditFields, auditFields, bool) {
+	changedBefore, changedAfter := auditFields{}, auditFields{}
+	for key, old := range before {
+		if value, ok := after[key]; !ok {
+			changedBefore[key] = old
+		} else if !sameJSON(old, value) {
+			changedBefore[key] = old
+			changedAfter[key] = value
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter, len(changedBefore) > 0 || len(changedAfter) > 0
+}
+
+func sameJSON(a, b interface{}) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
+
+func marshalFields(fields auditFields) (string, error) {
+	if len(fields) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(fields)
+	return string(b), err
+}
+
+func projectAudit(p *models.Project) auditFields {
+	tags := append([]string{}, p.Tags...)
+	sort.Strings(tags)
+	return auditFields{
+		"title":       p.Title,
+		"slug":        p.Slug,
+		"description": p.Description,
+		"video_embed": p.VideoEmbed,
+		"date":        p.Date.Format("2006-01-02"),
+		"tags":        tags,
+		"status":      p.Status,
+		"publish_at":  p.PublishAt,
+		"featured":    p.Featured,
+	}
+}
+
+// projectChange snapshots a saved project, noting how many images were
+// newly attached to it
+func projectChange(p *models.Project, added int) auditFields {
+	fields := projectAudit(p)
+	if added > 0 {
+		fields["images_added"] = added
+	}
+	return fields
+}
+
+// countAttached counts the images addProjectImages attached rather than
+// skipped as duplicates
+func countAttached(images []models.Image) int {
+	n := 0
+	for _, img := range images {
+		if img.ID != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func imageAudit(img *models.Image) auditFields {
+	return auditFields{
+		"project_id": img.ProjectID,
+		"media_id":   img.Media.ID,
+		"caption":    img.Caption,
+		"alt_text":   img.AltText,
+		"is_cover":   img.IsCover,
+	}
+}
+
+func mediaAudit(m *models.Media) auditFields {
+	return auditFields{
+		"hash":   m.Hash,
+		"path":   m.Path,
+		"format": m.Format,
+		"width":  m.Width,
+		"height": m.Height,
+		"bytes":  m.Bytes,
+	}
+}
+
+func configAudit(c *models.SiteConfig) auditFields {
+	return auditFields{
+		"about_text":    c.AboutText,
+		"contact_info":  c.ContactInfo,
+		"tracking_code": c.TrackingCode,
+		"theme_name":    c.ThemeName,
+	}
+}
+
+func userAudit(u *models.User) auditFields {
+	return auditFields{
+		"username": u.Username,
+		"role":     u.Role,
+		"disabled": u.Disabled(),
+	}
+}
+
+func inviteAudit(inv *models.Invite) auditFields {
+	return auditFields{
+		"role":       inv.Role,
+		"expires_at": inv.ExpiresAt,
+	}
+}
+
+// As returns a copy of db that records actor in the audit log
+func (db *DB) As(actor models.Actor) Store {
+	audited := *db
+	audited.actor = &actor
+	return &audited
+}
+
+// audit records a change in tx. See newAuditEntry for the meaning of before
+// and after.
+func (db *DB) audit(tx *sql.Tx, action, entityType string, entityID int64, before, after auditFields) error {
+	entry, ok, err := newAuditEntry(db.actor, action, entityType, entityID, before, after)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO audit_log (actor_id, actor_name, action, entity_type, entity_id, before, after, ip, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ActorID, entry.ActorName, entry.Action, entry.EntityType, entry.EntityID,
+		entry.Before, entry.After, entry.IP, entry.CreatedAt)
+	return err
+}
+
+// loadProjectAudit snapshots a project inside tx before it changes
+func loadProjectAudit(tx *sql.Tx, id int64) (auditFields, error) {
+	p, err := loadProject(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return projectAudit(&p), nil
+}
+
+func (db *DB) ListAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	if filter.Actor != "" {
+		where = append(where, "actor_name = ? COLLATE NOCASE")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.EntityType != "" {
+		where = append(where, "entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		where = append(where, "entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+
+	query := `
+        SELECT id, actor_id, actor_name, action, entity_type, entity_id, before, after, ip, created_at
+        FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.EntityType, &e.EntityID,
+			&e.Before, &e.After, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,40 @@
+// internal/db/audit_test.go
+package db_test
+
+import (
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestAuditRecordsActor(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		u := createUser(t, s, "ada")
+		actor := models.Actor{UserID: &u.ID, Username: u.Username, IP: "10.0.0.1"}
+		dbtest.CreateProject(t, s.As(actor), "Night Drive")
+
+		entries, err := s.ListAuditLog(models.AuditFilter{Action: "project.create"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("got %d project.create entries; want 1", len(entries))
+		}
+		e := entries[0]
+		if e.ActorID == nil || *e.ActorID != u.ID || e.ActorName != "ada" || e.IP != "10.0.0.1" {
+			t.Errorf("entry = %+v; want it attributed to ada from 10.0.0.1", e)
+		}
+
+		entries, err = s.ListAuditLog(models.AuditFilter{Actor: "system"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Action == "project.create" {
+				t.Errorf("change made through As recorded as system: %+v", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,229 @@
+// internal/db/categories.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"voidcase/internal/models"
+	"voidcase/internal/utils"
+)
+
+// ErrCategoryExists is returned when a category would share its name or
+// slug with another
+var ErrCategoryExists = errors.New("another category already has that name or slug")
+
+// categoryOrder sorts the tags aliased t that are categories first, in
+// category order
+const categoryOrder = `
+        COALESCE((SELECT c.position FROM categories c WHERE c.name = t.name), 1 << 30)`
+
+const categoryColumns = `c.id, c.name, c.slug, c.position, c.visible,
+               (SELECT COUNT(DISTINCT p.id) FROM tags t
+                JOIN project_tags pt ON pt.tag_id = t.id
+                JOIN projects p ON p.id = pt.project_id
+                WHERE t.name = c.name AND ` + projectLive + `)`
+
+func scanCategory(row rowScanner) (models.Category, error) {
+	var c models.Category
+	err := row.Scan(&c.ID, &c.Name, &c.Slug, &c.Position, &c.Visible, &c.ProjectCount)
+	return c, err
+}
+
+func (db *DB) ListCategories() ([]models.Category, error) {
+	rows, err := db.Query(`
+        SELECT ` + categoryColumns + `
+        FROM categories c
+        ORDER BY c.position, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (db *DB) GetCategory(id int64) (*models.Category, error) {
+	c, err := scanCategory(db.QueryRow(`
+        SELECT `+categoryColumns+` FROM categories c WHERE c.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (db *DB) GetCategoryBySlug(slug string) (*models.Category, error) {
+	c, err := scanCategory(db.QueryRow(`
+        SELECT `+categoryColumns+` FROM categories c WHERE c.slug = ?`, slug))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// categoryAudit snapshots the audited fields of a category
+func categoryAudit(c *models.Category) auditFields {
+	return auditFields{
+		"name":     c.Name,
+		"slug":     c.Slug,
+		"position": c.Position,
+		"visible":  c.Visible,
+	}
+}
+
+// categorySlug is the slug chosen for c, or one derived from its name
+func categorySlug(c *models.Category) string {
+	if strings.TrimSpace(c.Slug) != "" {
+		return utils.Slugify(c.Slug)
+	}
+	return utils.Slugify(c.Name)
+}
+
+// checkCategoryUnique returns ErrCategoryExists if a category other than id
+// has c's name or slug
+func checkCategoryUnique(tx *sql.Tx, id int64, c *models.Category) error {
+	var taken bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE (name = ? OR slug = ?) AND id != ?)",
+		c.Name, c.Slug, id).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrCategoryExists
+	}
+	return nil
+}
+
+// renameCategoryTag gives the tag named old, if any, the category's new name
+// so its projects stay in the category. When another tag already has the new
+// name, that tag takes its spelling instead.
+func renameCategoryTag(tx *sql.Tx, old, name string) error {
+	if _, err := tx.Exec(`
+        UPDATE tags SET name = ?
+        WHERE name = ? AND NOT EXISTS (
+            SELECT 1 FROM tags o WHERE o.name = ? AND o.id != tags.id
+        )`, name, old, name); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE tags SET name = ? WHERE name = ?", name, name)
+	return err
+}
+
+func (db *DB) CreateCategory(c *models.Category) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	c.Slug = categorySlug(c)
+	if err := checkCategoryUnique(tx, 0, c); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(
+		"SELECT COALESCE(MAX(position) + 1, 0) FROM categories").Scan(&c.Position); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO categories (name, slug, position, visible) VALUES (?, ?, ?, ?)",
+		c.Name, c.Slug, c.Position, c.Visible)
+	if err != nil {
+		return err
+	}
+	if c.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	if err := renameCategoryTag(tx, c.Name, c.Name); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "category.create", "category", c.ID, nil, categoryAudit(c)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) UpdateCategory(c *models.Category) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanCategory(tx.QueryRow(
+		"SELECT "+categoryColumns+" FROM categories c WHERE c.id = ?", c.ID))
+	if err != nil {
+		return err
+	}
+
+	c.Slug = categorySlug(c)
+	c.Position = before.Position
+	if err := checkCategoryUnique(tx, c.ID, c); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE categories SET name = ?, slug = ?, visible = ? WHERE id = ?",
+		c.Name, c.Slug, c.Visible, c.ID); err != nil {
+		return err
+	}
+	if err := renameCategoryTag(tx, before.Name, c.Name); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "category.update", "category", c.ID, categoryAudit(&before), categoryAudit(c)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) ReorderCategories(categoryIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for position, id := range categoryIDs {
+		var old int
+		if err := tx.QueryRow("SELECT position FROM categories WHERE id = ?", id).Scan(&old); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE categories SET position = ? WHERE id = ?", position, id); err != nil {
+			return err
+		}
+		if err := db.audit(tx, "category.reorder", "category", id,
+			auditFields{"position": old}, auditFields{"position": position}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteCategory(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	c, err := scanCategory(tx.QueryRow(
+		"SELECT "+categoryColumns+" FROM categories c WHERE c.id = ?", id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "category.delete", "category", id, categoryAudit(&c), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
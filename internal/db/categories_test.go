@@ -0,0 +1,106 @@
+// internal/db/categories_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestCategories(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		music := &models.Category{Name: "Music Video", Visible: true}
+		if err := s.CreateCategory(music); err != nil {
+			t.Fatal(err)
+		}
+		if music.Slug != "music-video" {
+			t.Errorf("derived slug = %q; want music-video", music.Slug)
+		}
+		docs := &models.Category{Name: "Documentary", Slug: "Docs"}
+		if err := s.CreateCategory(docs); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateCategory(&models.Category{Name: "music video"}); err != db.ErrCategoryExists {
+			t.Errorf("duplicate name error = %v; want ErrCategoryExists", err)
+		}
+		if err := s.CreateCategory(&models.Category{Name: "Docs 2", Slug: "docs"}); err != db.ErrCategoryExists {
+			t.Errorf("duplicate slug error = %v; want ErrCategoryExists", err)
+		}
+
+		if err := s.ReorderCategories([]int64{docs.ID, music.ID}); err != nil {
+			t.Fatal(err)
+		}
+		categories, err := s.ListCategories()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 2 || categories[0].ID != docs.ID || categories[1].ID != music.ID {
+			t.Fatalf("ListCategories = %+v; want Documentary then Music Video", categories)
+		}
+
+		// Renaming a category renames its tag, so projects stay in it
+		dbtest.CreateProject(t, s, "Pier", "Documentary")
+		docs.Name = "Documentaries"
+		if err := s.UpdateCategory(docs); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetCategoryBySlug("docs"); err != nil || got.ProjectCount != 1 {
+			t.Fatalf("renamed category = %+v, %v; want its project counted", got, err)
+		}
+		if _, err := s.GetTagByName("Documentaries"); err != nil {
+			t.Fatalf("category tag was not renamed: %v", err)
+		}
+
+		if err := s.DeleteCategory(docs.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetCategory(docs.ID); err != sql.ErrNoRows {
+			t.Errorf("deleted category error = %v; want sql.ErrNoRows", err)
+		}
+		if _, err := s.GetTagByName("Documentaries"); err != nil {
+			t.Errorf("deleting a category removed its tag: %v", err)
+		}
+	})
+}
+
+func TestRenamingTagRenamesCategory(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		category := &models.Category{Name: "Narrative", Visible: true}
+		if err := s.CreateCategory(category); err != nil {
+			t.Fatal(err)
+		}
+		dbtest.CreateProject(t, s, "Pier", "Narrative")
+
+		tag, err := s.GetTagByName("Narrative")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag.Name = "Fiction"
+		if err := s.UpdateTag(tag); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetCategory(category.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Fiction" || got.Slug != "narrative" || got.ProjectCount != 1 {
+			t.Fatalf("category = %+v; want Fiction at narrative with its project", got)
+		}
+
+		other := &models.Category{Name: "Documentary", Visible: true}
+		if err := s.CreateCategory(other); err != nil {
+			t.Fatal(err)
+		}
+		tag.Name = "Documentary"
+		if err := s.UpdateTag(tag); err != db.ErrCategoryExists {
+			t.Fatalf("renaming onto another category error = %v; want ErrCategoryExists", err)
+		}
+		if got, err := s.GetTagByName("Fiction"); err != nil || got.ID != tag.ID {
+			t.Fatalf("refused rename changed the tag: %v, %v", got, err)
+		}
+	})
+}
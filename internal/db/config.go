@@ -0,0 +1,53 @@
+// internal/db/config.go
+package db
+
+import (
+	"database/sql"
+
+	"voidcase/internal/models"
+)
+
+func (db *DB) UpdateSiteConfig(config *models.SiteConfig) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getSiteConfig(tx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE site_config 
+        SET about_text = ?, contact_info = ?, tracking_code = ?, 
+            theme_name = ?, updated_at = ?
+        WHERE id = 1`,
+		config.AboutText, config.ContactInfo, config.TrackingCode,
+		config.ThemeName, config.UpdatedAt); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "settings.update", "settings", 1, configAudit(before), configAudit(config)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) GetSiteConfig() (*models.SiteConfig, error) {
+	return getSiteConfig(db)
+}
+
+func getSiteConfig(q queryRower) (*models.SiteConfig, error) {
+	config := &models.SiteConfig{}
+	err := q.QueryRow(`
+        SELECT id, about_text, contact_info, tracking_code, theme_name, updated_at 
+        FROM site_config WHERE id = 1
+    `).Scan(&config.ID, &config.AboutText, &config.ContactInfo,
+		&config.TrackingCode, &config.ThemeName, &config.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return &models.SiteConfig{ThemeName: "default"}, nil
+	}
+	return config, err
+}
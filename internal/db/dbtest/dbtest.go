@@ -0,0 +1,69 @@
+// internal/db/dbtest/dbtest.go
+
+// Package dbtest provides the stores and fixtures shared by the tests of
+// internal/db and of the packages built on it
+package dbtest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/migrate"
+	"voidcase/internal/models"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// EachStore runs test against a MemoryStore and a migrated SQLite database
+// so both are held to the same contract
+func EachStore(t *testing.T, test func(t *testing.T, s db.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, db.NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, NewDB(t))
+	})
+}
+
+// NewDB returns a DB on a fresh, fully migrated database with none of the
+// seeded categories, so it starts as empty as a MemoryStore. It fails the
+// test when the driver lacks FTS5; run the tests with -tags sqlite_fts5, as
+// make test does.
+func NewDB(t *testing.T) *db.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if !db.HasFTS5(sqlDB) {
+		t.Fatal("SQLite was built without FTS5; run the tests with -tags sqlite_fts5 or make test")
+	}
+	if _, err := migrate.Apply(sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlDB.Exec("DELETE FROM categories"); err != nil {
+		t.Fatal(err)
+	}
+	return db.New(sqlDB)
+}
+
+// CreateProject saves a project published on 1 March 2024 with tags,
+// failing the test on error
+func CreateProject(t *testing.T, s db.Store, title string, tags ...string) *models.Project {
+	t.Helper()
+	p := &models.Project{
+		Title:  title,
+		Date:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Status: models.StatusPublished,
+		Tags:   tags,
+	}
+	if err := s.CreateProject(p, nil); err != nil {
+		t.Fatalf("CreateProject(%q): %v", title, err)
+	}
+	return p
+}
@@ -0,0 +1,294 @@
+// internal/db/images.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"voidcase/internal/models"
+)
+
+// ErrImageOrder is returned when a new image order does not list each of the
+// project's images exactly once
+var ErrImageOrder = errors.New("image order must list every image of the project exactly once")
+
+// imageColumns selects a project_media attachment joined with its media
+const imageColumns = `pm.id, pm.project_id, pm.position, pm.caption, pm.alt_text, pm.is_cover,
+               pm.created_at, ` + mediaColumns
+
+const imageFrom = `project_media pm JOIN media m ON m.id = pm.media_id`
+
+func scanImage(row rowScanner) (models.Image, error) {
+	var img models.Image
+	err := row.Scan(&img.ID, &img.ProjectID, &img.Position, &img.Caption, &img.AltText,
+		&img.IsCover, &img.CreatedAt,
+		&img.Media.ID, &img.Hash, &img.Path, &img.Format, &img.Width, &img.Height,
+		&img.Bytes, &img.Media.CreatedAt)
+	return img, err
+}
+
+func queryImages(q queryer, query string, args ...interface{}) ([]models.Image, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.Image
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := attachRenditions(q, imageMedia(images)); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// imageMedia returns pointers to the media embedded in images
+func imageMedia(images []models.Image) []*models.Media {
+	media := make([]*models.Media, len(images))
+	for i := range images {
+		media[i] = &images[i].Media
+	}
+	return media
+}
+
+// addProjectImages appends images after the project's existing ones. Images
+// whose media has no ID are stored as new media first; media already
+// attached to the project is skipped.
+func addProjectImages(tx *sql.Tx, projectID int64, images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	var next int
+	if err := tx.QueryRow(
+		"SELECT COALESCE(MAX(position) + 1, 0) FROM project_media WHERE project_id = ?",
+		projectID).Scan(&next); err != nil {
+		return err
+	}
+
+	for i := range images {
+		img := &images[i]
+		if img.Media.ID == 0 {
+			if err := insertMedia(tx, &img.Media); err != nil {
+				return err
+			}
+		}
+
+		img.ProjectID = projectID
+		img.Position = next
+		result, err := tx.Exec(`
+            INSERT OR IGNORE INTO project_media (project_id, media_id, position, caption, alt_text, is_cover, created_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			projectID, img.Media.ID, img.Position, img.Caption, img.AltText,
+			img.IsCover, img.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to attach media: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			continue
+		}
+		if img.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		next++
+	}
+	return nil
+}
+
+func (db *DB) ReorderImages(projectID int64, imageIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = ?)",
+		projectID).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	before, err := imageOrder(tx, projectID)
+	if err != nil {
+		return err
+	}
+	if !sameImages(before, imageIDs) {
+		return ErrImageOrder
+	}
+	for position, id := range imageIDs {
+		if _, err := tx.Exec(
+			"UPDATE project_media SET position = ? WHERE id = ? AND project_id = ?",
+			position, id, projectID); err != nil {
+			return err
+		}
+	}
+	after, err := imageOrder(tx, projectID)
+	if err != nil {
+		return err
+	}
+	if err := db.audit(tx, "project.reorder_images", "project", projectID,
+		auditFields{"images": before}, auditFields{"images": after}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sameImages reports whether imageIDs holds each of current exactly once
+func sameImages(current, imageIDs []int64) bool {
+	if len(imageIDs) != len(current) {
+		return false
+	}
+	pending := make(map[int64]bool, len(current))
+	for _, id := range current {
+		pending[id] = true
+	}
+	for _, id := range imageIDs {
+		if !pending[id] {
+			return false
+		}
+		delete(pending, id)
+	}
+	return true
+}
+
+// imageOrder returns the IDs of a project's images in display order
+func imageOrder(tx *sql.Tx, projectID int64) ([]int64, error) {
+	rows, err := tx.Query(
+		"SELECT id FROM project_media WHERE project_id = ? ORDER BY position, id", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (db *DB) UpdateImage(img *models.Image) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := scanImage(tx.QueryRow(
+		"SELECT "+imageColumns+" FROM "+imageFrom+" WHERE pm.id = ? AND pm.project_id = ?",
+		img.ID, img.ProjectID))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE project_media SET caption = ?, alt_text = ?
+        WHERE id = ?`, img.Caption, img.AltText, img.ID); err != nil {
+		return err
+	}
+
+	updated := old
+	updated.Caption, updated.AltText = img.Caption, img.AltText
+	if err := db.audit(tx, "image.update", "image", img.ID, imageAudit(&old), imageAudit(&updated)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) SetCoverImage(projectID, imageID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM project_media WHERE id = ? AND project_id = ?)",
+		imageID, projectID).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	var previous int64
+	if err := tx.QueryRow(`
+        SELECT COALESCE(MIN(id), 0) FROM project_media
+        WHERE project_id = ? AND is_cover`, projectID).Scan(&previous); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        UPDATE project_media SET is_cover = (id = ?)
+        WHERE project_id = ?`, imageID, projectID); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "project.set_cover", "project", projectID,
+		auditFields{"cover_image": previous}, auditFields{"cover_image": imageID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteImage(projectID, imageID int64) (*models.Media, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	img, err := scanImage(tx.QueryRow(
+		"SELECT "+imageColumns+" FROM "+imageFrom+" WHERE pm.id = ? AND pm.project_id = ?",
+		imageID, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM project_media WHERE id = ?", imageID); err != nil {
+		return nil, err
+	}
+	// Close the gap left in the ordering
+	if _, err := tx.Exec(`
+        UPDATE project_media SET position = position - 1
+        WHERE project_id = ? AND position > ?`, projectID, img.Position); err != nil {
+		return nil, err
+	}
+
+	orphans, err := deleteOrphanedMedia(tx, []models.Media{img.Media})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, "image.delete", "image", imageID, imageAudit(&img), nil); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if len(orphans) == 0 {
+		return nil, nil
+	}
+	return &orphans[0], nil
+}
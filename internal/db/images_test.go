@@ -0,0 +1,99 @@
+// internal/db/images_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func imageIDs(p *models.Project) []int64 {
+	var ids []int64
+	for _, img := range p.Images {
+		ids = append(ids, img.ID)
+	}
+	return ids
+}
+
+func TestReorderImages(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := &models.Project{Title: "Stills", Status: models.StatusPublished}
+		images := []models.Image{testImage("a"), testImage("b"), testImage("c")}
+		if err := s.CreateProject(p, images); err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, b, c := p.Images[0].ID, p.Images[1].ID, p.Images[2].ID
+
+		for _, tc := range []struct {
+			name string
+			ids  []int64
+		}{
+			{"partial", []int64{c, a}},
+			{"duplicated", []int64{c, a, a}},
+			{"foreign", []int64{c, a, b, c + 100}},
+			{"empty", nil},
+		} {
+			if err := s.ReorderImages(p.ID, tc.ids); err != db.ErrImageOrder {
+				t.Errorf("%s order error = %v; want ErrImageOrder", tc.name, err)
+			}
+		}
+		if got, _ := s.GetProject(p.ID); !equalIDs(imageIDs(got), []int64{a, b, c}) {
+			t.Errorf("order after rejected reorders = %v; want it unchanged", imageIDs(got))
+		}
+
+		if err := s.ReorderImages(p.ID, []int64{c, a, b}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetProject(p.ID); !equalIDs(imageIDs(got), []int64{c, a, b}) {
+			t.Errorf("order = %v; want %v", imageIDs(got), []int64{c, a, b})
+		}
+
+		if err := s.ReorderImages(p.ID+1, nil); err != sql.ErrNoRows {
+			t.Errorf("reordering a missing project error = %v; want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestDeleteImageRemovesUnusedMedia(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := &models.Project{Title: "Pier", Date: time.Now()}
+		if err := s.CreateProject(p, []models.Image{testImage("only")}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orphan, err := s.DeleteImage(p.ID, p.Images[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orphan == nil || orphan.Hash != "only" {
+			t.Fatalf("DeleteImage returned %+v; want the unused media", orphan)
+		}
+		if media, err := s.ListMedia(); err != nil || len(media) != 0 {
+			t.Fatalf("ListMedia = %v, %v; want none", media, err)
+		}
+	})
+}
+
+func equalIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+// internal/db/logins.go
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+func (db *DB) RecordLoginAttempt(attempt *models.LoginAttempt) error {
+	result, err := db.Exec(`
+        INSERT INTO login_attempts (username, ip, success, created_at)
+        VALUES (?, ?, ?, ?)`,
+		attempt.Username, attempt.IP, attempt.Success, attempt.CreatedAt)
+	if err != nil {
+		return err
+	}
+	attempt.ID, err = result.LastInsertId()
+	return err
+}
+
+func (db *DB) LoginFailures(username, ip string, since time.Time) (models.LoginFailures, error) {
+	var f models.LoginFailures
+	var err error
+	if f.Username, f.LastUsername, err = db.failuresFor("username", username, since); err != nil {
+		return f, err
+	}
+	f.IP, f.LastIP, err = db.failuresFor("ip", ip, since)
+	return f, err
+}
+
+// failuresFor counts failures for one value of column, which is username
+// or ip, after since and after the latest success for that value
+func (db *DB) failuresFor(column, value string, since time.Time) (int, time.Time, error) {
+	var lastSuccess time.Time
+	err := db.QueryRow(`
+        SELECT created_at FROM login_attempts
+        WHERE `+column+` = ? AND success = 1 AND created_at > ?
+        ORDER BY created_at DESC LIMIT 1`, value, since).Scan(&lastSuccess)
+	if err == nil {
+		since = lastSuccess
+	} else if err != sql.ErrNoRows {
+		return 0, time.Time{}, err
+	}
+
+	var count int
+	if err := db.QueryRow(`
+        SELECT COUNT(*) FROM login_attempts
+        WHERE `+column+` = ? AND success = 0 AND created_at > ?`, value, since).Scan(&count); err != nil {
+		return 0, time.Time{}, err
+	}
+	if count == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	var last time.Time
+	err = db.QueryRow(`
+        SELECT created_at FROM login_attempts
+        WHERE `+column+` = ? AND success = 0
+        ORDER BY created_at DESC LIMIT 1`, value).Scan(&last)
+	return count, last, err
+}
+
+func (db *DB) RecentLoginAttempts(limit int) ([]models.LoginAttempt, error) {
+	rows, err := db.Query(`
+        SELECT id, username, ip, success, created_at FROM login_attempts
+        ORDER BY created_at DESC, id DESC
+        LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.Scan(&a.ID, &a.Username, &a.IP, &a.Success, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func (db *DB) DeleteLoginAttemptsBefore(t time.Time) error {
+	_, err := db.Exec("DELETE FROM login_attempts WHERE created_at < ?", t)
+	return err
+}
@@ -0,0 +1,48 @@
+// internal/db/logins_test.go
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestLoginFailuresResetOnSuccess(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		start := time.Now().Add(-time.Hour)
+		record := func(username, ip string, success bool, at time.Duration) {
+			t.Helper()
+			attempt := &models.LoginAttempt{Username: username, IP: ip, Success: success, CreatedAt: start.Add(at)}
+			if err := s.RecordLoginAttempt(attempt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		record("ada", "10.0.0.1", false, time.Minute)
+		record("ada", "10.0.0.2", false, 2*time.Minute)
+		record("ada", "10.0.0.1", true, 3*time.Minute)
+		record("ada", "10.0.0.2", false, 4*time.Minute)
+		record("grace", "10.0.0.2", false, 5*time.Minute)
+
+		f, err := s.LoginFailures("ada", "10.0.0.2", start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Username != 1 || f.IP != 3 {
+			t.Errorf("failures = %d by username, %d by ip; want 1 and 3", f.Username, f.IP)
+		}
+		if !f.LastIP.Equal(start.Add(5 * time.Minute)) {
+			t.Errorf("LastIP = %v; want the latest failure", f.LastIP)
+		}
+
+		f, err = s.LoginFailures("ada", "10.0.0.2", start.Add(4*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Username != 0 || f.IP != 1 {
+			t.Errorf("failures since = %d by username, %d by ip; want 0 and 1", f.Username, f.IP)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+// internal/db/media.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// ErrMediaInUse is returned when deleting media still attached to a project
+// or used as a tag's cover
+var ErrMediaInUse = errors.New("media is attached to a project or is a tag's cover")
+
+const mediaColumns = `m.id, m.hash, m.path, m.format, m.width, m.height, m.bytes, m.created_at`
+
+func scanMedia(row rowScanner) (models.Media, error) {
+	var m models.Media
+	err := row.Scan(&m.ID, &m.Hash, &m.Path, &m.Format, &m.Width, &m.Height,
+		&m.Bytes, &m.CreatedAt)
+	return m, err
+}
+
+// attachRenditions loads the renditions of every media item in one query.
+// The same media may appear more than once.
+func attachRenditions(q queryer, media []*models.Media) error {
+	if len(media) == 0 {
+		return nil
+	}
+
+	index := make(map[int64][]*models.Media, len(media))
+	var args []interface{}
+	for _, m := range media {
+		if _, ok := index[m.ID]; !ok {
+			args = append(args, m.ID)
+		}
+		index[m.ID] = append(index[m.ID], m)
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`
+        SELECT id, media_id, name, path, format, width, height, bytes
+        FROM image_renditions
+        WHERE media_id IN (%s)
+        ORDER BY width`, strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.ImageRendition
+		if err := rows.Scan(&r.ID, &r.MediaID, &r.Name, &r.Path, &r.Format,
+			&r.Width, &r.Height, &r.Bytes); err != nil {
+			return err
+		}
+		for _, m := range index[r.MediaID] {
+			m.Renditions = append(m.Renditions, r)
+		}
+	}
+	return rows.Err()
+}
+
+// insertMedia records m and its renditions, assigning m.ID. Content that is
+// already stored under the same hash is reused instead.
+func insertMedia(tx *sql.Tx, m *models.Media) error {
+	err := tx.QueryRow("SELECT id FROM media WHERE hash = ?", m.Hash).Scan(&m.ID)
+	if err == nil {
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	result, err := tx.Exec(`
+        INSERT INTO media (hash, path, format, width, height, bytes, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.Hash, m.Path, m.Format, m.Width, m.Height, m.Bytes, m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save media record: %w", err)
+	}
+	if m.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	for i := range m.Renditions {
+		r := &m.Renditions[i]
+		r.MediaID = m.ID
+		result, err := tx.Exec(`
+            INSERT INTO image_renditions (media_id, name, path, format, width, height, bytes)
+            VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			r.MediaID, r.Name, r.Path, r.Format, r.Width, r.Height, r.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to save image rendition: %w", err)
+		}
+		if r.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOrphanedMedia removes the records of any of media that is no longer
+// attached to a project or used as a tag's cover and returns them, with
+// renditions, so their files can be cleaned up
+func deleteOrphanedMedia(tx *sql.Tx, media []models.Media) ([]models.Media, error) {
+	var orphans []models.Media
+	seen := make(map[int64]bool)
+	for _, m := range media {
+		if seen[m.ID] {
+			continue
+		}
+		seen[m.ID] = true
+
+		var attached bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM project_media WHERE media_id = ?)
+                OR EXISTS(SELECT 1 FROM tags WHERE cover_media_id = ?)`,
+			m.ID, m.ID).Scan(&attached); err != nil {
+			return nil, err
+		}
+		if !attached {
+			orphans = append(orphans, m)
+		}
+	}
+
+	ptrs := make([]*models.Media, len(orphans))
+	for i := range orphans {
+		orphans[i].Renditions = nil
+		ptrs[i] = &orphans[i]
+	}
+	if err := attachRenditions(tx, ptrs); err != nil {
+		return nil, err
+	}
+
+	for _, m := range orphans {
+		if _, err := tx.Exec("DELETE FROM image_renditions WHERE media_id = ?", m.ID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM media WHERE id = ?", m.ID); err != nil {
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
+
+func (db *DB) ListMedia() ([]models.Media, error) {
+	rows, err := db.Query(`
+        SELECT ` + mediaColumns + `,
+               (SELECT COUNT(*) FROM project_media pm WHERE pm.media_id = m.id),
+               (SELECT COUNT(*) FROM tags t WHERE t.cover_media_id = m.id)
+        FROM media m
+        ORDER BY m.created_at DESC, m.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []models.Media
+	for rows.Next() {
+		var m models.Media
+		if err := rows.Scan(&m.ID, &m.Hash, &m.Path, &m.Format, &m.Width, &m.Height,
+			&m.Bytes, &m.CreatedAt, &m.RefCount, &m.CoverCount); err != nil {
+			return nil, err
+		}
+		m.RefCount += m.CoverCount
+		media = append(media, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ptrs := make([]*models.Media, len(media))
+	for i := range media {
+		ptrs[i] = &media[i]
+	}
+	if err := attachRenditions(db, ptrs); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+func (db *DB) GetMediaByHash(hash string) (*models.Media, error) {
+	m, err := scanMedia(db.QueryRow(
+		"SELECT "+mediaColumns+" FROM media m WHERE m.hash = ?", hash))
+	if err != nil {
+		return nil, err
+	}
+	if err := attachRenditions(db, []*models.Media{&m}); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (db *DB) CreateMedia(m *models.Media) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM media WHERE hash = ?)", m.Hash).Scan(&exists); err != nil {
+		return err
+	}
+	if err := insertMedia(tx, m); err != nil {
+		return err
+	}
+	if !exists {
+		if err := db.audit(tx, "media.upload", "media", m.ID, nil, mediaAudit(m)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *DB) AttachMedia(projectID int64, mediaIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = ? AND deleted_at IS NULL)",
+		projectID).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	images := make([]models.Image, len(mediaIDs))
+	for i, id := range mediaIDs {
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM media WHERE id = ?)",
+			id).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return sql.ErrNoRows
+		}
+		images[i].Media.ID = id
+		images[i].CreatedAt = time.Now()
+	}
+
+	if err := addProjectImages(tx, projectID, images); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "project.attach_media", "project", projectID,
+		nil, auditFields{"media_ids": mediaIDs}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteMedia(id int64) (*models.Media, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m, err := scanMedia(tx.QueryRow(
+		"SELECT "+mediaColumns+" FROM media m WHERE m.id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := deleteOrphanedMedia(tx, []models.Media{m})
+	if err != nil {
+		return nil, err
+	}
+	if len(orphans) == 0 {
+		return nil, ErrMediaInUse
+	}
+	if err := db.audit(tx, "media.delete", "media", id, mediaAudit(&m), nil); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &orphans[0], nil
+}
@@ -0,0 +1,62 @@
+// internal/db/media_test.go
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestMediaUsedAsTagCover(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := &models.Project{Title: "Pier", Date: time.Now(), Tags: []string{"Night"}}
+		if err := s.CreateProject(p, []models.Image{testImage("cover")}); err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := p.Images[0]
+
+		tag, err := s.GetTagByName("Night")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag.CoverMediaID = &img.Media.ID
+		if err := s.UpdateTag(tag); err != nil {
+			t.Fatal(err)
+		}
+
+		// Detaching the image leaves the media in place for the cover
+		orphan, err := s.DeleteImage(p.ID, img.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orphan != nil {
+			t.Fatalf("DeleteImage removed media %d still used as a tag cover", orphan.ID)
+		}
+
+		media, err := s.ListMedia()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(media) != 1 || media[0].RefCount != 1 || media[0].CoverCount != 1 {
+			t.Fatalf("ListMedia = %+v; want one item used once, as a cover", media)
+		}
+		if _, err := s.DeleteMedia(img.Media.ID); err != db.ErrMediaInUse {
+			t.Fatalf("DeleteMedia of a cover error = %v; want ErrMediaInUse", err)
+		}
+
+		got, err := s.GetTag(tag.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cover == nil || got.Cover.ID != img.Media.ID {
+			t.Fatalf("tag cover = %+v; want media %d", got.Cover, img.Media.ID)
+		}
+	})
+}
@@ -0,0 +1,2171 @@
+// internal/db/memory.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// MemoryStore is an in-memory Store for exercising handlers without SQLite.
+// It mirrors the ordering and not-found behaviour of *DB.
+type MemoryStore struct {
+	*memoryData
+	// actor is recorded in the audit log, as for *DB
+	actor *models.Actor
+}
+
+// memoryData is the state shared by a MemoryStore and the copies As returns
+type memoryData struct {
+	mu       sync.Mutex
+	nextID   int64
+	projects map[int64]*models.Project
+	media    map[int64]*models.Media
+	config   models.SiteConfig
+	sessions map[int64]models.Session
+	users    map[int64]models.User
+	invites  map[int64]models.Invite
+	resets   map[int64]models.PasswordReset
+	recovery map[int64]memoryRecoveryCode
+	devices  map[int64]models.TrustedDevice
+	logins   []models.LoginAttempt
+	auditLog []models.AuditEntry
+	views    []models.PageView
+	salts    map[string]string
+
+	// revisions holds project revisions in the order they were saved
+	revisions []models.ProjectRevision
+	// tags holds every tag some project carries, by ID
+	tags       map[int64]*models.Tag
+	categories map[int64]*models.Category
+}
+
+// NewMemoryStore returns an empty MemoryStore with a default site config
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryData: &memoryData{
+		projects: make(map[int64]*models.Project),
+		media:    make(map[int64]*models.Media),
+		config:   models.SiteConfig{ID: 1, ThemeName: "default"},
+		sessions: make(map[int64]models.Session),
+		users:    make(map[int64]models.User),
+		invites:  make(map[int64]models.Invite),
+		resets:   make(map[int64]models.PasswordReset),
+		recovery: make(map[int64]memoryRecoveryCode),
+		devices:  make(map[int64]models.TrustedDevice),
+		salts:    make(map[string]string),
+		tags:     make(map[int64]*models.Tag),
+
+		categories: make(map[int64]*models.Category),
+	}}
+}
+
+func (m *MemoryStore) newID() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+// AddUser seeds a user account and returns it with its assigned ID
+func (m *MemoryStore) AddUser(u models.User) models.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u.ID = m.newID()
+	m.users[u.ID] = u
+	return u
+}
+
+// copyProject returns a copy of p that shares no slices with the store,
+// with the name of the user who last edited it
+func (m *MemoryStore) copyProject(p *models.Project) models.Project {
+	c := *p
+	c.UpdatedByName = ""
+	if p.UpdatedBy != nil {
+		c.UpdatedByName = m.users[*p.UpdatedBy].Username
+	}
+	c.Tags = append([]string(nil), p.Tags...)
+	c.Images = append([]models.Image(nil), p.Images...)
+	return c
+}
+
+// categoryRank is the position of the category named tag, after every
+// category if there is none
+func (m *MemoryStore) categoryRank(tag string) int {
+	for _, c := range m.categories {
+		if strings.EqualFold(c.Name, tag) {
+			return c.Position
+		}
+	}
+	return 1 << 30
+}
+
+func (m *MemoryStore) sortTags(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		ri, rj := m.categoryRank(tags[i]), m.categoryRank(tags[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return tags[i] < tags[j]
+	})
+}
+
+func (m *MemoryStore) listWhere(order models.ProjectOrder, keep func(*models.Project) bool) []models.Project {
+	var projects []models.Project
+	for _, p := range m.projects {
+		if p.DeletedAt == nil && keep(p) {
+			projects = append(projects, m.copyProject(p))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projectLess(order, &projects[i], &projects[j])
+	})
+	return projects
+}
+
+// projectLess mirrors the SQL project orderings, with the ID as a final tie
+// break to keep listings stable
+func projectLess(order models.ProjectOrder, a, b *models.Project) bool {
+	switch order {
+	case models.OrderDate:
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+	case models.OrderFeatured:
+		if a.Featured != b.Featured {
+			return a.Featured
+		}
+		if !a.Featured && !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+	}
+	if a.SortOrder != b.SortOrder {
+		return a.SortOrder < b.SortOrder
+	}
+	if !a.Date.Equal(b.Date) {
+		return a.Date.After(b.Date)
+	}
+	return a.ID < b.ID
+}
+
+func (m *MemoryStore) ListProjects() ([]models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listWhere(models.OrderManual, func(*models.Project) bool { return true }), nil
+}
+
+func (m *MemoryStore) ListPublishedProjects(order models.ProjectOrder) ([]models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.listWhere(order, func(p *models.Project) bool {
+		return p.Status == models.StatusPublished
+	}), nil
+}
+
+func (m *MemoryStore) ListProjectsByTag(tag string, order models.ProjectOrder, limit, offset int) ([]models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := m.subtagNames(tag)
+	projects := m.listWhere(order, func(p *models.Project) bool {
+		return p.Status == models.StatusPublished && hasAnyTag(p, names)
+	})
+	if offset >= len(projects) {
+		return nil, nil
+	}
+	projects = projects[offset:]
+	if limit > 0 && len(projects) > limit {
+		projects = projects[:limit]
+	}
+	return projects, nil
+}
+
+// subtagNames returns the name of the tag matching name and of every tag
+// beneath it, or just name when there is no such tag
+func (m *MemoryStore) subtagNames(name string) []string {
+	names := []string{name}
+	ids := make(map[int64]bool)
+	for _, t := range m.tags {
+		if strings.EqualFold(t.Name, name) {
+			ids[t.ID] = true
+		}
+	}
+	for added := true; added; {
+		added = false
+		for _, t := range m.tags {
+			if t.ParentID != nil && ids[*t.ParentID] && !ids[t.ID] {
+				ids[t.ID] = true
+				names = append(names, t.Name)
+				added = true
+			}
+		}
+	}
+	return names
+}
+
+// hasAnyTag reports whether p carries one of names
+func hasAnyTag(p *models.Project, names []string) bool {
+	for _, name := range names {
+		if hasTag(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag reports whether p carries tag, matched case-insensitively
+func hasTag(p *models.Project, tag string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) SearchProjects(search models.ProjectSearch) ([]models.SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	terms := searchTerms(search.Query)
+	var tagNames []string
+	if search.Tag != "" {
+		tagNames = m.subtagNames(search.Tag)
+	}
+	var filters [][]string
+	for _, tag := range search.Tags {
+		filters = append(filters, m.subtagNames(tag))
+	}
+	projects := m.listWhere(search.Order, func(p *models.Project) bool {
+		if search.Listed && p.Status != models.StatusPublished {
+			return false
+		}
+		if search.Status != "" && p.Status != search.Status {
+			return false
+		}
+		if search.Year != 0 && p.Date.Year() != search.Year {
+			return false
+		}
+		if search.Tag != "" && !hasAnyTag(p, tagNames) {
+			return false
+		}
+		if len(filters) > 0 {
+			matched := 0
+			for _, names := range filters {
+				if hasAnyTag(p, names) {
+					matched++
+				}
+			}
+			if matched == 0 || (search.MatchAll && matched < len(filters)) {
+				return false
+			}
+		}
+		return matchesAll(terms, p.Title, p.Description, strings.Join(p.Tags, " "))
+	})
+
+	results := make([]models.SearchResult, len(projects))
+	for i := range projects {
+		results[i].Project = projects[i]
+		if len(terms) > 0 {
+			results[i].Snippet = snippet(&projects[i], terms)
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) RecentProjects(limit int) ([]models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	projects := m.listWhere(models.OrderManual, func(*models.Project) bool { return true })
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].CreatedAt.After(projects[j].CreatedAt)
+	})
+	if len(projects) > limit {
+		projects = projects[:limit]
+	}
+	for i := range projects {
+		projects[i].Tags, projects[i].Images = nil, nil
+	}
+	return projects, nil
+}
+
+func (m *MemoryStore) GetProject(id int64) (*models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok || p.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	c := m.copyProject(p)
+	return &c, nil
+}
+
+func (m *MemoryStore) GetProjectBySlug(slug string) (*models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.projects {
+		if p.Slug == slug && p.DeletedAt == nil {
+			c := m.copyProject(p)
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) ProjectIDBySlug(slug string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.projects {
+		if p.Slug == slug && p.DeletedAt == nil {
+			return p.ID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (m *MemoryStore) uniqueSlug(base string, excludeID int64) string {
+	slug := base
+	for i := 2; ; i++ {
+		taken := false
+		for _, p := range m.projects {
+			if p.Slug == slug && p.ID != excludeID {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return slug
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// store saves p along with its deduplicated tags and attached images
+func (m *MemoryStore) store(p *models.Project, images []models.Image) {
+	var tags []string
+	for _, t := range p.Tags {
+		dup := false
+		for _, existing := range tags {
+			if strings.EqualFold(existing, t) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			tags = append(tags, m.tagNamed(t).Name)
+		}
+	}
+	m.sortTags(tags)
+	p.Tags = tags
+
+	for _, img := range images {
+		if img.Media.ID == 0 {
+			img.Media = *m.addMedia(img.Media)
+		}
+		if m.attached(p, img.Media.ID) {
+			continue
+		}
+		img.ID = m.newID()
+		img.ProjectID = p.ID
+		img.Position = len(p.Images)
+		p.Images = append(p.Images, img)
+	}
+
+	c := m.copyProject(p)
+	m.projects[p.ID] = &c
+	m.pruneTags()
+}
+
+func (m *MemoryStore) CreateProject(p *models.Project, images []models.Image) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.ID = m.newID()
+	p.Slug = m.uniqueSlug(slugBase(p), p.ID)
+	if p.Status == "" {
+		p.Status = models.StatusPublished
+	}
+	p.SortOrder = 0
+	for _, existing := range m.projects {
+		if existing.SortOrder <= p.SortOrder {
+			p.SortOrder = existing.SortOrder - 1
+		}
+	}
+	p.Images = nil
+	m.store(p, images)
+	return m.audit("project.create", "project", p.ID, nil, projectChange(p, len(p.Images)))
+}
+
+func (m *MemoryStore) UpdateProject(p *models.Project, images []models.Image) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.projects[p.ID]
+	if !ok || existing.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	p.Slug = m.uniqueSlug(slugBase(p), p.ID)
+	if p.Status == "" {
+		p.Status = models.StatusPublished
+	}
+	p.CreatedAt = existing.CreatedAt
+	p.SortOrder = existing.SortOrder
+	p.Images = append([]models.Image(nil), existing.Images...)
+	m.store(p, images)
+
+	before := projectAudit(existing)
+	after := projectChange(p, len(p.Images)-len(existing.Images))
+	if _, _, changed := diffFields(before, after); changed {
+		m.saveRevision(existing)
+	}
+	return m.audit("project.update", "project", p.ID, before, after)
+}
+
+func (m *MemoryStore) ReorderProjects(projectIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range projectIDs {
+		if p, ok := m.projects[id]; !ok || p.DeletedAt != nil {
+			return sql.ErrNoRows
+		}
+	}
+	oldOrder := make(map[int64]int, len(projectIDs))
+	for position, id := range projectIDs {
+		p := m.projects[id]
+		oldOrder[id] = p.SortOrder
+		p.SortOrder = position
+	}
+	return m.audit("project.reorder", "project", 0,
+		auditFields{"order": previousOrder(projectIDs, oldOrder)}, auditFields{"order": projectIDs})
+}
+
+// saveRevision records p as replaced by a save
+func (m *MemoryStore) saveRevision(p *models.Project) {
+	m.revisions = append(m.revisions, models.ProjectRevision{
+		ID:          m.newID(),
+		ProjectID:   p.ID,
+		Title:       p.Title,
+		Slug:        p.Slug,
+		Description: p.Description,
+		VideoEmbed:  p.VideoEmbed,
+		Date:        p.Date,
+		Tags:        append([]string{}, p.Tags...),
+		ImageIDs:    m.imageOrder(p),
+		SavedAt:     p.UpdatedAt,
+		SavedBy:     p.UpdatedBy,
+		ReplacedAt:  time.Now(),
+	})
+}
+
+func (m *MemoryStore) ListProjectRevisions(projectID int64) ([]models.ProjectRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revisions []models.ProjectRevision
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if rev := m.revisions[i]; rev.ProjectID == projectID {
+			rev.Tags = append([]string(nil), rev.Tags...)
+			rev.ImageIDs = append([]int64(nil), rev.ImageIDs...)
+			if rev.SavedBy != nil {
+				rev.SavedByName = m.users[*rev.SavedBy].Username
+			}
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+func (m *MemoryStore) RestoreProjectRevision(projectID, revisionID int64, updatedAt time.Time, updatedBy *int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rev *models.ProjectRevision
+	for i := range m.revisions {
+		if m.revisions[i].ID == revisionID && m.revisions[i].ProjectID == projectID {
+			rev = &m.revisions[i]
+		}
+	}
+	existing, ok := m.projects[projectID]
+	if rev == nil || !ok || existing.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	before := m.imageOrder(existing)
+	after := restoredOrder(rev.ImageIDs, before)
+	position := make(map[int64]int, len(after))
+	for i, id := range after {
+		position[id] = i
+	}
+
+	p := m.copyProject(existing)
+	p.Title = rev.Title
+	p.Description = rev.Description
+	p.VideoEmbed = rev.VideoEmbed
+	p.Date = rev.Date
+	p.Tags = append([]string(nil), rev.Tags...)
+	p.Slug = rev.Slug
+	p.Slug = m.uniqueSlug(slugBase(&p), projectID)
+	p.UpdatedAt = updatedAt
+	p.UpdatedBy = updatedBy
+	for i := range p.Images {
+		p.Images[i].Position = position[p.Images[i].ID]
+	}
+	sort.SliceStable(p.Images, func(i, j int) bool {
+		return p.Images[i].Position < p.Images[j].Position
+	})
+
+	old, restored := projectAudit(existing), projectAudit(&p)
+	old["images"], restored["images"] = before, after
+	restored["revision_id"] = revisionID
+	m.saveRevision(existing)
+	m.store(&p, nil)
+	return m.audit("project.revert", "project", projectID, old, restored)
+}
+
+func (m *MemoryStore) DeleteProject(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok || p.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	p.DeletedAt = &now
+	return m.audit("project.delete", "project", id, projectAudit(p), nil)
+}
+
+func (m *MemoryStore) PublishScheduledProjects(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	published := 0
+	for _, p := range m.projects {
+		if p.DeletedAt != nil || p.Status != models.StatusScheduled ||
+			p.PublishAt == nil || p.PublishAt.After(now) {
+			continue
+		}
+		before := projectAudit(p)
+		p.Status, p.PublishAt = models.StatusPublished, nil
+		if err := m.audit("project.publish", "project", p.ID, before, projectAudit(p)); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+func (m *MemoryStore) ListDeletedProjects() ([]models.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var projects []models.Project
+	for _, p := range m.projects {
+		if p.DeletedAt != nil {
+			projects = append(projects, m.copyProject(p))
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if !projects[i].DeletedAt.Equal(*projects[j].DeletedAt) {
+			return projects[i].DeletedAt.After(*projects[j].DeletedAt)
+		}
+		return projects[i].ID > projects[j].ID
+	})
+	return projects, nil
+}
+
+func (m *MemoryStore) RestoreProject(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok || p.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	p.DeletedAt = nil
+	return m.audit("project.restore", "project", id, nil, projectAudit(p))
+}
+
+func (m *MemoryStore) PurgeProject(id int64) ([]models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[id]
+	if !ok || p.DeletedAt == nil {
+		return nil, sql.ErrNoRows
+	}
+	return m.purgeProject(p)
+}
+
+func (m *MemoryStore) PurgeDeletedProjects(before time.Time) ([]models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var orphans []models.Media
+	for _, p := range m.projects {
+		if p.DeletedAt == nil || !p.DeletedAt.Before(before) {
+			continue
+		}
+		media, err := m.purgeProject(p)
+		if err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, media...)
+	}
+	return orphans, nil
+}
+
+func (m *MemoryStore) purgeProject(p *models.Project) ([]models.Media, error) {
+	delete(m.projects, p.ID)
+	m.pruneTags()
+	kept := m.revisions[:0]
+	for _, rev := range m.revisions {
+		if rev.ProjectID != p.ID {
+			kept = append(kept, rev)
+		}
+	}
+	m.revisions = kept
+
+	var orphans []models.Media
+	for _, img := range p.Images {
+		if media := m.deleteIfOrphaned(img.Media.ID); media != nil {
+			orphans = append(orphans, *media)
+		}
+	}
+	return orphans, m.audit("project.purge", "project", p.ID, projectAudit(p), nil)
+}
+
+func (m *MemoryStore) findImage(projectID, imageID int64) (*models.Project, int, error) {
+	p, ok := m.projects[projectID]
+	if !ok {
+		return nil, 0, sql.ErrNoRows
+	}
+	for i := range p.Images {
+		if p.Images[i].ID == imageID {
+			return p, i, nil
+		}
+	}
+	return nil, 0, sql.ErrNoRows
+}
+
+func (m *MemoryStore) ReorderImages(projectID int64, imageIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[projectID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	before := m.imageOrder(p)
+	if !sameImages(before, imageIDs) {
+		return ErrImageOrder
+	}
+	for position, id := range imageIDs {
+		_, i, _ := m.findImage(projectID, id)
+		p.Images[i].Position = position
+	}
+	sort.SliceStable(p.Images, func(i, j int) bool {
+		return p.Images[i].Position < p.Images[j].Position
+	})
+	return m.audit("project.reorder_images", "project", projectID,
+		auditFields{"images": before}, auditFields{"images": m.imageOrder(p)})
+}
+
+// imageOrder returns the IDs of a project's images in display order
+func (m *MemoryStore) imageOrder(p *models.Project) []int64 {
+	ids := []int64{}
+	for _, img := range p.Images {
+		ids = append(ids, img.ID)
+	}
+	return ids
+}
+
+func (m *MemoryStore) UpdateImage(img *models.Image) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, i, err := m.findImage(img.ProjectID, img.ID)
+	if err != nil {
+		return err
+	}
+	before := imageAudit(&p.Images[i])
+	p.Images[i].Caption = img.Caption
+	p.Images[i].AltText = img.AltText
+	return m.audit("image.update", "image", img.ID, before, imageAudit(&p.Images[i]))
+}
+
+func (m *MemoryStore) SetCoverImage(projectID, imageID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, _, err := m.findImage(projectID, imageID)
+	if err != nil {
+		return err
+	}
+	var previous int64
+	for i := range p.Images {
+		if p.Images[i].IsCover && (previous == 0 || p.Images[i].ID < previous) {
+			previous = p.Images[i].ID
+		}
+		p.Images[i].IsCover = p.Images[i].ID == imageID
+	}
+	return m.audit("project.set_cover", "project", projectID,
+		auditFields{"cover_image": previous}, auditFields{"cover_image": imageID})
+}
+
+func (m *MemoryStore) DeleteImage(projectID, imageID int64) (*models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, i, err := m.findImage(projectID, imageID)
+	if err != nil {
+		return nil, err
+	}
+	img := p.Images[i]
+	p.Images = append(p.Images[:i], p.Images[i+1:]...)
+	for j := i; j < len(p.Images); j++ {
+		p.Images[j].Position--
+	}
+	return m.deleteIfOrphaned(img.Media.ID), m.audit("image.delete", "image", imageID, imageAudit(&img), nil)
+}
+
+// addMedia stores media, or returns the media already stored with its hash
+func (m *MemoryStore) addMedia(media models.Media) *models.Media {
+	for _, existing := range m.media {
+		if existing.Hash == media.Hash {
+			return existing
+		}
+	}
+	media.ID = m.newID()
+	media.RefCount = 0
+	m.media[media.ID] = &media
+	return &media
+}
+
+func (m *MemoryStore) attached(p *models.Project, mediaID int64) bool {
+	for _, img := range p.Images {
+		if img.Media.ID == mediaID {
+			return true
+		}
+	}
+	return false
+}
+
+// refCount counts the projects the media is attached to and the tags using
+// it as their cover
+func (m *MemoryStore) refCount(mediaID int64) int {
+	count := m.coverCount(mediaID)
+	for _, p := range m.projects {
+		if m.attached(p, mediaID) {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *MemoryStore) coverCount(mediaID int64) int {
+	count := 0
+	for _, t := range m.tags {
+		if t.CoverMediaID != nil && *t.CoverMediaID == mediaID {
+			count++
+		}
+	}
+	return count
+}
+
+// deleteIfOrphaned removes media no project or tag cover uses and returns it
+func (m *MemoryStore) deleteIfOrphaned(mediaID int64) *models.Media {
+	media, ok := m.media[mediaID]
+	if !ok || m.refCount(mediaID) > 0 {
+		return nil
+	}
+	delete(m.media, mediaID)
+	return media
+}
+
+func (m *MemoryStore) ListMedia() ([]models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var media []models.Media
+	for _, item := range m.media {
+		c := *item
+		c.RefCount = m.refCount(c.ID)
+		c.CoverCount = m.coverCount(c.ID)
+		media = append(media, c)
+	}
+	sort.Slice(media, func(i, j int) bool {
+		if !media[i].CreatedAt.Equal(media[j].CreatedAt) {
+			return media[i].CreatedAt.After(media[j].CreatedAt)
+		}
+		return media[i].ID > media[j].ID
+	})
+	return media, nil
+}
+
+func (m *MemoryStore) GetMediaByHash(hash string) (*models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, item := range m.media {
+		if item.Hash == hash {
+			c := *item
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) CreateMedia(media *models.Media) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(m.media)
+	*media = *m.addMedia(*media)
+	if len(m.media) == count {
+		return nil
+	}
+	return m.audit("media.upload", "media", media.ID, nil, mediaAudit(media))
+}
+
+func (m *MemoryStore) AttachMedia(projectID int64, mediaIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[projectID]
+	if !ok || p.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	images := make([]models.Image, len(mediaIDs))
+	for i, id := range mediaIDs {
+		media, ok := m.media[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		images[i] = models.Image{CreatedAt: time.Now(), Media: *media}
+	}
+	m.store(p, images)
+	return m.audit("project.attach_media", "project", projectID,
+		nil, auditFields{"media_ids": mediaIDs})
+}
+
+func (m *MemoryStore) DeleteMedia(id int64) (*models.Media, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.media[id]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	media := m.deleteIfOrphaned(id)
+	if media == nil {
+		return nil, ErrMediaInUse
+	}
+	return media, m.audit("media.delete", "media", id, mediaAudit(media), nil)
+}
+
+// tagCounts counts the projects out of the trash carrying each tag, only
+// counting published projects if published is set
+func (m *MemoryStore) tagCounts(published bool) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range m.projects {
+		if p.DeletedAt != nil || (published && p.Status != models.StatusPublished) {
+			continue
+		}
+		for _, t := range p.Tags {
+			counts[t]++
+		}
+	}
+	return counts
+}
+
+func (m *MemoryStore) NavigationTags() ([]models.NavTag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	byName := make(map[string]*models.Tag)
+	for _, t := range m.tags {
+		names = append(names, t.Name)
+		byName[t.Name] = t
+	}
+	sort.Strings(names)
+	m.sortTags(names)
+
+	listed := m.tagCounts(true)
+	nodes := make([]navNode, len(names))
+	for i, name := range names {
+		t := byName[name]
+		nodes[i] = navNode{id: t.ID, tag: models.NavTag{Name: name, Slug: name}, listed: listed[name] > 0}
+		if t.ParentID != nil {
+			nodes[i].parentID = *t.ParentID
+		}
+		if c := m.categoryNamed(name); c != nil {
+			nodes[i].tag.Slug = c.Slug
+			nodes[i].hidden = !c.Visible
+		}
+	}
+	return navTree(nodes), nil
+}
+
+func (m *MemoryStore) CategoryCounts() ([]models.CategoryCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var categories []models.CategoryCount
+	for name, count := range m.tagCounts(false) {
+		categories = append(categories, models.CategoryCount{Name: name, Count: count})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		ri, rj := m.categoryRank(categories[i].Name), m.categoryRank(categories[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		if categories[i].Count != categories[j].Count {
+			return categories[i].Count > categories[j].Count
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+// tagNamed returns the tag matching name case-insensitively, creating it if
+// there is none
+func (m *MemoryStore) tagNamed(name string) *models.Tag {
+	for _, t := range m.tags {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	t := &models.Tag{ID: m.newID(), Name: name}
+	m.tags[t.ID] = t
+	return t
+}
+
+// pruneTags removes tags no project carries and no other tag sits beneath,
+// counting projects in the trash, keeping those given a description or cover
+func (m *MemoryStore) pruneTags() {
+	for pruned := true; pruned; {
+		pruned = false
+		for id, t := range m.tags {
+			if t.Description != "" || t.CoverMediaID != nil || m.tagInUse(t) {
+				continue
+			}
+			delete(m.tags, id)
+			pruned = true
+		}
+	}
+}
+
+// tagInUse reports whether a project carries t or a tag sits beneath it
+func (m *MemoryStore) tagInUse(t *models.Tag) bool {
+	for _, p := range m.projects {
+		if hasTag(p, t.Name) {
+			return true
+		}
+	}
+	for _, child := range m.tags {
+		if child.ParentID != nil && *child.ParentID == t.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTag returns a copy of t with its project count and cover
+func (m *MemoryStore) copyTag(t *models.Tag) models.Tag {
+	c := *t
+	c.ProjectCount = m.tagCounts(false)[t.Name]
+	c.ParentName = ""
+	if t.ParentID != nil {
+		if parent, ok := m.tags[*t.ParentID]; ok {
+			c.ParentName = parent.Name
+		}
+	}
+	c.Cover = nil
+	if t.CoverMediaID != nil {
+		if media, ok := m.media[*t.CoverMediaID]; ok {
+			cover := *media
+			c.Cover = &cover
+		}
+	}
+	return c
+}
+
+// renameTag replaces a tag name on every project carrying it, dropping the
+// old name where the project already has the new one
+func (m *MemoryStore) renameTag(old, name string) {
+	for _, p := range m.projects {
+		if !hasTag(p, old) {
+			continue
+		}
+		var tags []string
+		for _, t := range p.Tags {
+			if strings.EqualFold(t, old) {
+				t = name
+			}
+			if !containsFold(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		m.sortTags(tags)
+		p.Tags = tags
+	}
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) ListTags() ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	byName := make(map[string]*models.Tag)
+	for _, t := range m.tags {
+		names = append(names, t.Name)
+		byName[t.Name] = t
+	}
+	sort.Strings(names)
+	m.sortTags(names)
+
+	tags := make([]models.Tag, len(names))
+	for i, name := range names {
+		tags[i] = m.copyTag(byName[name])
+	}
+	return tags, nil
+}
+
+func (m *MemoryStore) GetTag(id int64) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := m.copyTag(t)
+	return &c, nil
+}
+
+func (m *MemoryStore) GetTagByName(name string) (*models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tags {
+		if strings.EqualFold(t.Name, name) {
+			c := m.copyTag(t)
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) UpdateTag(t *models.Tag) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.tags[t.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for _, other := range m.tags {
+		if other.ID != t.ID && strings.EqualFold(other.Name, t.Name) {
+			return ErrTagExists
+		}
+	}
+	if t.CoverMediaID != nil {
+		if _, ok := m.media[*t.CoverMediaID]; !ok {
+			return sql.ErrNoRows
+		}
+	}
+	if t.ParentID != nil {
+		parent, ok := m.tags[*t.ParentID]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		for _, name := range m.subtagNames(existing.Name) {
+			if strings.EqualFold(name, parent.Name) {
+				return ErrTagCycle
+			}
+		}
+	}
+
+	if err := m.renameTagCategory(existing.Name, t.Name); err != nil {
+		return err
+	}
+	before := tagAudit(existing)
+	m.renameTag(existing.Name, t.Name)
+	existing.Name, existing.Description, existing.CoverMediaID = t.Name, t.Description, t.CoverMediaID
+	existing.ParentID = t.ParentID
+	return m.audit("tag.update", "tag", t.ID, before, tagAudit(existing))
+}
+
+// renameTagCategory gives the category matching the tag named old, if any,
+// the tag's new name, or returns ErrCategoryExists if another category
+// already has it
+func (m *MemoryStore) renameTagCategory(old, name string) error {
+	c := m.categoryNamed(old)
+	if c == nil || old == name {
+		return nil
+	}
+	if other := m.categoryNamed(name); other != nil && other != c {
+		return ErrCategoryExists
+	}
+	before := categoryAudit(c)
+	c.Name = name
+	return m.audit("category.update", "category", c.ID, before, categoryAudit(c))
+}
+
+func (m *MemoryStore) MergeTags(sourceID, targetID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sourceID == targetID {
+		return errors.New("cannot merge a tag into itself")
+	}
+	source, ok := m.tags[sourceID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	target, ok := m.tags[targetID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if err := m.renameTagCategory(source.Name, target.Name); err != nil && err != ErrCategoryExists {
+		return err
+	}
+	before := tagAudit(target)
+	// A target anywhere beneath the source takes the source's place first,
+	// so the source's children can move beneath it without a cycle
+	if containsFold(m.subtagNames(source.Name), target.Name) {
+		target.ParentID = source.ParentID
+	}
+	m.renameTag(source.Name, target.Name)
+	for _, child := range m.tags {
+		if child.ParentID != nil && *child.ParentID == sourceID && child.ID != targetID {
+			child.ParentID = &targetID
+		}
+	}
+	delete(m.tags, sourceID)
+
+	if strings.TrimSpace(target.Description) == "" {
+		target.Description = source.Description
+	}
+	if target.CoverMediaID == nil {
+		target.CoverMediaID = source.CoverMediaID
+	}
+	if err := m.audit("tag.merge", "tag", sourceID, tagAudit(source),
+		auditFields{"merged_into": targetID, "name": target.Name}); err != nil {
+		return err
+	}
+	return m.audit("tag.update", "tag", targetID, before, tagAudit(target))
+}
+
+func (m *MemoryStore) DeleteTag(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tags[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for _, p := range m.projects {
+		var tags []string
+		for _, name := range p.Tags {
+			if !strings.EqualFold(name, t.Name) {
+				tags = append(tags, name)
+			}
+		}
+		p.Tags = tags
+	}
+	for _, child := range m.tags {
+		if child.ParentID != nil && *child.ParentID == id {
+			child.ParentID = t.ParentID
+		}
+	}
+	delete(m.tags, id)
+	return m.audit("tag.delete", "tag", id, tagAudit(t), nil)
+}
+
+// categoryNamed returns the category matching name case-insensitively, or
+// nil
+func (m *MemoryStore) categoryNamed(name string) *models.Category {
+	for _, c := range m.categories {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// copyCategory returns a copy of c with its project count
+func (m *MemoryStore) copyCategory(c *models.Category) models.Category {
+	copied := *c
+	copied.ProjectCount = 0
+	for name, count := range m.tagCounts(false) {
+		if strings.EqualFold(name, c.Name) {
+			copied.ProjectCount += count
+		}
+	}
+	return copied
+}
+
+// checkCategoryUnique returns ErrCategoryExists if a category other than id
+// has c's name or slug
+func (m *MemoryStore) checkCategoryUnique(id int64, c *models.Category) error {
+	for _, other := range m.categories {
+		if other.ID != id && (strings.EqualFold(other.Name, c.Name) || other.Slug == c.Slug) {
+			return ErrCategoryExists
+		}
+	}
+	return nil
+}
+
+// renameCategoryTag gives the tag named old the category's new name, or the
+// tag already named name its spelling, as for *DB
+func (m *MemoryStore) renameCategoryTag(old, name string) {
+	var from, to *models.Tag
+	for _, t := range m.tags {
+		if strings.EqualFold(t.Name, name) {
+			to = t
+		} else if strings.EqualFold(t.Name, old) {
+			from = t
+		}
+	}
+	if to == nil {
+		to = from
+	}
+	if to != nil {
+		m.renameTag(to.Name, name)
+		to.Name = name
+	}
+}
+
+func (m *MemoryStore) ListCategories() ([]models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var categories []models.Category
+	for _, c := range m.categories {
+		categories = append(categories, m.copyCategory(c))
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	return categories, nil
+}
+
+func (m *MemoryStore) GetCategory(id int64) (*models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.categories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := m.copyCategory(c)
+	return &copied, nil
+}
+
+func (m *MemoryStore) GetCategoryBySlug(slug string) (*models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.categories {
+		if c.Slug == slug {
+			copied := m.copyCategory(c)
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) CreateCategory(c *models.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.Slug = categorySlug(c)
+	if err := m.checkCategoryUnique(0, c); err != nil {
+		return err
+	}
+	c.Position = 0
+	for _, other := range m.categories {
+		if other.Position >= c.Position {
+			c.Position = other.Position + 1
+		}
+	}
+
+	c.ID = m.newID()
+	stored := *c
+	stored.ProjectCount = 0
+	m.categories[c.ID] = &stored
+	m.renameCategoryTag(c.Name, c.Name)
+	return m.audit("category.create", "category", c.ID, nil, categoryAudit(c))
+}
+
+func (m *MemoryStore) UpdateCategory(c *models.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.categories[c.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	c.Slug = categorySlug(c)
+	c.Position = existing.Position
+	if err := m.checkCategoryUnique(c.ID, c); err != nil {
+		return err
+	}
+
+	before := categoryAudit(existing)
+	m.renameCategoryTag(existing.Name, c.Name)
+	existing.Name, existing.Slug, existing.Visible = c.Name, c.Slug, c.Visible
+	return m.audit("category.update", "category", c.ID, before, categoryAudit(existing))
+}
+
+func (m *MemoryStore) ReorderCategories(categoryIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range categoryIDs {
+		if _, ok := m.categories[id]; !ok {
+			return sql.ErrNoRows
+		}
+	}
+	for position, id := range categoryIDs {
+		c := m.categories[id]
+		old := c.Position
+		c.Position = position
+		if err := m.audit("category.reorder", "category", id,
+			auditFields{"position": old}, auditFields{"position": position}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteCategory(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.categories[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.categories, id)
+	return m.audit("category.delete", "category", id, categoryAudit(c), nil)
+}
+
+func (m *MemoryStore) GetSiteConfig() (*models.SiteConfig, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.config
+	return &c, nil
+}
+
+func (m *MemoryStore) UpdateSiteConfig(config *models.SiteConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := configAudit(&m.config)
+	m.config = *config
+	m.config.ID = 1
+	return m.audit("settings.update", "settings", 1, before, configAudit(&m.config))
+}
+
+func (m *MemoryStore) CreateSession(session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session.ID = m.newID()
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *MemoryStore) GetSession(tokenHash string) (*models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range m.sessions {
+		if s.TokenHash == tokenHash && s.ExpiresAt.After(now) {
+			return &s, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) TouchSession(id int64, ip string, lastSeen, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[id]; ok {
+		s.IP = ip
+		s.LastSeenAt = lastSeen
+		s.ExpiresAt = expires
+		m.sessions[id] = s
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListUserSessions(userID int64) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var sessions []models.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.ExpiresAt.After(now) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) DeleteSession(tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if s.TokenHash == tokenHash {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeSession(userID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || s.UserID != userID {
+		return sql.ErrNoRows
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteUserSessions(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteUserSessions(userID)
+	return nil
+}
+
+func (m *MemoryStore) deleteUserSessions(userID int64) {
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func (m *MemoryStore) DeleteExpiredSessions(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if s.ExpiresAt.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+	for id, d := range m.devices {
+		if d.ExpiresAt.Before(now) {
+			delete(m.devices, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetUserByUsername(username string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if strings.EqualFold(u.Username, username) {
+			c := u
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetUser(id int64) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &u, nil
+}
+
+func (m *MemoryStore) ListUsers() ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []models.User
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+	})
+	return users, nil
+}
+
+func (m *MemoryStore) CreateUser(u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.insertUser(u); err != nil {
+		return err
+	}
+	return m.audit("user.create", "user", u.ID, nil, userAudit(u))
+}
+
+func (m *MemoryStore) insertUser(u *models.User) error {
+	for _, existing := range m.users {
+		if strings.EqualFold(existing.Username, u.Username) {
+			return ErrUsernameTaken
+		}
+	}
+	u.ID = m.newID()
+	m.users[u.ID] = *u
+	return nil
+}
+
+// checkOwnerRemains mirrors the SQLite guard against removing the last
+// active owner
+func (m *MemoryStore) checkOwnerRemains(id int64) error {
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if u.Role != models.RoleOwner || u.Disabled() {
+		return nil
+	}
+	for _, other := range m.users {
+		if other.ID != id && other.Role == models.RoleOwner && !other.Disabled() {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+func (m *MemoryStore) SetUserRole(id int64, role models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if role != models.RoleOwner {
+		if err := m.checkOwnerRemains(id); err != nil {
+			return err
+		}
+	}
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	before := userAudit(&u)
+	u.Role = role
+	m.users[id] = u
+	return m.audit("user.role", "user", id, before, userAudit(&u))
+}
+
+func (m *MemoryStore) SetUserDisabled(id int64, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	before := userAudit(&u)
+	u.DisabledAt = nil
+	if disabled {
+		if err := m.checkOwnerRemains(id); err != nil {
+			return err
+		}
+		now := time.Now()
+		u.DisabledAt = &now
+		m.deleteUserSessions(id)
+	}
+	m.users[id] = u
+
+	action := "user.enable"
+	if disabled {
+		action = "user.disable"
+	}
+	return m.audit(action, "user", id, before, userAudit(&u))
+}
+
+func (m *MemoryStore) SetUserPassword(id int64, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.setPassword(id, passwordHash); err != nil {
+		return err
+	}
+	return m.audit("user.password", "user", id, nil, nil)
+}
+
+func (m *MemoryStore) setPassword(id int64, passwordHash string) error {
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.PasswordHash = passwordHash
+	m.users[id] = u
+	m.deleteUserSessions(id)
+	m.deleteTrustedDevices(id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteUser(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkOwnerRemains(id); err != nil {
+		return err
+	}
+	u := m.users[id]
+	m.deleteUserSessions(id)
+	for inviteID, inv := range m.invites {
+		if inv.CreatedBy == id {
+			delete(m.invites, inviteID)
+		}
+	}
+	for resetID, reset := range m.resets {
+		if reset.UserID == id {
+			delete(m.resets, resetID)
+		}
+	}
+	m.deleteRecoveryCodes(id)
+	m.deleteTrustedDevices(id)
+	for _, p := range m.projects {
+		if p.UpdatedBy != nil && *p.UpdatedBy == id {
+			p.UpdatedBy = nil
+		}
+	}
+	delete(m.users, id)
+	return m.audit("user.delete", "user", id, userAudit(&u), nil)
+}
+
+func (m *MemoryStore) CreateInvite(inv *models.Invite) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv.ID = m.newID()
+	m.invites[inv.ID] = *inv
+	return m.audit("invite.create", "invite", inv.ID, nil, inviteAudit(inv))
+}
+
+func (m *MemoryStore) ListInvites() ([]models.Invite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var invites []models.Invite
+	for _, inv := range m.invites {
+		if inv.UsedAt == nil && inv.ExpiresAt.After(now) {
+			invites = append(invites, inv)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		if !invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+			return invites[i].CreatedAt.After(invites[j].CreatedAt)
+		}
+		return invites[i].ID > invites[j].ID
+	})
+	return invites, nil
+}
+
+func (m *MemoryStore) GetInvite(tokenHash string) (*models.Invite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.validInvite(tokenHash)
+}
+
+func (m *MemoryStore) validInvite(tokenHash string) (*models.Invite, error) {
+	for _, inv := range m.invites {
+		if inv.TokenHash == tokenHash && inv.UsedAt == nil && inv.ExpiresAt.After(time.Now()) {
+			return &inv, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) AcceptInvite(tokenHash string, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv, err := m.validInvite(tokenHash)
+	if err != nil {
+		return err
+	}
+	u.Role = inv.Role
+	if err := m.insertUser(u); err != nil {
+		return err
+	}
+	usedAt := u.CreatedAt
+	inv.UsedAt = &usedAt
+	m.invites[inv.ID] = *inv
+
+	after := userAudit(u)
+	after["invite_id"] = inv.ID
+	return m.audit("invite.accept", "user", u.ID, nil, after)
+}
+
+func (m *MemoryStore) DeleteInvite(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inv, ok := m.invites[id]
+	if !ok {
+		return nil
+	}
+	delete(m.invites, id)
+	return m.audit("invite.delete", "invite", id, inviteAudit(&inv), nil)
+}
+
+func (m *MemoryStore) CreatePasswordReset(reset *models.PasswordReset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, existing := range m.resets {
+		if existing.UserID == reset.UserID && existing.UsedAt == nil {
+			delete(m.resets, id)
+		}
+	}
+	reset.ID = m.newID()
+	m.resets[reset.ID] = *reset
+	return m.audit("user.reset_link", "user", reset.UserID,
+		nil, auditFields{"expires_at": reset.ExpiresAt})
+}
+
+func (m *MemoryStore) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.validReset(tokenHash)
+}
+
+func (m *MemoryStore) validReset(tokenHash string) (*models.PasswordReset, error) {
+	for _, reset := range m.resets {
+		if reset.TokenHash == tokenHash && reset.UsedAt == nil && reset.ExpiresAt.After(time.Now()) {
+			return &reset, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) ResetPassword(tokenHash, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reset, err := m.validReset(tokenHash)
+	if err != nil {
+		return err
+	}
+	if err := m.setPassword(reset.UserID, passwordHash); err != nil {
+		return err
+	}
+	now := time.Now()
+	reset.UsedAt = &now
+	m.resets[reset.ID] = *reset
+	return m.audit("user.password_reset", "user", reset.UserID, nil, nil)
+}
+
+// memoryRecoveryCode mirrors a recovery_codes row
+type memoryRecoveryCode struct {
+	userID   int64
+	codeHash string
+	used     bool
+}
+
+func (m *MemoryStore) EnableTOTP(userID int64, secret string, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.TOTPSecret = secret
+	u.TOTPLastStep = 0
+	m.users[userID] = u
+	m.replaceRecoveryCodes(userID, codeHashes)
+	return m.audit("user.totp_enable", "user", userID, nil, nil)
+}
+
+func (m *MemoryStore) DisableTOTP(userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[userID]; ok {
+		u.TOTPSecret = ""
+		u.TOTPLastStep = 0
+		m.users[userID] = u
+	}
+	m.deleteRecoveryCodes(userID)
+	m.deleteTrustedDevices(userID)
+	return m.audit("user.totp_disable", "user", userID, nil, nil)
+}
+
+func (m *MemoryStore) UseTOTPStep(userID, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[userID]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	m.users[userID] = u
+	return true, nil
+}
+
+func (m *MemoryStore) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replaceRecoveryCodes(userID, codeHashes)
+	return m.audit("user.recovery_codes", "user", userID, nil, nil)
+}
+
+func (m *MemoryStore) replaceRecoveryCodes(userID int64, codeHashes []string) {
+	m.deleteRecoveryCodes(userID)
+	for _, hash := range codeHashes {
+		m.recovery[m.newID()] = memoryRecoveryCode{userID: userID, codeHash: hash}
+	}
+}
+
+func (m *MemoryStore) deleteRecoveryCodes(userID int64) {
+	for id, c := range m.recovery {
+		if c.userID == userID {
+			delete(m.recovery, id)
+		}
+	}
+}
+
+func (m *MemoryStore) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, c := range m.recovery {
+		if c.userID == userID && c.codeHash == codeHash && !c.used {
+			c.used = true
+			m.recovery[id] = c
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) RecoveryCodesLeft(userID int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, c := range m.recovery {
+		if c.userID == userID && !c.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) CreateTrustedDevice(device *models.TrustedDevice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	device.ID = m.newID()
+	m.devices[device.ID] = *device
+	return nil
+}
+
+func (m *MemoryStore) IsTrustedDevice(userID int64, tokenHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, d := range m.devices {
+		if d.UserID == userID && d.TokenHash == tokenHash && d.ExpiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) deleteTrustedDevices(userID int64) {
+	for id, d := range m.devices {
+		if d.UserID == userID {
+			delete(m.devices, id)
+		}
+	}
+}
+
+func (m *MemoryStore) RecordLoginAttempt(attempt *models.LoginAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt.ID = m.newID()
+	m.logins = append(m.logins, *attempt)
+	return nil
+}
+
+func (m *MemoryStore) LoginFailures(username, ip string, since time.Time) (models.LoginFailures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var f models.LoginFailures
+	f.Username, f.LastUsername = m.failuresFor(func(a models.LoginAttempt) bool { return a.Username == username }, since)
+	f.IP, f.LastIP = m.failuresFor(func(a models.LoginAttempt) bool { return a.IP == ip }, since)
+	return f, nil
+}
+
+// failuresFor counts matching failures after since and after the latest
+// matching success. Attempts are stored oldest first.
+func (m *MemoryStore) failuresFor(match func(models.LoginAttempt) bool, since time.Time) (int, time.Time) {
+	count := 0
+	var last time.Time
+	for _, a := range m.logins {
+		if !match(a) || !a.CreatedAt.After(since) {
+			continue
+		}
+		if a.Success {
+			count, last = 0, time.Time{}
+			continue
+		}
+		count++
+		last = a.CreatedAt
+	}
+	return count, last
+}
+
+func (m *MemoryStore) RecentLoginAttempts(limit int) ([]models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attempts []models.LoginAttempt
+	for i := len(m.logins) - 1; i >= 0 && len(attempts) < limit; i-- {
+		attempts = append(attempts, m.logins[i])
+	}
+	return attempts, nil
+}
+
+func (m *MemoryStore) DeleteLoginAttemptsBefore(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.logins[:0]
+	for _, a := range m.logins {
+		if !a.CreatedAt.Before(t) {
+			kept = append(kept, a)
+		}
+	}
+	m.logins = kept
+	return nil
+}
+
+// As returns a MemoryStore sharing m's data that records actor in the audit
+// log
+func (m *MemoryStore) As(actor models.Actor) Store {
+	return &MemoryStore{memoryData: m.memoryData, actor: &actor}
+}
+
+// audit records a change; the caller holds m.mu
+func (m *MemoryStore) audit(action, entityType string, entityID int64, before, after auditFields) error {
+	entry, ok, err := newAuditEntry(m.actor, action, entityType, entityID, before, after)
+	if err != nil || !ok {
+		return err
+	}
+	entry.ID = m.newID()
+	m.auditLog = append(m.auditLog, *entry)
+	return nil
+}
+
+func (m *MemoryStore) ListAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []models.AuditEntry
+	for i := len(m.auditLog) - 1; i >= 0; i-- {
+		e := m.auditLog[i]
+		if (filter.Actor != "" && !strings.EqualFold(e.ActorName, filter.Actor)) ||
+			(filter.Action != "" && e.Action != filter.Action) ||
+			(filter.EntityType != "" && e.EntityType != filter.EntityType) ||
+			(filter.EntityID != 0 && e.EntityID != filter.EntityID) ||
+			(!filter.Since.IsZero() && e.CreatedAt.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if filter.Limit > 0 {
+		if filter.Offset >= len(entries) {
+			return nil, nil
+		}
+		entries = entries[filter.Offset:]
+		if len(entries) > filter.Limit {
+			entries = entries[:filter.Limit]
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) SavePageViews(views []models.PageView) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, view := range views {
+		view.ID = m.newID()
+		m.views = append(m.views, view)
+	}
+	return nil
+}
+
+func (m *MemoryStore) DailySalt(day string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	salt, ok := m.salts[day]
+	if !ok {
+		var err error
+		if salt, err = newSalt(); err != nil {
+			return "", err
+		}
+	}
+	m.salts = map[string]string{day: salt}
+	return salt, nil
+}
+
+// rankCounts orders counts by count descending, then by key, and keeps the
+// top analyticsTopN
+func rankCounts(counts map[string]int) []models.NameCount {
+	var ranked []models.NameCount
+	for name, count := range counts {
+		ranked = append(ranked, models.NameCount{Name: name, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > analyticsTopN {
+		ranked = ranked[:analyticsTopN]
+	}
+	return ranked
+}
+
+func (m *MemoryStore) GetAnalyticsSummary(days int) (*models.AnalyticsSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary := &models.AnalyticsSummary{
+		ProjectViews: make(map[int64]int),
+	}
+	cutoff := analyticsCutoff(days)
+
+	visitors := make(map[string]bool)
+	projects := make(map[int64]int)
+	pages := make(map[string]int)
+	referrers := make(map[string]int)
+	browsers := make(map[string]int)
+	platforms := make(map[string]int)
+	daily := make(map[string]int)
+	for _, v := range m.views {
+		if v.ViewedAt.Before(cutoff) {
+			continue
+		}
+		summary.TotalViews++
+		if v.IPHash != "" {
+			visitors[v.IPHash] = true
+		}
+		if v.ProjectID != nil {
+			projects[*v.ProjectID]++
+		}
+		pages[v.PagePath]++
+		if v.Referrer != "" {
+			referrers[v.Referrer]++
+		}
+		if v.Browser != "" {
+			browsers[v.Browser]++
+		}
+		if v.Platform != "" {
+			platforms[v.Platform]++
+		}
+		daily[v.ViewedAt.UTC().Format("2006-01-02")]++
+	}
+	summary.UniqueViews = len(visitors)
+
+	var projectIDs []int64
+	for id := range projects {
+		projectIDs = append(projectIDs, id)
+	}
+	sort.Slice(projectIDs, func(i, j int) bool {
+		ci, cj := projects[projectIDs[i]], projects[projectIDs[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return projectIDs[i] < projectIDs[j]
+	})
+	if len(projectIDs) > analyticsTopN {
+		projectIDs = projectIDs[:analyticsTopN]
+	}
+	for _, id := range projectIDs {
+		summary.ProjectViews[id] = projects[id]
+	}
+	for _, c := range rankCounts(pages) {
+		summary.TopPages = append(summary.TopPages, models.PageCount{Path: c.Name, Count: c.Count})
+	}
+	for _, c := range rankCounts(referrers) {
+		summary.TopReferrers = append(summary.TopReferrers, models.ReferrerCount{Referrer: c.Name, Count: c.Count})
+	}
+	summary.Browsers = rankCounts(browsers)
+	summary.Platforms = rankCounts(platforms)
+
+	var dates []string
+	for date := range daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		t, _ := time.Parse("2006-01-02", date)
+		summary.DailyViews = append(summary.DailyViews, models.DailyViews{Date: t, Count: daily[date]})
+	}
+	return summary, nil
+}
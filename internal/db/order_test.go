@@ -0,0 +1,105 @@
+// internal/db/order_test.go
+package db_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func projectIDs(projects []models.Project) []int64 {
+	var ids []int64
+	for _, p := range projects {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestReorderProjects(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		a := dbtest.CreateProject(t, s, "Night Drive")
+		b := dbtest.CreateProject(t, s, "Harbour")
+		c := dbtest.CreateProject(t, s, "Pier")
+
+		order := []int64{b.ID, c.ID, a.ID}
+		if err := s.ReorderProjects(order); err != nil {
+			t.Fatal(err)
+		}
+		projects, err := s.ListPublishedProjects(models.OrderManual)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := projectIDs(projects); !equalIDs(got, order) {
+			t.Errorf("manual order = %v; want %v", got, order)
+		}
+
+		entries, err := s.ListAuditLog(models.AuditFilter{Action: "project.reorder"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("got %d project.reorder entries; want 1 for the whole reorder", len(entries))
+		}
+		var after struct{ Order []int64 }
+		if err := json.Unmarshal([]byte(entries[0].After), &after); err != nil {
+			t.Fatal(err)
+		}
+		if !equalIDs(after.Order, order) {
+			t.Errorf("audited order = %v; want %v", after.Order, order)
+		}
+
+		if err := s.ReorderProjects([]int64{a.ID, c.ID + 100}); err != sql.ErrNoRows {
+			t.Errorf("reordering a missing project error = %v; want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestThemeOrders(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		// Dated out of manual order, with a and b featured
+		project := func(title string, month time.Month, featured bool) *models.Project {
+			p := dbtest.CreateProject(t, s, title, "Film")
+			p.Date = time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC)
+			p.Featured = featured
+			if err := s.UpdateProject(p, nil); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+		a := project("Night Drive", time.January, true)
+		b := project("Harbour", time.March, true)
+		c := project("Pier", time.February, false)
+		d := project("Dunes", time.April, false)
+		if err := s.ReorderProjects([]int64{c.ID, a.ID, d.ID, b.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[models.ProjectOrder][]int64{
+			models.OrderManual: {c.ID, a.ID, d.ID, b.ID},
+			models.OrderDate:   {d.ID, b.ID, c.ID, a.ID},
+			// Featured projects pinned first in manual order, the rest by date
+			models.OrderFeatured: {a.ID, b.ID, d.ID, c.ID},
+		}
+		for name, theme := range models.AvailableThemes {
+			home, err := s.ListPublishedProjects(theme.HomeOrder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := projectIDs(home); !equalIDs(got, want[theme.HomeOrder]) {
+				t.Errorf("%s homepage (%s) = %v; want %v", name, theme.HomeOrder, got, want[theme.HomeOrder])
+			}
+			tagged, err := s.ListProjectsByTag("Film", theme.TagOrder, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := projectIDs(tagged); !equalIDs(got, want[theme.TagOrder]) {
+				t.Errorf("%s tag page (%s) = %v; want %v", name, theme.TagOrder, got, want[theme.TagOrder])
+			}
+		}
+	})
+}
@@ -0,0 +1,650 @@
+// internal/db/projects.go
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"voidcase/internal/models"
+	"voidcase/internal/utils"
+)
+
+const projectColumns = `p.id, p.title, COALESCE(p.slug, ''), COALESCE(p.description, ''),
+               COALESCE(p.video_embed, ''), p.date, p.created_at, p.updated_at,
+               p.updated_by, COALESCE(u.username, ''), p.deleted_at, p.status, p.publish_at,
+               p.sort_order, p.featured`
+
+// projectFrom joins the user who last edited each project
+const projectFrom = `projects p LEFT JOIN users u ON u.id = p.updated_by`
+
+// projectLive excludes projects in the trash
+const projectLive = `p.deleted_at IS NULL`
+
+// projectListed selects the projects listed on the public site
+const projectListed = projectLive + ` AND p.status = 'published'`
+
+// projectOrders are the ORDER BY clauses of each project ordering
+var projectOrders = map[models.ProjectOrder]string{
+	models.OrderManual:   `p.sort_order, p.date DESC`,
+	models.OrderDate:     `p.date DESC, p.sort_order`,
+	models.OrderFeatured: `p.featured DESC, CASE WHEN p.featured THEN p.sort_order END, p.date DESC, p.sort_order`,
+}
+
+// orderBy returns the ORDER BY clause for order, falling back to manual
+// order for unknown values
+func orderBy(order models.ProjectOrder) string {
+	if clause, ok := projectOrders[order]; ok {
+		return clause
+	}
+	return projectOrders[models.OrderManual]
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProject(row rowScanner) (models.Project, error) {
+	var p models.Project
+	err := row.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.VideoEmbed,
+		&p.Date, &p.CreatedAt, &p.UpdatedAt, &p.UpdatedBy, &p.UpdatedByName, &p.DeletedAt,
+		&p.Status, &p.PublishAt, &p.SortOrder, &p.Featured)
+	return p, err
+}
+
+// queryProjects runs a projects query and loads tags and images for the
+// results in two batched queries
+func (db *DB) queryProjects(query string, args ...interface{}) ([]models.Project, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := db.attachTagsAndImages(projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (db *DB) attachTagsAndImages(projects []models.Project) error {
+	if len(projects) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(projects))
+	args := make([]interface{}, len(projects))
+	for i, p := range projects {
+		index[p.ID] = i
+		args[i] = p.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(projects)), ",")
+
+	rows, err := db.Query(fmt.Sprintf(`
+        SELECT pt.project_id, t.name
+        FROM tags t
+        JOIN project_tags pt ON t.id = pt.tag_id
+        WHERE pt.project_id IN (%s)
+        ORDER BY %s, t.name`, placeholders, categoryOrder), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var projectID int64
+		var tag string
+		if err := rows.Scan(&projectID, &tag); err != nil {
+			return err
+		}
+		p := &projects[index[projectID]]
+		p.Tags = append(p.Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	images, err := queryImages(db, fmt.Sprintf(`
+        SELECT `+imageColumns+`
+        FROM `+imageFrom+`
+        WHERE pm.project_id IN (%s)
+        ORDER BY pm.position, pm.id`, placeholders), args...)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		p := &projects[index[img.ProjectID]]
+		p.Images = append(p.Images, img)
+	}
+	return nil
+}
+
+func (db *DB) ListProjects() ([]models.Project, error) {
+	return db.queryProjects(`
+        SELECT ` + projectColumns + `
+        FROM ` + projectFrom + `
+        WHERE ` + projectLive + `
+        ORDER BY ` + orderBy(models.OrderManual))
+}
+
+func (db *DB) ListPublishedProjects(order models.ProjectOrder) ([]models.Project, error) {
+	return db.queryProjects(`
+        SELECT ` + projectColumns + `
+        FROM ` + projectFrom + `
+        WHERE ` + projectListed + `
+        ORDER BY ` + orderBy(order))
+}
+
+func (db *DB) ListProjectsByTag(tag string, order models.ProjectOrder, limit, offset int) ([]models.Project, error) {
+	if limit <= 0 {
+		// SQLite reads a negative limit as no limit
+		limit = -1
+	}
+	return db.queryProjects(`
+        SELECT `+projectColumns+`
+        FROM `+projectFrom+`
+        WHERE `+taggedWith+` AND `+projectListed+`
+        ORDER BY `+orderBy(order)+`
+        LIMIT ? OFFSET ?`, tag, limit, offset)
+}
+
+func (db *DB) RecentProjects(limit int) ([]models.Project, error) {
+	rows, err := db.Query(`
+        SELECT `+projectColumns+`
+        FROM `+projectFrom+`
+        WHERE `+projectLive+`
+        ORDER BY p.created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func (db *DB) GetProject(id int64) (*models.Project, error) {
+	return db.getProjectWhere("p.id = ?", id)
+}
+
+func (db *DB) GetProjectBySlug(slug string) (*models.Project, error) {
+	return db.getProjectWhere("p.slug = ?", slug)
+}
+
+func (db *DB) ProjectIDBySlug(slug string) (int64, error) {
+	var id int64
+	err := db.QueryRow(`
+        SELECT p.id FROM projects p WHERE `+projectLive+` AND p.slug = ?`, slug).Scan(&id)
+	return id, err
+}
+
+func (db *DB) getProjectWhere(where string, arg interface{}) (*models.Project, error) {
+	p, err := scanProject(db.QueryRow(`
+        SELECT `+projectColumns+`
+        FROM `+projectFrom+` WHERE `+projectLive+` AND `+where, arg))
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []models.Project{p}
+	if err := db.attachTagsAndImages(projects); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// loadProject reads a project with its tags inside tx, whether or not it is
+// in the trash
+func loadProject(tx *sql.Tx, id int64) (models.Project, error) {
+	p, err := scanProject(tx.QueryRow(`
+        SELECT `+projectColumns+`
+        FROM `+projectFrom+` WHERE p.id = ?`, id))
+	if err != nil {
+		return p, err
+	}
+
+	rows, err := tx.Query(`
+        SELECT t.name FROM tags t
+        JOIN project_tags pt ON t.id = pt.tag_id
+        WHERE pt.project_id = ?
+        ORDER BY `+categoryOrder+`, t.name`, id)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return p, err
+		}
+		p.Tags = append(p.Tags, tag)
+	}
+	return p, rows.Err()
+}
+
+func (db *DB) CreateProject(p *models.Project, images []models.Image) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	p.Slug, err = UniqueProjectSlug(tx, slugBase(p), 0)
+	if err != nil {
+		return err
+	}
+	if p.Status == "" {
+		p.Status = models.StatusPublished
+	}
+	// New projects go to the top of the manual order
+	if err := tx.QueryRow(
+		"SELECT COALESCE(MIN(sort_order), 0) - 1 FROM projects").Scan(&p.SortOrder); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+        INSERT INTO projects (title, slug, description, video_embed, date, created_at, updated_at, updated_by,
+                              status, publish_at, sort_order, featured)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Title, p.Slug, p.Description, p.VideoEmbed, p.Date, p.CreatedAt, p.UpdatedAt, p.UpdatedBy,
+		p.Status, utcTime(p.PublishAt), p.SortOrder, p.Featured)
+	if err != nil {
+		return err
+	}
+	if p.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	if err := setProjectTags(tx, p.ID, p.Tags); err != nil {
+		return err
+	}
+	if err := addProjectImages(tx, p.ID, images); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "project.create", "project", p.ID, nil, projectChange(p, countAttached(images))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) UpdateProject(p *models.Project, images []models.Image) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := loadProject(tx, p.ID)
+	if err != nil {
+		return err
+	}
+	order, err := imageOrder(tx, p.ID)
+	if err != nil {
+		return err
+	}
+
+	p.Slug, err = UniqueProjectSlug(tx, slugBase(p), p.ID)
+	if err != nil {
+		return err
+	}
+	if p.Status == "" {
+		p.Status = models.StatusPublished
+	}
+
+	result, err := tx.Exec(`
+        UPDATE projects
+        SET title = ?, slug = ?, description = ?, video_embed = ?, date = ?, updated_at = ?, updated_by = ?,
+            status = ?, publish_at = ?, featured = ?
+        WHERE id = ? AND deleted_at IS NULL`,
+		p.Title, p.Slug, p.Description, p.VideoEmbed, p.Date, p.UpdatedAt, p.UpdatedBy,
+		p.Status, utcTime(p.PublishAt), p.Featured, p.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := setProjectTags(tx, p.ID, p.Tags); err != nil {
+		return err
+	}
+	if err := addProjectImages(tx, p.ID, images); err != nil {
+		return err
+	}
+
+	// Keep the replaced state as a revision unless the save changed nothing
+	before, after := projectAudit(&current), projectChange(p, countAttached(images))
+	if _, _, changed := diffFields(before, after); changed {
+		if err := saveRevision(tx, &current, order); err != nil {
+			return err
+		}
+	}
+	if err := db.audit(tx, "project.update", "project", p.ID, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) ReorderProjects(projectIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	oldOrder := make(map[int64]int, len(projectIDs))
+	for position, id := range projectIDs {
+		var old int
+		if err := tx.QueryRow(
+			"SELECT sort_order FROM projects WHERE id = ? AND deleted_at IS NULL", id).Scan(&old); err != nil {
+			return err
+		}
+		oldOrder[id] = old
+		if _, err := tx.Exec("UPDATE projects SET sort_order = ? WHERE id = ?", position, id); err != nil {
+			return err
+		}
+	}
+	if err := db.audit(tx, "project.reorder", "project", 0,
+		auditFields{"order": previousOrder(projectIDs, oldOrder)}, auditFields{"order": projectIDs}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// previousOrder returns projectIDs sorted by their sort order before a
+// reorder, for the audit log
+func previousOrder(projectIDs []int64, oldOrder map[int64]int) []int64 {
+	ids := append([]int64(nil), projectIDs...)
+	sort.SliceStable(ids, func(i, j int) bool { return oldOrder[ids[i]] < oldOrder[ids[j]] })
+	return ids
+}
+
+func (db *DB) DeleteProject(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := loadProjectAudit(tx, id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result, err := tx.Exec(
+		"UPDATE projects SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", utcTime(&now), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := db.audit(tx, "project.delete", "project", id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// utcTime stores optional times in UTC so SQLite compares them as text in
+// time order
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func (db *DB) PublishScheduledProjects(now time.Time) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+        SELECT id FROM projects
+        WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL`,
+		models.StatusScheduled, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		p, err := loadProject(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		before := projectAudit(&p)
+		if _, err := tx.Exec(
+			"UPDATE projects SET status = ?, publish_at = NULL WHERE id = ?",
+			models.StatusPublished, id); err != nil {
+			return 0, err
+		}
+		p.Status, p.PublishAt = models.StatusPublished, nil
+		if err := db.audit(tx, "project.publish", "project", id, before, projectAudit(&p)); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), tx.Commit()
+}
+
+func (db *DB) ListDeletedProjects() ([]models.Project, error) {
+	return db.queryProjects(`
+        SELECT ` + projectColumns + `
+        FROM ` + projectFrom + `
+        WHERE p.deleted_at IS NOT NULL
+        ORDER BY p.deleted_at DESC, p.id DESC`)
+}
+
+func (db *DB) RestoreProject(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	after, err := loadProjectAudit(tx, id)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		"UPDATE projects SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := db.audit(tx, "project.restore", "project", id, nil, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) PurgeProject(id int64) ([]models.Media, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var trashed bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM projects WHERE id = ? AND deleted_at IS NOT NULL)",
+		id).Scan(&trashed); err != nil {
+		return nil, err
+	}
+	if !trashed {
+		return nil, sql.ErrNoRows
+	}
+
+	orphans, err := db.purgeProject(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return orphans, tx.Commit()
+}
+
+func (db *DB) PurgeDeletedProjects(before time.Time) ([]models.Media, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"SELECT id FROM projects WHERE deleted_at IS NOT NULL AND deleted_at < ?", before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var orphans []models.Media
+	for _, id := range ids {
+		media, err := db.purgeProject(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, media...)
+	}
+	return orphans, tx.Commit()
+}
+
+// purgeProject removes a project for good and returns the media no other
+// project uses, with its renditions
+func (db *DB) purgeProject(tx *sql.Tx, id int64) ([]models.Media, error) {
+	before, err := loadProjectAudit(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	images, err := queryImages(tx,
+		"SELECT "+imageColumns+" FROM "+imageFrom+" WHERE pm.project_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete in order: tags, revisions, attachments, project, then any media
+	// no other project uses
+	if _, err := tx.Exec("DELETE FROM project_tags WHERE project_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM project_revisions WHERE project_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM project_media WHERE project_id = ?", id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := pruneTags(tx); err != nil {
+		return nil, err
+	}
+
+	media := make([]models.Media, len(images))
+	for i, img := range images {
+		media[i] = img.Media
+	}
+	orphans, err := deleteOrphanedMedia(tx, media)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, "project.purge", "project", id, before, nil); err != nil {
+		return nil, err
+	}
+	return orphans, nil
+}
+
+func slugBase(p *models.Project) string {
+	base := strings.TrimSpace(p.Slug)
+	if base == "" {
+		base = p.Title
+	}
+	return utils.Slugify(base)
+}
+
+// setProjectTags replaces a project's tags, creating any tags that do not
+// exist yet
+func setProjectTags(tx *sql.Tx, projectID int64, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM project_tags WHERE project_id = ?", projectID); err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		var tagID int64
+		err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", name).Scan(&tagID)
+		if err == sql.ErrNoRows {
+			result, err := tx.Exec("INSERT INTO tags (name) VALUES (?)", name)
+			if err != nil {
+				return err
+			}
+			if tagID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			"INSERT OR IGNORE INTO project_tags (project_id, tag_id) VALUES (?, ?)",
+			projectID, tagID); err != nil {
+			return err
+		}
+	}
+	return pruneTags(tx)
+}
@@ -0,0 +1,41 @@
+// internal/db/projects_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+)
+
+func TestProjectSlugs(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		first := dbtest.CreateProject(t, s, "Night Drive")
+		second := dbtest.CreateProject(t, s, "Night drive!")
+		if first.Slug != "night-drive" || second.Slug != "night-drive-2" {
+			t.Fatalf("slugs = %q, %q; want night-drive, night-drive-2", first.Slug, second.Slug)
+		}
+
+		got, err := s.GetProjectBySlug("night-drive-2")
+		if err != nil || got.ID != second.ID {
+			t.Fatalf("GetProjectBySlug = %v, %v; want project %d", got, err, second.ID)
+		}
+		if id, err := s.ProjectIDBySlug("night-drive"); err != nil || id != first.ID {
+			t.Fatalf("ProjectIDBySlug = %d, %v; want %d", id, err, first.ID)
+		}
+		if _, err := s.ProjectIDBySlug("missing"); err != sql.ErrNoRows {
+			t.Fatalf("ProjectIDBySlug(missing) error = %v; want sql.ErrNoRows", err)
+		}
+
+		// A slug set by hand is kept through renames
+		first.Title = "Night Drive Redux"
+		first.Slug = "redux"
+		if err := s.UpdateProject(first, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetProject(first.ID); err != nil || got.Slug != "redux" {
+			t.Fatalf("slug after update = %v, %v; want redux", got, err)
+		}
+	})
+}
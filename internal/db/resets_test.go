@@ -0,0 +1,64 @@
+// internal/db/resets_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestPasswordResets(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		ada := createUser(t, s, "ada")
+		grace := createUser(t, s, "grace")
+		now := time.Now()
+		reset := func(u *models.User, token string, expires time.Time) {
+			t.Helper()
+			r := &models.PasswordReset{UserID: u.ID, TokenHash: token, CreatedAt: now, ExpiresAt: expires}
+			if err := s.CreatePasswordReset(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reset(ada, "replaced", now.Add(time.Hour))
+		reset(ada, "current", now.Add(time.Hour))
+		reset(grace, "expired", now.Add(-time.Minute))
+
+		for _, token := range []string{"replaced", "expired", "unknown"} {
+			if _, err := s.GetPasswordReset(token); err != sql.ErrNoRows {
+				t.Errorf("GetPasswordReset(%s) error = %v; want sql.ErrNoRows", token, err)
+			}
+			if err := s.ResetPassword(token, "new"); err != sql.ErrNoRows {
+				t.Errorf("ResetPassword(%s) error = %v; want sql.ErrNoRows", token, err)
+			}
+		}
+
+		got, err := s.GetPasswordReset("current")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != ada.ID {
+			t.Errorf("reset belongs to user %d; want %d", got.UserID, ada.ID)
+		}
+		if err := s.ResetPassword("current", "new"); err != nil {
+			t.Fatal(err)
+		}
+		if u, _ := s.GetUser(ada.ID); u.PasswordHash != "new" {
+			t.Errorf("password hash = %q; want the reset's", u.PasswordHash)
+		}
+
+		// A link works once
+		if _, err := s.GetPasswordReset("current"); err != sql.ErrNoRows {
+			t.Errorf("GetPasswordReset after use error = %v; want sql.ErrNoRows", err)
+		}
+		if err := s.ResetPassword("current", "again"); err != sql.ErrNoRows {
+			t.Errorf("second ResetPassword error = %v; want sql.ErrNoRows", err)
+		}
+		if u, _ := s.GetUser(grace.ID); u.PasswordHash != "x" {
+			t.Errorf("expired link changed grace's password to %q", u.PasswordHash)
+		}
+	})
+}
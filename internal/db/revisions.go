@@ -0,0 +1,169 @@
+// internal/db/revisions.go
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// saveRevision records p, with its images in order, as replaced by a save
+// inside tx
+func saveRevision(tx *sql.Tx, p *models.Project, order []int64) error {
+	tags, err := json.Marshal(append([]string{}, p.Tags...))
+	if err != nil {
+		return err
+	}
+	imageIDs, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO project_revisions (project_id, title, slug, description, video_embed, date,
+                                       tags, image_ids, saved_at, saved_by, replaced_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.Title, p.Slug, p.Description, p.VideoEmbed, p.Date,
+		string(tags), string(imageIDs), p.UpdatedAt, p.UpdatedBy, time.Now())
+	return err
+}
+
+// restoredOrder puts the images of a revision that are still attached back in
+// their old order, followed by images attached since in their current order
+func restoredOrder(revision, current []int64) []int64 {
+	attached := make(map[int64]bool, len(current))
+	for _, id := range current {
+		attached[id] = true
+	}
+
+	order := make([]int64, 0, len(current))
+	for _, id := range revision {
+		if attached[id] {
+			order = append(order, id)
+			delete(attached, id)
+		}
+	}
+	for _, id := range current {
+		if attached[id] {
+			order = append(order, id)
+		}
+	}
+	return order
+}
+
+const revisionColumns = `r.id, r.project_id, r.title, r.slug, r.description, r.video_embed, r.date,
+               r.tags, r.image_ids, r.saved_at, r.saved_by, COALESCE(u.username, ''), r.replaced_at`
+
+const revisionFrom = `project_revisions r LEFT JOIN users u ON u.id = r.saved_by`
+
+func scanRevision(row rowScanner) (models.ProjectRevision, error) {
+	var rev models.ProjectRevision
+	var tags, imageIDs string
+	if err := row.Scan(&rev.ID, &rev.ProjectID, &rev.Title, &rev.Slug, &rev.Description,
+		&rev.VideoEmbed, &rev.Date, &tags, &imageIDs, &rev.SavedAt, &rev.SavedBy,
+		&rev.SavedByName, &rev.ReplacedAt); err != nil {
+		return rev, err
+	}
+	if err := json.Unmarshal([]byte(tags), &rev.Tags); err != nil {
+		return rev, err
+	}
+	return rev, json.Unmarshal([]byte(imageIDs), &rev.ImageIDs)
+}
+
+func (db *DB) ListProjectRevisions(projectID int64) ([]models.ProjectRevision, error) {
+	rows, err := db.Query(`
+        SELECT `+revisionColumns+`
+        FROM `+revisionFrom+`
+        WHERE r.project_id = ?
+        ORDER BY r.id DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.ProjectRevision
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (db *DB) RestoreProjectRevision(projectID, revisionID int64, updatedAt time.Time, updatedBy *int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rev, err := scanRevision(tx.QueryRow(`
+        SELECT `+revisionColumns+`
+        FROM `+revisionFrom+`
+        WHERE r.id = ? AND r.project_id = ?`, revisionID, projectID))
+	if err != nil {
+		return err
+	}
+	current, err := loadProject(tx, projectID)
+	if err != nil {
+		return err
+	}
+	if current.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	before, err := imageOrder(tx, projectID)
+	if err != nil {
+		return err
+	}
+
+	p := &models.Project{
+		ID:          projectID,
+		Title:       rev.Title,
+		Slug:        rev.Slug,
+		Description: rev.Description,
+		VideoEmbed:  rev.VideoEmbed,
+		Date:        rev.Date,
+		UpdatedAt:   updatedAt,
+		UpdatedBy:   updatedBy,
+		Status:      current.Status,
+		PublishAt:   current.PublishAt,
+		Tags:        rev.Tags,
+	}
+	if p.Slug, err = UniqueProjectSlug(tx, slugBase(p), projectID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+        UPDATE projects
+        SET title = ?, slug = ?, description = ?, video_embed = ?, date = ?, updated_at = ?, updated_by = ?
+        WHERE id = ?`,
+		p.Title, p.Slug, p.Description, p.VideoEmbed, p.Date, p.UpdatedAt, p.UpdatedBy, projectID); err != nil {
+		return err
+	}
+	if err := setProjectTags(tx, projectID, p.Tags); err != nil {
+		return err
+	}
+
+	after := restoredOrder(rev.ImageIDs, before)
+	for position, id := range after {
+		if _, err := tx.Exec(
+			"UPDATE project_media SET position = ? WHERE id = ? AND project_id = ?",
+			position, id, projectID); err != nil {
+			return err
+		}
+	}
+
+	if err := saveRevision(tx, &current, before); err != nil {
+		return err
+	}
+	old, restored := projectAudit(&current), projectAudit(p)
+	old["images"], restored["images"] = before, after
+	restored["revision_id"] = revisionID
+	if err := db.audit(tx, "project.revert", "project", projectID, old, restored); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,104 @@
+// internal/db/revisions_test.go
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestUpdateProjectSavesRevisionOnChange(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Night Drive", "Film")
+
+		// Saving the form unchanged records nothing
+		same, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpdateProject(same, nil); err != nil {
+			t.Fatal(err)
+		}
+		if revisions, _ := s.ListProjectRevisions(p.ID); len(revisions) != 0 {
+			t.Fatalf("unchanged save recorded %d revisions; want none", len(revisions))
+		}
+
+		changed, _ := s.GetProject(p.ID)
+		changed.Title = "Night Drive (Director's Cut)"
+		if err := s.UpdateProject(changed, nil); err != nil {
+			t.Fatal(err)
+		}
+		revisions, err := s.ListProjectRevisions(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != 1 {
+			t.Fatalf("got %d revisions; want 1", len(revisions))
+		}
+		if rev := revisions[0]; rev.Title != "Night Drive" || rev.Slug != "night-drive" ||
+			len(rev.Tags) != 1 || rev.Tags[0] != "Film" {
+			t.Errorf("revision = %+v; want the state before the change", rev)
+		}
+	})
+}
+
+func TestRestoreProjectRevision(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := &models.Project{Title: "Night Drive", Description: "First cut",
+			Tags: []string{"Film"}, Status: models.StatusPublished}
+		if err := s.CreateProject(p, []models.Image{testImage("a"), testImage("b")}); err != nil {
+			t.Fatal(err)
+		}
+		edited, _ := s.GetProject(p.ID)
+		edited.Title, edited.Description, edited.Tags = "Pier", "Second cut", []string{"Documentary"}
+		if err := s.UpdateProject(edited, nil); err != nil {
+			t.Fatal(err)
+		}
+		ids := imageIDs(edited)
+		if err := s.ReorderImages(p.ID, []int64{ids[1], ids[0]}); err != nil {
+			t.Fatal(err)
+		}
+
+		revisions, _ := s.ListProjectRevisions(p.ID)
+		first := revisions[len(revisions)-1]
+		u := createUser(t, s, "ada")
+		actor := models.Actor{UserID: &u.ID, Username: u.Username}
+		if err := s.As(actor).RestoreProjectRevision(p.ID, first.ID, time.Now(), &u.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != "Night Drive" || got.Slug != "night-drive" || got.Description != "First cut" ||
+			len(got.Tags) != 1 || got.Tags[0] != "Film" {
+			t.Errorf("restored project = %+v; want the first revision's fields", got)
+		}
+		if !equalIDs(imageIDs(got), ids) {
+			t.Errorf("restored image order = %v; want %v", imageIDs(got), ids)
+		}
+		if got.UpdatedBy == nil || *got.UpdatedBy != u.ID {
+			t.Errorf("updated by = %v; want ada", got.UpdatedBy)
+		}
+
+		// The state the restore replaced is kept as a revision of its own
+		revisions, _ = s.ListProjectRevisions(p.ID)
+		if latest := revisions[0]; latest.Title != "Pier" || latest.Description != "Second cut" {
+			t.Errorf("latest revision = %+v; want the replaced state", latest)
+		}
+
+		entries, err := s.ListAuditLog(models.AuditFilter{Action: "project.revert"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].EntityID != p.ID || entries[0].ActorName != "ada" ||
+			!strings.Contains(entries[0].After, `"Night Drive"`) || !strings.Contains(entries[0].Before, `"Pier"`) {
+			t.Errorf("revert entries = %+v; want one by ada from Pier back to Night Drive", entries)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+// internal/db/schedule_test.go
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestPublishScheduledProjects(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		// The clock is passed in, and in another zone than the stored times
+		now := time.Date(2030, 5, 1, 9, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+		publishAt := now.Add(time.Hour)
+		create := func(title string, status models.ProjectStatus) *models.Project {
+			t.Helper()
+			p := &models.Project{Title: title, Status: status}
+			if status == models.StatusScheduled {
+				p.PublishAt = &publishAt
+			}
+			if err := s.CreateProject(p, nil); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+		scheduled := create("Night Drive", models.StatusScheduled)
+		trashed := create("Harbour", models.StatusScheduled)
+		draft := create("Pier", models.StatusDraft)
+		if err := s.DeleteProject(trashed.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, at := range []time.Time{now, publishAt.Add(-time.Second)} {
+			if n, err := s.PublishScheduledProjects(at); err != nil || n != 0 {
+				t.Fatalf("PublishScheduledProjects(%v) = %d, %v; want nothing due", at, n, err)
+			}
+		}
+		if p, _ := s.GetProject(scheduled.ID); p.Status != models.StatusScheduled {
+			t.Fatalf("status before the publish time = %s; want scheduled", p.Status)
+		}
+
+		n, err := s.PublishScheduledProjects(publishAt.UTC())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("published %d projects; want only the scheduled one outside the trash", n)
+		}
+		if p, _ := s.GetProject(scheduled.ID); p.Status != models.StatusPublished || p.PublishAt != nil {
+			t.Errorf("status, publish_at = %s, %v; want published with no publish time", p.Status, p.PublishAt)
+		}
+		if p, _ := s.GetProject(draft.ID); p.Status != models.StatusDraft {
+			t.Errorf("draft status = %s; want it left alone", p.Status)
+		}
+		entries, _ := s.ListAuditLog(models.AuditFilter{Action: "project.publish"})
+		if len(entries) != 1 || entries[0].EntityID != scheduled.ID {
+			t.Errorf("publish entries = %+v; want one for the scheduled project", entries)
+		}
+
+		if n, _ := s.PublishScheduledProjects(publishAt.Add(time.Hour)); n != 0 {
+			t.Errorf("second run published %d projects; want none", n)
+		}
+	})
+}
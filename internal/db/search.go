@@ -0,0 +1,221 @@
+// internal/db/search.go
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"voidcase/internal/models"
+)
+
+// The search index is the FTS5 table project_search, created and kept in
+// step with projects and their tags by migration 0020. FTS5 is only compiled
+// into the SQLite driver when building with -tags sqlite_fts5.
+
+// HasFTS5 reports whether the SQLite driver was built with FTS5, which the
+// search index needs
+func HasFTS5(sqlDB *sql.DB) bool {
+	var enabled bool
+	err := sqlDB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	return err == nil && enabled
+}
+
+// searchTerms splits a visitor's query into words, dropping characters that
+// are FTS5 syntax
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		word = strings.Trim(strings.ReplaceAll(word, `"`, ""), "*^")
+		if word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// matchExpression turns terms into an FTS5 query matching projects that
+// contain every term, each as a prefix
+func matchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (db *DB) SearchProjects(search models.ProjectSearch) ([]models.SearchResult, error) {
+	terms := searchTerms(search.Query)
+
+	var where []string
+	var args []interface{}
+	if search.Listed {
+		where = append(where, projectListed)
+	} else {
+		where = append(where, projectLive)
+	}
+	if search.Tag != "" {
+		where = append(where, taggedWith)
+		args = append(args, search.Tag)
+	}
+	if len(search.Tags) > 0 {
+		matches := make([]string, len(search.Tags))
+		for i, tag := range search.Tags {
+			matches[i] = taggedWith
+			args = append(args, tag)
+		}
+		join := " OR "
+		if search.MatchAll {
+			join = " AND "
+		}
+		where = append(where, "("+strings.Join(matches, join)+")")
+	}
+	if search.Year != 0 {
+		where = append(where, "strftime('%Y', p.date) = ?")
+		args = append(args, strconv.Itoa(search.Year))
+	}
+	if search.Status != "" {
+		where = append(where, "p.status = ?")
+		args = append(args, search.Status)
+	}
+
+	query := `SELECT ` + projectColumns + `, ''`
+	from := projectFrom
+	order := orderBy(search.Order)
+	if len(terms) > 0 {
+		// Titles weigh most, then tags, then descriptions
+		query = `SELECT ` + projectColumns + `,
+               snippet(project_search, -1, '` + models.MatchStart + `', '` + models.MatchEnd + `', '…', 16)`
+		from = `project_search JOIN projects p ON p.id = project_search.rowid
+        LEFT JOIN users u ON u.id = p.updated_by`
+		where = append(where, "project_search MATCH ?")
+		args = append(args, matchExpression(terms))
+		order = "bm25(project_search, 10.0, 1.0, 5.0), " + order
+	}
+
+	rows, err := db.Query(query+`
+        FROM `+from+`
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []models.Project
+	var snippets []string
+	for rows.Next() {
+		var p models.Project
+		var excerpt string
+		if err := rows.Scan(&p.ID, &p.Title, &p.Slug, &p.Description, &p.VideoEmbed,
+			&p.Date, &p.CreatedAt, &p.UpdatedAt, &p.UpdatedBy, &p.UpdatedByName, &p.DeletedAt,
+			&p.Status, &p.PublishAt, &p.SortOrder, &p.Featured, &excerpt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+		snippets = append(snippets, excerpt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := db.attachTagsAndImages(projects); err != nil {
+		return nil, err
+	}
+	results := make([]models.SearchResult, len(projects))
+	for i, p := range projects {
+		results[i] = models.SearchResult{Project: p, Snippet: snippets[i]}
+	}
+	return results, nil
+}
+
+// snippetRunes is roughly how much text snippet shows around the first match
+const snippetRunes = 120
+
+// snippet excerpts the first of the title, description and tags to contain a
+// term, marking every occurrence of the terms. It stands in for FTS5's
+// snippet function in MemoryStore.
+func snippet(p *models.Project, terms []string) string {
+	for _, text := range []string{p.Title, p.Description, strings.Join(p.Tags, " ")} {
+		start, _, ok := firstMatch(text, terms)
+		if !ok {
+			continue
+		}
+
+		// Centre the excerpt on the match, on rune boundaries
+		from := start
+		for n := 0; from > 0 && n < snippetRunes/3; n++ {
+			_, size := utf8.DecodeLastRuneInString(text[:from])
+			from -= size
+		}
+		to := from
+		for n := 0; to < len(text) && n < snippetRunes; n++ {
+			_, size := utf8.DecodeRuneInString(text[to:])
+			to += size
+		}
+
+		excerpt := markTerms(text[from:to], terms)
+		if from > 0 {
+			excerpt = "…" + excerpt
+		}
+		if to < len(text) {
+			excerpt += "…"
+		}
+		return excerpt
+	}
+	return ""
+}
+
+// matchesAll reports whether every term occurs in one of texts
+func matchesAll(terms []string, texts ...string) bool {
+	for _, term := range terms {
+		found := false
+		for _, text := range texts {
+			if _, _, ok := firstMatch(text, []string{term}); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// firstMatch returns the byte offset and length of the earliest
+// case-insensitive occurrence of any term in text, preferring the longest
+// term at that offset
+func firstMatch(text string, terms []string) (int, int, bool) {
+	for i := range text {
+		length := 0
+		for _, term := range terms {
+			end := i + len(term)
+			if end <= len(text) && len(term) > length && strings.EqualFold(text[i:end], term) {
+				length = len(term)
+			}
+		}
+		if length > 0 {
+			return i, length, true
+		}
+	}
+	return 0, 0, false
+}
+
+// markTerms wraps each case-insensitive occurrence of the terms in text with
+// MatchStart and MatchEnd
+func markTerms(text string, terms []string) string {
+	var b strings.Builder
+	for {
+		i, length, ok := firstMatch(text, terms)
+		if !ok {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:i])
+		b.WriteString(models.MatchStart + text[i:i+length] + models.MatchEnd)
+		text = text[i+length:]
+	}
+}
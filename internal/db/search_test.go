@@ -0,0 +1,85 @@
+// internal/db/search_test.go
+package db_test
+
+import (
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestSearchProjects(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		drive := dbtest.CreateProject(t, s, "Night Drive", "Music Video", "Neon")
+		dbtest.CreateProject(t, s, "Harbour Lights", "Documentary")
+		both := dbtest.CreateProject(t, s, "Neon Harbour", "Documentary", "Neon")
+
+		results, err := s.SearchProjects(models.ProjectSearch{Query: "driv"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ID != drive.ID {
+			t.Fatalf("query driv matched %v; want only %q", resultTitles(results), drive.Title)
+		}
+		if results[0].Snippet == "" {
+			t.Error("query match has no snippet")
+		}
+
+		results, err = s.SearchProjects(models.ProjectSearch{Tags: []string{"Neon", "Documentary"}, MatchAll: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ID != both.ID {
+			t.Fatalf("tags Neon + Documentary matched %v; want only %q", resultTitles(results), both.Title)
+		}
+
+		results, err = s.SearchProjects(models.ProjectSearch{Tags: []string{"Music Video", "Documentary"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("tags Music Video or Documentary matched %v; want all three", resultTitles(results))
+		}
+	})
+}
+
+func TestSearchMatchesTagsBeneath(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Pier", "Shorts", "Film")
+		parent, err := s.GetTagByName("Film")
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := s.GetTagByName("Shorts")
+		if err != nil {
+			t.Fatal(err)
+		}
+		child.ParentID = &parent.ID
+		if err := s.UpdateTag(child); err != nil {
+			t.Fatal(err)
+		}
+
+		// Dropping Film from the project keeps the tag, as Shorts sits
+		// beneath it, and searching for Film still finds the project
+		p.Tags = []string{"Shorts"}
+		if err := s.UpdateProject(p, nil); err != nil {
+			t.Fatal(err)
+		}
+		results, err := s.SearchProjects(models.ProjectSearch{Tag: "film"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ID != p.ID {
+			t.Fatalf("tag film matched %v; want %q", resultTitles(results), p.Title)
+		}
+	})
+}
+
+func resultTitles(results []models.SearchResult) []string {
+	titles := make([]string, len(results))
+	for i, r := range results {
+		titles[i] = r.Title
+	}
+	return titles
+}
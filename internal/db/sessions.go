@@ -0,0 +1,100 @@
+// internal/db/sessions.go
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+const sessionColumns = `id, user_id, token_hash, user_agent, ip, created_at, last_seen_at, expires_at`
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	s := &models.Session{}
+	err := row.Scan(&s.ID, &s.UserID, &s.TokenHash, &s.UserAgent, &s.IP,
+		&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (db *DB) CreateSession(session *models.Session) error {
+	result, err := db.Exec(`
+        INSERT INTO sessions (user_id, token_hash, user_agent, ip, created_at, last_seen_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, session.UserID, session.TokenHash, session.UserAgent, session.IP,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	session.ID, err = result.LastInsertId()
+	return err
+}
+
+func (db *DB) GetSession(tokenHash string) (*models.Session, error) {
+	return scanSession(db.QueryRow(`
+        SELECT `+sessionColumns+` FROM sessions
+        WHERE token_hash = ? AND expires_at > ?
+    `, tokenHash, time.Now()))
+}
+
+func (db *DB) TouchSession(id int64, ip string, lastSeen, expires time.Time) error {
+	_, err := db.Exec(`
+        UPDATE sessions SET ip = ?, last_seen_at = ?, expires_at = ?
+        WHERE id = ?`, ip, lastSeen, expires, id)
+	return err
+}
+
+func (db *DB) ListUserSessions(userID int64) ([]models.Session, error) {
+	rows, err := db.Query(`
+        SELECT `+sessionColumns+` FROM sessions
+        WHERE user_id = ? AND expires_at > ?
+        ORDER BY last_seen_at DESC, id DESC`, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+func (db *DB) DeleteSession(tokenHash string) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+	return err
+}
+
+func (db *DB) RevokeSession(userID, id int64) error {
+	result, err := db.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DB) DeleteUserSessions(userID int64) error {
+	_, err := db.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+func (db *DB) DeleteExpiredSessions(now time.Time) error {
+	if _, err := db.Exec("DELETE FROM sessions WHERE expires_at < ?", now); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM trusted_devices WHERE expires_at < ?", now)
+	return err
+}
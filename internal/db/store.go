@@ -0,0 +1,303 @@
+// internal/db/store.go
+package db
+
+import (
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// ProjectStore reads and writes projects along with their tags and images.
+// Lookups that find nothing return sql.ErrNoRows. Projects in the trash are
+// left out of every method but the trash methods.
+type ProjectStore interface {
+	// ListProjects returns every project in manual order with tags and images
+	ListProjects() ([]models.Project, error)
+	// ListPublishedProjects returns the projects listed on the public site,
+	// sorted by order, with tags and images
+	ListPublishedProjects(order models.ProjectOrder) ([]models.Project, error)
+	// ListProjectsByTag returns published projects carrying tag, matched
+	// case-insensitively, or a tag beneath it, sorted by order. It skips
+	// offset projects and returns at most limit, or every one when limit is
+	// 0, with tags and images loaded for the page alone.
+	ListProjectsByTag(tag string, order models.ProjectOrder, limit, offset int) ([]models.Project, error)
+	// SearchProjects returns the projects matching search, best matches
+	// first when it has a query and in search.Order otherwise
+	SearchProjects(search models.ProjectSearch) ([]models.SearchResult, error)
+	// RecentProjects returns the most recently created projects without
+	// tags or images
+	RecentProjects(limit int) ([]models.Project, error)
+	GetProject(id int64) (*models.Project, error)
+	GetProjectBySlug(slug string) (*models.Project, error)
+	// ProjectIDBySlug returns the ID of the project at slug without loading
+	// its tags or images
+	ProjectIDBySlug(slug string) (int64, error)
+	// CreateProject inserts p with its tags and attaches images, assigning
+	// p.ID and a unique p.Slug derived from p.Slug or p.Title. Images whose
+	// media has no ID are recorded in the media library first. A project
+	// without a status is published. New projects go first in the manual
+	// order.
+	CreateProject(p *models.Project, images []models.Image) error
+	// UpdateProject saves p's fields, replaces its tags and attaches any
+	// newly uploaded images. The state it replaces is kept as a revision
+	// unless nothing changed.
+	UpdateProject(p *models.Project, images []models.Image) error
+	// ReorderProjects sets the manual order to projectIDs, first to last, in
+	// one transaction. Projects left out keep their position. The change is
+	// audited as a single project.reorder entry listing the new order.
+	ReorderProjects(projectIDs []int64) error
+	// ListProjectRevisions returns a project's earlier states, newest first
+	ListProjectRevisions(projectID int64) ([]models.ProjectRevision, error)
+	// RestoreProjectRevision saves a revision's fields, tags and image order
+	// as a new save of the project, keeping the current state as a revision.
+	// Images attached since the revision follow its images.
+	RestoreProjectRevision(projectID, revisionID int64, updatedAt time.Time, updatedBy *int64) error
+	// DeleteProject moves a project to the trash, hiding it from every
+	// other lookup and listing until it is restored
+	DeleteProject(id int64) error
+	// PublishScheduledProjects publishes the scheduled projects whose
+	// publish time is not after now and returns how many there were
+	PublishScheduledProjects(now time.Time) (int, error)
+	// ListDeletedProjects returns the projects in the trash, most recently
+	// deleted first, with tags and images
+	ListDeletedProjects() ([]models.Project, error)
+	// RestoreProject takes a project out of the trash
+	RestoreProject(id int64) error
+	// PurgeProject removes a project in the trash for good and returns the
+	// media that is no longer attached anywhere so its files can be cleaned up
+	PurgeProject(id int64) ([]models.Media, error)
+	// PurgeDeletedProjects purges every project deleted before the given
+	// time, returning the unattached media as PurgeProject does
+	PurgeDeletedProjects(before time.Time) ([]models.Media, error)
+}
+
+// ImageStore edits the images attached to a project. Image IDs identify an
+// attachment, not the media behind it. Every method is scoped to projectID
+// so an image cannot be modified through another project.
+type ImageStore interface {
+	// ReorderImages sets image positions to their index in imageIDs, which
+	// must list every image of the project exactly once (ErrImageOrder)
+	ReorderImages(projectID int64, imageIDs []int64) error
+	// UpdateImage saves an image's caption and alt text
+	UpdateImage(img *models.Image) error
+	// SetCoverImage makes imageID the project's only cover image
+	SetCoverImage(projectID, imageID int64) error
+	// DeleteImage detaches an image from the project. If no other project
+	// uses the media it is deleted too and returned, with its renditions, so
+	// the files can be cleaned up; otherwise the returned media is nil.
+	DeleteImage(projectID, imageID int64) (*models.Media, error)
+}
+
+// MediaStore manages the content-addressed media library shared by all
+// projects
+type MediaStore interface {
+	// ListMedia returns every media item, newest first, with renditions and
+	// the number of projects and tag covers using it
+	ListMedia() ([]models.Media, error)
+	GetMediaByHash(hash string) (*models.Media, error)
+	// CreateMedia records m and its renditions without attaching it. If the
+	// hash is already stored, m.ID is set to the existing media.
+	CreateMedia(m *models.Media) error
+	// AttachMedia appends media to the end of a project's images, skipping
+	// any already attached
+	AttachMedia(projectID int64, mediaIDs []int64) error
+	// DeleteMedia removes unattached media and returns it for file cleanup,
+	// or ErrMediaInUse if a project still uses it or a tag has it as cover
+	DeleteMedia(id int64) (*models.Media, error)
+}
+
+// TagStore manages tags and the listings used for navigation and
+// statistics. Tags are created by tagging projects and removed once no
+// project, in the trash or not, carries them and no tag sits beneath them.
+type TagStore interface {
+	// NavigationTags returns the tree of tags leading to at least one
+	// listed project, categories first, leaving out hidden categories and
+	// the tags beneath them
+	NavigationTags() ([]models.NavTag, error)
+	CategoryCounts() ([]models.CategoryCount, error)
+	// ListTags returns every tag, categories first, with project counts
+	// and covers
+	ListTags() ([]models.Tag, error)
+	GetTag(id int64) (*models.Tag, error)
+	// GetTagByName finds a tag case-insensitively
+	GetTagByName(name string) (*models.Tag, error)
+	// UpdateTag saves a tag's name, description, cover and parent. A
+	// category matching the tag is renamed with it. Renaming to the name of
+	// another tag returns ErrTagExists, or of another category
+	// ErrCategoryExists, and a parent beneath the tag returns ErrTagCycle.
+	UpdateTag(t *models.Tag) error
+	// MergeTags moves every project from the source tag to the target and
+	// deletes the source. The target keeps its description and cover,
+	// taking the source's where it has none. Tags beneath the source move
+	// beneath the target, as does the source's category unless the target
+	// has one. A target beneath the source first takes the source's place.
+	MergeTags(sourceID, targetID int64) error
+	// DeleteTag removes a tag from every project and deletes it. Tags
+	// beneath it move up to its parent.
+	DeleteTag(id int64) error
+}
+
+// CategoryStore manages the categories offered on the project form. A
+// category matches the tag of the same name, ignoring case, and renaming it
+// renames that tag. Categories sharing a name or slug return
+// ErrCategoryExists.
+type CategoryStore interface {
+	// ListCategories returns every category in order with project counts
+	ListCategories() ([]models.Category, error)
+	GetCategory(id int64) (*models.Category, error)
+	GetCategoryBySlug(slug string) (*models.Category, error)
+	// CreateCategory inserts c last in the order and assigns c.ID. c.Slug
+	// is derived from the name when blank.
+	CreateCategory(c *models.Category) error
+	// UpdateCategory saves a category's name, slug and visibility
+	UpdateCategory(c *models.Category) error
+	// ReorderCategories sets the order to categoryIDs, first to last.
+	// Categories left out keep their position.
+	ReorderCategories(categoryIDs []int64) error
+	// DeleteCategory removes a category, leaving its tag on projects
+	DeleteCategory(id int64) error
+}
+
+// ConfigStore reads and writes the single site_config row
+type ConfigStore interface {
+	GetSiteConfig() (*models.SiteConfig, error)
+	UpdateSiteConfig(config *models.SiteConfig) error
+}
+
+// SessionStore manages admin login sessions
+type SessionStore interface {
+	CreateSession(session *models.Session) error
+	// GetSession returns the unexpired session for a token hash or
+	// sql.ErrNoRows
+	GetSession(tokenHash string) (*models.Session, error)
+	// TouchSession records a use of the session and moves its expiry
+	TouchSession(id int64, ip string, lastSeen, expires time.Time) error
+	// ListUserSessions returns the user's unexpired sessions, most
+	// recently used first
+	ListUserSessions(userID int64) ([]models.Session, error)
+	DeleteSession(tokenHash string) error
+	// RevokeSession deletes one of the user's sessions, returning
+	// sql.ErrNoRows if the user has no session with that ID
+	RevokeSession(userID, id int64) error
+	DeleteUserSessions(userID int64) error
+	// DeleteExpiredSessions removes expired sessions and remembered devices
+	DeleteExpiredSessions(now time.Time) error
+}
+
+// UserStore manages admin accounts and the invites used to create them.
+// Changes that would leave no active owner return ErrLastOwner.
+type UserStore interface {
+	GetUser(id int64) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	// ListUsers returns every account ordered by username
+	ListUsers() ([]models.User, error)
+	// CreateUser inserts u and assigns u.ID, or returns ErrUsernameTaken
+	CreateUser(u *models.User) error
+	SetUserRole(id int64, role models.Role) error
+	// SetUserDisabled disables or re-enables an account. Disabling ends the
+	// account's sessions.
+	SetUserDisabled(id int64, disabled bool) error
+	// SetUserPassword replaces an account's password hash and ends all of
+	// its sessions
+	SetUserPassword(id int64, passwordHash string) error
+	// DeleteUser removes an account with its sessions, invites and reset
+	// links. Projects it last edited keep no editor.
+	DeleteUser(id int64) error
+
+	CreateInvite(inv *models.Invite) error
+	// ListInvites returns unused, unexpired invites, newest first
+	ListInvites() ([]models.Invite, error)
+	// GetInvite returns the unused, unexpired invite with tokenHash
+	GetInvite(tokenHash string) (*models.Invite, error)
+	// AcceptInvite creates u with the invite's role and marks the invite
+	// used, or returns sql.ErrNoRows if the invite is no longer valid
+	AcceptInvite(tokenHash string, u *models.User) error
+	DeleteInvite(id int64) error
+
+	// CreatePasswordReset records a reset link, replacing any unused links
+	// for the same user
+	CreatePasswordReset(reset *models.PasswordReset) error
+	// GetPasswordReset returns the unused, unexpired reset with tokenHash
+	GetPasswordReset(tokenHash string) (*models.PasswordReset, error)
+	// ResetPassword sets the password of the reset's user, marks the reset
+	// used and ends the user's sessions, or returns sql.ErrNoRows if the
+	// reset is no longer valid
+	ResetPassword(tokenHash, passwordHash string) error
+}
+
+// TwoFactorStore keeps TOTP enrollment, recovery codes and remembered
+// devices. Codes and device tokens are passed and stored as hashes.
+type TwoFactorStore interface {
+	// EnableTOTP saves the user's secret and replaces their recovery codes
+	EnableTOTP(userID int64, secret string, codeHashes []string) error
+	// DisableTOTP removes the secret, recovery codes and remembered devices
+	DisableTOTP(userID int64) error
+	// UseTOTPStep records step as used. It reports false if that step or a
+	// later one was already accepted, so a code works only once.
+	UseTOTPStep(userID, step int64) (bool, error)
+	// ReplaceRecoveryCodes discards the user's recovery codes for new ones
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	// UseRecoveryCode marks an unused code as used, reporting false if the
+	// user has no such code
+	UseRecoveryCode(userID int64, codeHash string) (bool, error)
+	// RecoveryCodesLeft counts the user's unused recovery codes
+	RecoveryCodesLeft(userID int64) (int, error)
+	CreateTrustedDevice(device *models.TrustedDevice) error
+	// IsTrustedDevice reports whether tokenHash is an unexpired device
+	// remembered for userID
+	IsTrustedDevice(userID int64, tokenHash string) (bool, error)
+}
+
+// LoginAttemptStore records sign-in attempts for throttling and review
+type LoginAttemptStore interface {
+	RecordLoginAttempt(attempt *models.LoginAttempt) error
+	// LoginFailures counts failures after since for username and for ip,
+	// ignoring any before the latest success for the same username or ip
+	LoginFailures(username, ip string, since time.Time) (models.LoginFailures, error)
+	// RecentLoginAttempts returns up to limit attempts, newest first
+	RecentLoginAttempts(limit int) ([]models.LoginAttempt, error)
+	DeleteLoginAttemptsBefore(t time.Time) error
+}
+
+// AuditStore gives access to the audit log. Every change to content,
+// settings or accounts is recorded in the same transaction as the change.
+type AuditStore interface {
+	// As returns a Store that records actor as the author of the changes
+	// made through it. Changes made without As are recorded as "system".
+	As(actor models.Actor) Store
+	// ListAuditLog returns matching entries, newest first
+	ListAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+// AnalyticsStore records public page views and summarises them
+type AnalyticsStore interface {
+	// SavePageViews records a batch of views in one transaction
+	SavePageViews(views []models.PageView) error
+	// GetAnalyticsSummary reports on the views of the last days days
+	GetAnalyticsSummary(days int) (*models.AnalyticsSummary, error)
+	// DailySalt returns the random salt for day (YYYY-MM-DD in UTC),
+	// creating it on first use and discarding the salts of other days
+	DailySalt(day string) (string, error)
+}
+
+// Store is the full repository API handlers depend on. It is implemented by
+// *DB for SQLite and by *MemoryStore for tests.
+type Store interface {
+	ProjectStore
+	ImageStore
+	MediaStore
+	TagStore
+	CategoryStore
+	ConfigStore
+	SessionStore
+	UserStore
+	TwoFactorStore
+	LoginAttemptStore
+	AuditStore
+	AnalyticsStore
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
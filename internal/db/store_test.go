@@ -0,0 +1,71 @@
+// internal/db/store_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+// testImage is an image whose media has not been stored yet
+func testImage(hash string) models.Image {
+	now := time.Now()
+	return models.Image{
+		CreatedAt: now,
+		Media:     models.Media{Hash: hash, Path: "media/" + hash + ".jpg", Format: "jpeg", CreatedAt: now},
+	}
+}
+
+func TestProjectRoundTrip(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := &models.Project{
+			Title:       "Night Drive",
+			Description: "A drive through the city",
+			VideoEmbed:  "https://player.example.com/1",
+			Date:        time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			Tags:        []string{"Film", "Night"},
+		}
+		if err := s.CreateProject(p, []models.Image{testImage("first")}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != p.Title || got.Description != p.Description || got.VideoEmbed != p.VideoEmbed {
+			t.Errorf("GetProject = %+v; want the saved fields", got)
+		}
+		if !got.Date.Equal(p.Date) || got.Status != models.StatusPublished {
+			t.Errorf("date, status = %v, %q; want %v, published", got.Date, got.Status, p.Date)
+		}
+		if len(got.Tags) != 2 || len(got.Images) != 1 || got.Images[0].Hash != "first" {
+			t.Errorf("tags, images = %v, %+v; want both tags and the image", got.Tags, got.Images)
+		}
+
+		got.Title = "Night Drive (Director's Cut)"
+		got.Tags = []string{"Film"}
+		if err := s.UpdateProject(got, []models.Image{testImage("second")}); err != nil {
+			t.Fatal(err)
+		}
+		projects, err := s.ListProjects()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(projects) != 1 {
+			t.Fatalf("ListProjects returned %d projects; want 1", len(projects))
+		}
+		if p := projects[0]; p.Title != got.Title || len(p.Tags) != 1 || len(p.Images) != 2 {
+			t.Errorf("updated project = %q %v with %d images; want the new title, one tag and two images",
+				p.Title, p.Tags, len(p.Images))
+		}
+
+		if _, err := s.GetProject(p.ID + 1); err != sql.ErrNoRows {
+			t.Errorf("GetProject of a missing project error = %v; want sql.ErrNoRows", err)
+		}
+	})
+}
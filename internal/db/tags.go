@@ -0,0 +1,477 @@
+// internal/db/tags.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"voidcase/internal/models"
+)
+
+// ErrTagExists is returned when renaming a tag to the name of another tag,
+// which should be merged into it instead
+var ErrTagExists = errors.New("another tag already has that name")
+
+// ErrTagCycle is returned when a tag would sit beneath itself
+var ErrTagCycle = errors.New("a tag cannot sit beneath itself or a tag beneath it")
+
+// taggedWith matches the projects aliased p carrying the tag named by its
+// argument or any tag beneath it
+const taggedWith = `EXISTS (
+            WITH RECURSIVE subtags(id) AS (
+                SELECT id FROM tags WHERE name = ?
+                UNION SELECT t.id FROM tags t JOIN subtags s ON t.parent_id = s.id
+            )
+            SELECT 1 FROM project_tags pt
+            WHERE pt.project_id = p.id AND pt.tag_id IN (SELECT id FROM subtags))`
+
+// navNode is a tag considered for the site navigation
+type navNode struct {
+	id       int64
+	parentID int64
+	tag      models.NavTag
+	// hidden is set for hidden categories, which are left out along with
+	// the tags beneath them
+	hidden bool
+	// listed is set when a listed project carries the tag itself
+	listed bool
+}
+
+// navTree nests nodes, given in display order, beneath their parents,
+// keeping the branches that lead to a listed project
+func navTree(nodes []navNode) []models.NavTag {
+	known := make(map[int64]bool, len(nodes))
+	for _, n := range nodes {
+		known[n.id] = true
+	}
+	children := make(map[int64][]navNode)
+	for _, n := range nodes {
+		parent := n.parentID
+		if !known[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], n)
+	}
+
+	visited := make(map[int64]bool)
+	var build func(parent int64) []models.NavTag
+	build = func(parent int64) []models.NavTag {
+		var tags []models.NavTag
+		for _, n := range children[parent] {
+			if n.hidden || visited[n.id] {
+				continue
+			}
+			visited[n.id] = true
+			tag := n.tag
+			tag.Children = build(n.id)
+			if n.listed || len(tag.Children) > 0 {
+				tags = append(tags, tag)
+			}
+		}
+		return tags
+	}
+	return build(0)
+}
+
+func (db *DB) NavigationTags() ([]models.NavTag, error) {
+	rows, err := db.Query(`
+        SELECT t.id, COALESCE(t.parent_id, 0), t.name, COALESCE(c.slug, t.name),
+               NOT COALESCE(c.visible, 1),
+               EXISTS (
+                   SELECT 1 FROM project_tags pt JOIN projects p ON p.id = pt.project_id
+                   WHERE pt.tag_id = t.id AND ` + projectListed + `
+               )
+        FROM tags t
+        LEFT JOIN categories c ON c.name = t.name
+        ORDER BY ` + categoryOrder + `, t.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []navNode
+	for rows.Next() {
+		var n navNode
+		if err := rows.Scan(&n.id, &n.parentID, &n.tag.Name, &n.tag.Slug, &n.hidden, &n.listed); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return navTree(nodes), nil
+}
+
+func (db *DB) CategoryCounts() ([]models.CategoryCount, error) {
+	rows, err := db.Query(`
+        SELECT t.name, COUNT(p.id) as count
+        FROM tags t
+        LEFT JOIN project_tags pt ON t.id = pt.tag_id
+        LEFT JOIN projects p ON p.id = pt.project_id AND ` + projectLive + `
+        GROUP BY t.name
+        ORDER BY ` + categoryOrder + `, count DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.CategoryCount
+	for rows.Next() {
+		var c models.CategoryCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+const tagColumns = `t.id, t.name, t.description, t.cover_media_id, t.parent_id,
+               COALESCE((SELECT parent.name FROM tags parent WHERE parent.id = t.parent_id), ''),
+               (SELECT COUNT(*) FROM project_tags pt JOIN projects p ON p.id = pt.project_id
+                WHERE pt.tag_id = t.id AND ` + projectLive + `)`
+
+func scanTag(row rowScanner) (models.Tag, error) {
+	var t models.Tag
+	err := row.Scan(&t.ID, &t.Name, &t.Description, &t.CoverMediaID, &t.ParentID, &t.ParentName,
+		&t.ProjectCount)
+	return t, err
+}
+
+// attachTagCovers loads the cover media of tags, with renditions, in two
+// queries
+func attachTagCovers(q queryer, tags []models.Tag) error {
+	var args []interface{}
+	for _, t := range tags {
+		if t.CoverMediaID != nil {
+			args = append(args, *t.CoverMediaID)
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(fmt.Sprintf(`
+        SELECT `+mediaColumns+` FROM media m WHERE m.id IN (%s)`,
+		strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	media := make(map[int64]*models.Media)
+	var covers []*models.Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return err
+		}
+		media[m.ID] = &m
+		covers = append(covers, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if err := attachRenditions(q, covers); err != nil {
+		return err
+	}
+	for i := range tags {
+		if id := tags[i].CoverMediaID; id != nil {
+			tags[i].Cover = media[*id]
+		}
+	}
+	return nil
+}
+
+func (db *DB) ListTags() ([]models.Tag, error) {
+	rows, err := db.Query(`
+        SELECT ` + tagColumns + `
+        FROM tags t
+        ORDER BY ` + categoryOrder + `, t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := attachTagCovers(db, tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (db *DB) GetTag(id int64) (*models.Tag, error) {
+	return db.getTag("t.id = ?", id)
+}
+
+func (db *DB) GetTagByName(name string) (*models.Tag, error) {
+	return db.getTag("t.name = ?", name)
+}
+
+func (db *DB) getTag(where string, arg interface{}) (*models.Tag, error) {
+	t, err := scanTag(db.QueryRow(`
+        SELECT `+tagColumns+`
+        FROM tags t
+        WHERE `+where, arg))
+	if err != nil {
+		return nil, err
+	}
+	tags := []models.Tag{t}
+	if err := attachTagCovers(db, tags); err != nil {
+		return nil, err
+	}
+	return &tags[0], nil
+}
+
+// tagAudit snapshots the audited fields of a tag
+func tagAudit(t *models.Tag) auditFields {
+	return auditFields{
+		"name":           t.Name,
+		"description":    t.Description,
+		"cover_media_id": t.CoverMediaID,
+		"parent_id":      t.ParentID,
+	}
+}
+
+func (db *DB) UpdateTag(t *models.Tag) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := scanTag(tx.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", t.ID))
+	if err != nil {
+		return err
+	}
+
+	var taken bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM tags WHERE name = ? AND id != ?)", t.Name, t.ID).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrTagExists
+	}
+	if t.CoverMediaID != nil {
+		var exists bool
+		if err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM media WHERE id = ?)", *t.CoverMediaID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+	if t.ParentID != nil {
+		var parent int64
+		if err := tx.QueryRow("SELECT id FROM tags WHERE id = ?", *t.ParentID).Scan(&parent); err != nil {
+			return err
+		}
+		var cycle bool
+		if err := tx.QueryRow(`
+            WITH RECURSIVE subtags(id) AS (
+                SELECT ? UNION SELECT t.id FROM tags t JOIN subtags s ON t.parent_id = s.id
+            )
+            SELECT EXISTS(SELECT 1 FROM subtags WHERE id = ?)`, t.ID, parent).Scan(&cycle); err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTagCycle
+		}
+	}
+
+	if err := db.renameTagCategory(tx, before.Name, t.Name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE tags SET name = ?, description = ?, cover_media_id = ?, parent_id = ? WHERE id = ?",
+		t.Name, t.Description, t.CoverMediaID, t.ParentID, t.ID); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "tag.update", "tag", t.ID, tagAudit(&before), tagAudit(t)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// renameTagCategory gives the category matching the tag named old, if any,
+// the tag's new name so the two stay matched. It returns ErrCategoryExists
+// if another category already has that name.
+func (db *DB) renameTagCategory(tx *sql.Tx, old, name string) error {
+	if old == name {
+		return nil
+	}
+	before, err := scanCategory(tx.QueryRow(
+		"SELECT "+categoryColumns+" FROM categories c WHERE c.name = ?", old))
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var taken bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE name = ? AND id != ?)", name, before.ID).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrCategoryExists
+	}
+
+	after := before
+	after.Name = name
+	if _, err := tx.Exec("UPDATE categories SET name = ? WHERE id = ?", name, before.ID); err != nil {
+		return err
+	}
+	return db.audit(tx, "category.update", "category", before.ID, categoryAudit(&before), categoryAudit(&after))
+}
+
+func (db *DB) MergeTags(sourceID, targetID int64) error {
+	if sourceID == targetID {
+		return errors.New("cannot merge a tag into itself")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	source, err := scanTag(tx.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", sourceID))
+	if err != nil {
+		return err
+	}
+	target, err := scanTag(tx.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", targetID))
+	if err != nil {
+		return err
+	}
+
+	// A category on the source follows its projects, unless the target has
+	// its own
+	if err := db.renameTagCategory(tx, source.Name, target.Name); err != nil && err != ErrCategoryExists {
+		return err
+	}
+
+	// Projects carrying both tags keep a single link to the target
+	if _, err := tx.Exec(`
+        INSERT OR IGNORE INTO project_tags (project_id, tag_id)
+        SELECT project_id, ? FROM project_tags WHERE tag_id = ?`, targetID, sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM project_tags WHERE tag_id = ?", sourceID); err != nil {
+		return err
+	}
+
+	// The target takes on the source's description and cover if it has none
+	merged := target
+	if strings.TrimSpace(merged.Description) == "" {
+		merged.Description = source.Description
+	}
+	if merged.CoverMediaID == nil {
+		merged.CoverMediaID = source.CoverMediaID
+	}
+	if _, err := tx.Exec(
+		"UPDATE tags SET description = ?, cover_media_id = ? WHERE id = ?",
+		merged.Description, merged.CoverMediaID, targetID); err != nil {
+		return err
+	}
+	// A target anywhere beneath the source takes the source's place first,
+	// so the source's children can move beneath it without a cycle
+	var beneath bool
+	if err := tx.QueryRow(`
+        WITH RECURSIVE subtags(id) AS (
+            SELECT ? UNION SELECT t.id FROM tags t JOIN subtags s ON t.parent_id = s.id
+        )
+        SELECT EXISTS(SELECT 1 FROM subtags WHERE id = ?)`, sourceID, targetID).Scan(&beneath); err != nil {
+		return err
+	}
+	if beneath {
+		merged.ParentID = source.ParentID
+		if _, err := tx.Exec("UPDATE tags SET parent_id = ? WHERE id = ?", merged.ParentID, targetID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		"UPDATE tags SET parent_id = ? WHERE parent_id = ? AND id != ?", targetID, sourceID, targetID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", sourceID); err != nil {
+		return err
+	}
+
+	if err := db.audit(tx, "tag.merge", "tag", sourceID, tagAudit(&source),
+		auditFields{"merged_into": targetID, "name": target.Name}); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "tag.update", "tag", targetID, tagAudit(&target), tagAudit(&merged)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteTag(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	t, err := scanTag(tx.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM project_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	// The tags beneath it move up to its parent
+	if _, err := tx.Exec("UPDATE tags SET parent_id = ? WHERE parent_id = ?", t.ParentID, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "tag.delete", "tag", id, tagAudit(&t), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pruneTags removes tags no project carries and no other tag sits beneath.
+// Projects in the trash keep their tags so they can be restored, and tags
+// given a description or cover are kept for when they are used again.
+func pruneTags(tx *sql.Tx) error {
+	for {
+		result, err := tx.Exec(`
+            DELETE FROM tags
+            WHERE description = '' AND cover_media_id IS NULL AND NOT EXISTS (
+                SELECT 1 FROM project_tags pt WHERE pt.tag_id = tags.id
+            ) AND NOT EXISTS (
+                SELECT 1 FROM tags child WHERE child.parent_id = tags.id
+            )`)
+		if err != nil {
+			return err
+		}
+		// Removing a tag can leave its parent unused in turn
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// internal/db/tags_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestPruneKeepsDescribedTags(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Pier", "Plain", "Described")
+		tag, err := s.GetTagByName("Described")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tag.Description = "Work shot after dark"
+		if err := s.UpdateTag(tag); err != nil {
+			t.Fatal(err)
+		}
+
+		p.Tags = nil
+		if err := s.UpdateProject(p, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetTagByName("Plain"); err != sql.ErrNoRows {
+			t.Errorf("unused tag Plain error = %v; want sql.ErrNoRows", err)
+		}
+		got, err := s.GetTagByName("Described")
+		if err != nil {
+			t.Fatalf("described tag was pruned: %v", err)
+		}
+		if got.ProjectCount != 0 || got.Description != tag.Description {
+			t.Errorf("described tag = %+v; want no projects and its description", got)
+		}
+	})
+}
+
+func TestUpdateTagErrors(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		dbtest.CreateProject(t, s, "Pier", "Film", "Shorts")
+		film, err := s.GetTagByName("Film")
+		if err != nil {
+			t.Fatal(err)
+		}
+		shorts, err := s.GetTagByName("Shorts")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		renamed := *shorts
+		renamed.Name = "film"
+		if err := s.UpdateTag(&renamed); err != db.ErrTagExists {
+			t.Errorf("renaming onto another tag error = %v; want ErrTagExists", err)
+		}
+
+		shorts.ParentID = &film.ID
+		if err := s.UpdateTag(shorts); err != nil {
+			t.Fatal(err)
+		}
+		film.ParentID = &shorts.ID
+		if err := s.UpdateTag(film); err != db.ErrTagCycle {
+			t.Errorf("parent beneath the tag error = %v; want ErrTagCycle", err)
+		}
+		film.ParentID = &film.ID
+		if err := s.UpdateTag(film); err != db.ErrTagCycle {
+			t.Errorf("tag as its own parent error = %v; want ErrTagCycle", err)
+		}
+
+		missing := int64(1 << 40)
+		shorts.CoverMediaID = &missing
+		if err := s.UpdateTag(shorts); err != sql.ErrNoRows {
+			t.Errorf("missing cover error = %v; want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestMergeTags(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		category := &models.Category{Name: "Commercials", Visible: true}
+		if err := s.CreateCategory(category); err != nil {
+			t.Fatal(err)
+		}
+		dbtest.CreateProject(t, s, "Pier", "Commercials")
+		dbtest.CreateProject(t, s, "Harbour", "Commercials", "Advertising")
+		dbtest.CreateProject(t, s, "Neon", "Advertising", "Retail")
+
+		source, err := s.GetTagByName("Commercials")
+		if err != nil {
+			t.Fatal(err)
+		}
+		target, err := s.GetTagByName("Advertising")
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := s.GetTagByName("Retail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		source.Description = "Spots and campaigns"
+		if err := s.UpdateTag(source); err != nil {
+			t.Fatal(err)
+		}
+		child.ParentID = &source.ID
+		if err := s.UpdateTag(child); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.MergeTags(source.ID, target.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetTag(source.ID); err != sql.ErrNoRows {
+			t.Errorf("merged source error = %v; want sql.ErrNoRows", err)
+		}
+		merged, err := s.GetTag(target.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if merged.ProjectCount != 3 || merged.Description != source.Description {
+			t.Errorf("target = %+v; want 3 projects and the source's description", merged)
+		}
+		if got, err := s.GetTag(child.ID); err != nil || got.ParentID == nil || *got.ParentID != target.ID {
+			t.Errorf("child after merge = %+v, %v; want it beneath the target", got, err)
+		}
+		if got, err := s.GetCategory(category.ID); err != nil || got.Name != "Advertising" {
+			t.Errorf("category after merge = %+v, %v; want it renamed to Advertising", got, err)
+		}
+	})
+}
+
+func TestMergeTagsIntoGrandchild(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		// Film > Shorts > Animation, with Film beneath Work
+		dbtest.CreateProject(t, s, "Pier", "Work", "Film", "Shorts", "Animation", "Drama")
+		tags := make(map[string]*models.Tag)
+		for _, name := range []string{"Work", "Film", "Shorts", "Animation", "Drama"} {
+			tag, err := s.GetTagByName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tags[name] = tag
+		}
+		for child, parent := range map[string]string{
+			"Film": "Work", "Shorts": "Film", "Animation": "Shorts", "Drama": "Shorts"} {
+			tags[child].ParentID = &tags[parent].ID
+			if err := s.UpdateTag(tags[child]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.MergeTags(tags["Film"].ID, tags["Animation"].ID); err != nil {
+			t.Fatal(err)
+		}
+		// Animation takes Film's place beneath Work, and Shorts moves
+		// beneath Animation with Drama still beneath it
+		for child, parent := range map[string]string{"Animation": "Work", "Shorts": "Animation", "Drama": "Shorts"} {
+			got, err := s.GetTag(tags[child].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ParentID == nil || *got.ParentID != tags[parent].ID {
+				t.Errorf("%s = %+v; want it beneath %s", child, got, parent)
+			}
+		}
+		if _, err := s.SearchProjects(models.ProjectSearch{Tag: "Work"}); err != nil {
+			t.Errorf("searching the merged tree: %v", err)
+		}
+	})
+}
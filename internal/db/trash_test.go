@@ -0,0 +1,72 @@
+// internal/db/trash_test.go
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+)
+
+func TestTrashHidesProjects(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Harbour", "Documentary")
+		if err := s.DeleteProject(p.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetProject(p.ID); err != sql.ErrNoRows {
+			t.Fatalf("GetProject after delete error = %v; want sql.ErrNoRows", err)
+		}
+		if _, err := s.ProjectIDBySlug(p.Slug); err != sql.ErrNoRows {
+			t.Fatalf("ProjectIDBySlug after delete error = %v; want sql.ErrNoRows", err)
+		}
+		if trash, err := s.ListDeletedProjects(); err != nil || len(trash) != 1 || trash[0].ID != p.ID {
+			t.Fatalf("ListDeletedProjects = %v, %v; want the deleted project", trash, err)
+		}
+
+		if err := s.RestoreProject(p.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetProject(p.ID)
+		if err != nil {
+			t.Fatalf("GetProject after restore: %v", err)
+		}
+		// Projects in the trash keep their tags so they can be restored
+		if len(got.Tags) != 1 || got.Tags[0] != "Documentary" {
+			t.Fatalf("restored tags = %v; want [Documentary]", got.Tags)
+		}
+	})
+}
+
+func TestPurgeDeletedProjectsOutsideUTC(t *testing.T) {
+	// West of UTC a local timestamp sorts before the UTC one for the same
+	// instant, so mixing the two would purge projects early
+	local := time.Local
+	time.Local = time.FixedZone("UTC-10", -10*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		p := dbtest.CreateProject(t, s, "Harbour")
+		if err := s.DeleteProject(p.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, before := range []time.Time{time.Now().Add(-time.Minute), time.Now().UTC().Add(-time.Minute)} {
+			if _, err := s.PurgeDeletedProjects(before); err != nil {
+				t.Fatal(err)
+			}
+			if trash, _ := s.ListDeletedProjects(); len(trash) != 1 {
+				t.Fatalf("purging before %v removed a project deleted after it", before)
+			}
+		}
+
+		if _, err := s.PurgeDeletedProjects(time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		if trash, _ := s.ListDeletedProjects(); len(trash) != 0 {
+			t.Errorf("trash holds %d projects; want the one deleted before the cutoff purged", len(trash))
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// internal/db/twofactor.go
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+func (db *DB) EnableTOTP(userID int64, secret string, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ?", secret, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.totp_enable", "user", userID, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DisableTOTP(userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE users SET totp_secret = NULL, totp_last_step = 0 WHERE id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM trusted_devices WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.totp_disable", "user", userID, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) UseTOTPStep(userID, step int64) (bool, error) {
+	// The comparison and update happen in one statement so two requests
+	// cannot both accept the same code
+	result, err := db.Exec(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (db *DB) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.recovery_codes", "user", userID, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := db.Exec(`
+        UPDATE recovery_codes SET used_at = ?
+        WHERE id = (
+            SELECT id FROM recovery_codes
+            WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+            LIMIT 1
+        )`, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (db *DB) RecoveryCodesLeft(userID int64) (int, error) {
+	var n int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&n)
+	return n, err
+}
+
+func (db *DB) CreateTrustedDevice(device *models.TrustedDevice) error {
+	result, err := db.Exec(`
+        INSERT INTO trusted_devices (user_id, token_hash, created_at, expires_at)
+        VALUES (?, ?, ?, ?)`,
+		device.UserID, device.TokenHash, device.CreatedAt, device.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	device.ID, err = result.LastInsertId()
+	return err
+}
+
+func (db *DB) IsTrustedDevice(userID int64, tokenHash string) (bool, error) {
+	var trusted bool
+	err := db.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM trusted_devices
+            WHERE user_id = ? AND token_hash = ? AND expires_at > ?
+        )`, userID, tokenHash, time.Now()).Scan(&trusted)
+	return trusted, err
+}
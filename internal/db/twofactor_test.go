@@ -0,0 +1,63 @@
+// internal/db/twofactor_test.go
+package db_test
+
+import (
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+)
+
+func TestUseTOTPStepRejectsReplay(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		u := createUser(t, s, "ada")
+		if err := s.EnableTOTP(u.ID, "GEZDGNBVGY3TQOJQ", nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			step int64
+			want bool
+		}{{100, true}, {100, false}, {99, false}, {101, true}} {
+			ok, err := s.UseTOTPStep(u.ID, c.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != c.want {
+				t.Errorf("UseTOTPStep(%d) = %v; want %v", c.step, ok, c.want)
+			}
+		}
+	})
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		u := createUser(t, s, "ada")
+		if err := s.EnableTOTP(u.ID, "GEZDGNBVGY3TQOJQ", []string{"a", "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := s.UseRecoveryCode(u.ID, "a"); err != nil || !ok {
+			t.Fatalf("first use = %v, %v; want true", ok, err)
+		}
+		if ok, err := s.UseRecoveryCode(u.ID, "a"); err != nil || ok {
+			t.Fatalf("second use = %v, %v; want false", ok, err)
+		}
+		if n, err := s.RecoveryCodesLeft(u.ID); err != nil || n != 1 {
+			t.Fatalf("RecoveryCodesLeft = %d, %v; want 1", n, err)
+		}
+
+		other := createUser(t, s, "grace")
+		if ok, err := s.UseRecoveryCode(other.ID, "b"); err != nil || ok {
+			t.Errorf("another user's code = %v, %v; want false", ok, err)
+		}
+
+		if err := s.ReplaceRecoveryCodes(u.ID, []string{"c", "d", "e"}); err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := s.UseRecoveryCode(u.ID, "b"); ok {
+			t.Error("replaced code still works")
+		}
+		if n, _ := s.RecoveryCodesLeft(u.ID); n != 3 {
+			t.Errorf("RecoveryCodesLeft after replace = %d; want 3", n)
+		}
+	})
+}
@@ -0,0 +1,458 @@
+// internal/db/users.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"voidcase/internal/models"
+)
+
+// ErrLastOwner is returned when a change would leave no active owner
+var ErrLastOwner = errors.New("at least one active owner is required")
+
+// ErrUsernameTaken is returned when creating a user whose username exists
+var ErrUsernameTaken = errors.New("username is already taken")
+
+const userColumns = `id, username, password_hash, role, created_at, disabled_at,
+               COALESCE(totp_secret, ''), totp_last_step`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	u := &models.User{}
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DisabledAt,
+		&u.TOTPSecret, &u.TOTPLastStep)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (db *DB) GetUser(id int64) (*models.User, error) {
+	return scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	return scanUser(db.QueryRow(`
+        SELECT `+userColumns+` FROM users
+        WHERE username = ? COLLATE NOCASE
+    `, username))
+}
+
+func (db *DB) ListUsers() ([]models.User, error) {
+	rows, err := db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (db *DB) CreateUser(u *models.User) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, u); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.create", "user", u.ID, nil, userAudit(u)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// getUserTx loads a user inside tx, for snapshots taken before a change
+func getUserTx(tx *sql.Tx, id int64) (*models.User, error) {
+	return scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func insertUser(tx *sql.Tx, u *models.User) error {
+	var taken bool
+	if err := tx.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM users WHERE username = ? COLLATE NOCASE)
+    `, u.Username).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+
+	result, err := tx.Exec(`
+        INSERT INTO users (username, password_hash, role, created_at)
+        VALUES (?, ?, ?, ?)`,
+		u.Username, u.PasswordHash, u.Role, u.CreatedAt)
+	if err != nil {
+		return err
+	}
+	u.ID, err = result.LastInsertId()
+	return err
+}
+
+// checkOwnerRemains returns ErrLastOwner if id is the only active owner, for
+// use before demoting, disabling or deleting it. Unknown ids return
+// sql.ErrNoRows.
+func checkOwnerRemains(tx *sql.Tx, id int64) error {
+	u, err := getUserTx(tx, id)
+	if err != nil {
+		return err
+	}
+	if u.Role != models.RoleOwner || u.Disabled() {
+		return nil
+	}
+
+	var others int
+	if err := tx.QueryRow(`
+        SELECT COUNT(*) FROM users
+        WHERE role = ? AND disabled_at IS NULL AND id != ?
+    `, models.RoleOwner, id).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (db *DB) SetUserRole(id int64, role models.Role) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	u, err := getUserTx(tx, id)
+	if err != nil {
+		return err
+	}
+	if role != models.RoleOwner {
+		if err := checkOwnerRemains(tx, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", role, id); err != nil {
+		return err
+	}
+
+	before := userAudit(u)
+	u.Role = role
+	if err := db.audit(tx, "user.role", "user", id, before, userAudit(u)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) SetUserDisabled(id int64, disabled bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	u, err := getUserTx(tx, id)
+	if err != nil {
+		return err
+	}
+
+	var disabledAt *time.Time
+	if disabled {
+		if err := checkOwnerRemains(tx, id); err != nil {
+			return err
+		}
+		now := time.Now()
+		disabledAt = &now
+		if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE users SET disabled_at = ? WHERE id = ?", disabledAt, id); err != nil {
+		return err
+	}
+
+	action := "user.enable"
+	if disabled {
+		action = "user.disable"
+	}
+	before := userAudit(u)
+	u.DisabledAt = disabledAt
+	if err := db.audit(tx, action, "user", id, before, userAudit(u)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) SetUserPassword(id int64, passwordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPassword(tx, id, passwordHash); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.password", "user", id, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setPassword replaces a password hash, signs the user out everywhere and
+// forgets their remembered devices
+func setPassword(tx *sql.Tx, id int64, passwordHash string) error {
+	result, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM trusted_devices WHERE user_id = ?", id)
+	return err
+}
+
+func (db *DB) DeleteUser(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	u, err := getUserTx(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkOwnerRemains(tx, id); err != nil {
+		return err
+	}
+
+	// Foreign keys are not enforced, so clear references by hand
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM invites WHERE created_by = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM password_resets WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM trusted_devices WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE projects SET updated_by = NULL WHERE updated_by = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.delete", "user", id, userAudit(u), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const inviteColumns = `id, token_hash, role, created_by, created_at, expires_at, used_at`
+
+func scanInvite(row rowScanner) (*models.Invite, error) {
+	inv := &models.Invite{}
+	err := row.Scan(&inv.ID, &inv.TokenHash, &inv.Role, &inv.CreatedBy,
+		&inv.CreatedAt, &inv.ExpiresAt, &inv.UsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (db *DB) CreateInvite(inv *models.Invite) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        INSERT INTO invites (token_hash, role, created_by, created_at, expires_at)
+        VALUES (?, ?, ?, ?, ?)`,
+		inv.TokenHash, inv.Role, inv.CreatedBy, inv.CreatedAt, inv.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if inv.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "invite.create", "invite", inv.ID, nil, inviteAudit(inv)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) ListInvites() ([]models.Invite, error) {
+	rows, err := db.Query(`
+        SELECT `+inviteColumns+` FROM invites
+        WHERE used_at IS NULL AND expires_at > ?
+        ORDER BY created_at DESC, id DESC`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *inv)
+	}
+	return invites, rows.Err()
+}
+
+func (db *DB) GetInvite(tokenHash string) (*models.Invite, error) {
+	return getInvite(db, tokenHash)
+}
+
+func getInvite(q queryRower, tokenHash string) (*models.Invite, error) {
+	return scanInvite(q.QueryRow(`
+        SELECT `+inviteColumns+` FROM invites
+        WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+    `, tokenHash, time.Now()))
+}
+
+func (db *DB) AcceptInvite(tokenHash string, u *models.User) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inv, err := getInvite(tx, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	u.Role = inv.Role
+	if err := insertUser(tx, u); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE invites SET used_at = ? WHERE id = ?", u.CreatedAt, inv.ID); err != nil {
+		return err
+	}
+	after := userAudit(u)
+	after["invite_id"] = inv.ID
+	if err := db.audit(tx, "invite.accept", "user", u.ID, nil, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) DeleteInvite(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inv, err := scanInvite(tx.QueryRow(`SELECT `+inviteColumns+` FROM invites WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM invites WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "invite.delete", "invite", id, inviteAudit(inv), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const resetColumns = `id, user_id, token_hash, created_at, expires_at, used_at`
+
+func (db *DB) CreatePasswordReset(reset *models.PasswordReset) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL", reset.UserID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+        INSERT INTO password_resets (user_id, token_hash, created_at, expires_at)
+        VALUES (?, ?, ?, ?)`,
+		reset.UserID, reset.TokenHash, reset.CreatedAt, reset.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if reset.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.reset_link", "user", reset.UserID,
+		nil, auditFields{"expires_at": reset.ExpiresAt}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	return getPasswordReset(db, tokenHash)
+}
+
+func getPasswordReset(q queryRower, tokenHash string) (*models.PasswordReset, error) {
+	r := &models.PasswordReset{}
+	err := q.QueryRow(`
+        SELECT `+resetColumns+` FROM password_resets
+        WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+    `, tokenHash, time.Now()).Scan(&r.ID, &r.UserID, &r.TokenHash, &r.CreatedAt, &r.ExpiresAt, &r.UsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (db *DB) ResetPassword(tokenHash, passwordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	reset, err := getPasswordReset(tx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if err := setPassword(tx, reset.UserID, passwordHash); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE password_resets SET used_at = ? WHERE id = ?", time.Now(), reset.ID); err != nil {
+		return err
+	}
+	if err := db.audit(tx, "user.password_reset", "user", reset.UserID, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,31 @@
+// internal/db/users_test.go
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+// createUser saves an owner named username, failing the test on error
+func createUser(t *testing.T, s db.Store, username string) *models.User {
+	t.Helper()
+	u := &models.User{Username: username, PasswordHash: "x", Role: models.RoleOwner, CreatedAt: time.Now()}
+	if err := s.CreateUser(u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCreateUserRejectsTakenUsername(t *testing.T) {
+	dbtest.EachStore(t, func(t *testing.T, s db.Store) {
+		createUser(t, s, "ada")
+		err := s.CreateUser(&models.User{Username: "ada", PasswordHash: "x", Role: models.RoleViewer})
+		if err != db.ErrUsernameTaken {
+			t.Errorf("CreateUser error = %v; want ErrUsernameTaken", err)
+		}
+	})
+}
@@ -3,19 +3,21 @@ package handlers
 
 import (
 	"database/sql"
-	"fmt"
-	"html/template"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
-	"strings"
 
-	"voidcase/internal/middleware"
-	"voidcase/internal/models"
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/session"
 
 	"github.com/gorilla/csrf"
-	"golang.org/x/exp/maps"
 )
 
+// analyticsRanges are the reporting windows offered on the analytics page
+var analyticsRanges = []int{7, 30, 90, 365}
+
 // ProjectViews represents project view statistics
 type ProjectViews struct {
 	ProjectID int64
@@ -24,93 +26,80 @@ type ProjectViews struct {
 }
 
 type AnalyticsHandler struct {
-	db *sql.DB
+	store db.Store
+	cfg   *config.Config
 }
 
-func NewAnalyticsHandler(db *sql.DB) *AnalyticsHandler {
-	return &AnalyticsHandler{db: db}
+func NewAnalyticsHandler(store db.Store, cfg *config.Config) *AnalyticsHandler {
+	return &AnalyticsHandler{store: store, cfg: cfg}
 }
 
+// AdminAnalyticsHandler renders the analytics report for ?days=N, one of
+// analyticsRanges, defaulting to 30
 func (h *AnalyticsHandler) AdminAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
 	days := 30
-	if d := r.URL.Query().Get("days"); d != "" {
-		if parsed, err := strconv.Atoi(d); err == nil {
-			days = parsed
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil {
+		for _, allowed := range analyticsRanges {
+			if d == allowed {
+				days = d
+			}
 		}
 	}
 
-	analytics, err := middleware.NewAnalyticsMiddleware(h.db).GetAnalyticsSummary(days)
+	analytics, err := h.store.GetAnalyticsSummary(days)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Get project titles for IDs
-	projectIDs := maps.Keys(analytics.ProjectViews)
-	projectsMap, err := h.getProjectsForIDs(projectIDs)
+	projectViews, err := h.projectViews(analytics.ProjectViews)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Convert map to slice for template
-	var projectsList []models.Project
-	for _, project := range projectsMap {
-		projectsList = append(projectsList, project)
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/analytics.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
 	}
 
-	tmpl := template.Must(template.ParseFiles(
-		"internal/templates/base.html",
-		"internal/templates/admin/analytics.html",
-	))
-
 	data := PageData{
-		Title:     "Analytics",
-		Analytics: analytics,
-		Projects:  projectsList,
-		CSRFToken: csrf.Token(r),
-		IsAdmin:   true,
+		Title:          "Analytics",
+		Analytics:      analytics,
+		ProjectViews:   projectViews,
+		Days:           days,
+		AnalyticsRange: analyticsRanges,
+		CSRFToken:      csrf.Token(r),
+		IsAdmin:        true,
+		CurrentUser:    session.CurrentUser(r.Context()),
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
 	}
 }
 
-func (h *AnalyticsHandler) getProjectsForIDs(ids []int64) (map[int64]models.Project, error) {
-	if len(ids) == 0 {
-		return make(map[int64]models.Project), nil
-	}
-
-	query := fmt.Sprintf(`
-        SELECT id, title 
-        FROM projects 
-        WHERE id IN (?%s)`,
-		strings.Repeat(",?", len(ids)-1))
-
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		args[i] = id
-	}
-
-	rows, err := h.db.Query(query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	projects := make(map[int64]models.Project)
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(&p.ID, &p.Title); err != nil {
+// projectViews pairs view counts with project titles, most viewed first.
+// Projects deleted since they were viewed are skipped.
+func (h *AnalyticsHandler) projectViews(views map[int64]int) ([]ProjectViews, error) {
+	var list []ProjectViews
+	for id, count := range views {
+		p, err := h.store.GetProject(id)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
 			return nil, err
 		}
-		projects[p.ID] = p
+		list = append(list, ProjectViews{ProjectID: id, Title: p.Title, Views: count})
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return projects, nil
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Views != list[j].Views {
+			return list[i].Views > list[j].Views
+		}
+		return list[i].Title < list[j].Title
+	})
+	return list, nil
 }
@@ -0,0 +1,178 @@
+// internal/handlers/audit.go
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+)
+
+// auditPageSize is the number of entries shown per page of the audit log
+const auditPageSize = 50
+
+// auditEntityTypes are the entity types offered as a filter
+var auditEntityTypes = []string{"project", "image", "media", "tag", "category", "settings", "user", "invite"}
+
+// AuditPage is the audit log view: the current page of entries, the filter
+// form values and links to neighbouring pages
+type AuditPage struct {
+	Entries     []models.AuditEntry
+	EntityTypes []string
+	Actor       string
+	Action      string
+	EntityType  string
+	EntityID    string
+	From        string
+	To          string
+	PrevURL     string
+	NextURL     string
+	CSVURL      string
+}
+
+type AuditHandler struct {
+	store db.Store
+	cfg   *config.Config
+}
+
+func NewAuditHandler(store db.Store, cfg *config.Config) *AuditHandler {
+	return &AuditHandler{store: store, cfg: cfg}
+}
+
+// auditFilter reads the filter from the query string. from and to are
+// inclusive dates (YYYY-MM-DD); invalid values are ignored.
+func auditFilter(query url.Values) models.AuditFilter {
+	filter := models.AuditFilter{
+		Actor:      strings.TrimSpace(query.Get("actor")),
+		Action:     strings.TrimSpace(query.Get("action")),
+		EntityType: query.Get("type"),
+	}
+	if id, err := strconv.ParseInt(query.Get("id"), 10, 64); err == nil && id > 0 {
+		filter.EntityID = id
+	}
+	if from, err := time.ParseInLocation("2006-01-02", query.Get("from"), time.Local); err == nil {
+		filter.Since = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", query.Get("to"), time.Local); err == nil {
+		filter.Until = to.AddDate(0, 0, 1)
+	}
+	return filter
+}
+
+// AdminAuditHandler lists audit log entries matching the query string
+// filters, newest first, auditPageSize per page
+func (h *AuditHandler) AdminAuditHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	filter := auditFilter(query)
+	// Fetch one extra entry to learn whether there is a next page
+	filter.Limit = auditPageSize + 1
+	filter.Offset = (page - 1) * auditPageSize
+	entries, err := h.store.ListAuditLog(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit := &AuditPage{
+		Entries:     entries,
+		EntityTypes: auditEntityTypes,
+		Actor:       query.Get("actor"),
+		Action:      query.Get("action"),
+		EntityType:  query.Get("type"),
+		EntityID:    query.Get("id"),
+		From:        query.Get("from"),
+		To:          query.Get("to"),
+	}
+	query.Del("page")
+	audit.CSVURL = "/admin/audit.csv?" + query.Encode()
+	if len(entries) > auditPageSize {
+		audit.Entries = entries[:auditPageSize]
+		query.Set("page", strconv.Itoa(page+1))
+		audit.NextURL = "/admin/audit?" + query.Encode()
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page-1))
+		audit.PrevURL = "/admin/audit?" + query.Encode()
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/audit.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "Audit Log",
+		Audit:       audit,
+		CSRFToken:   csrf.Token(r),
+		IsAdmin:     true,
+		CurrentUser: session.CurrentUser(r.Context()),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// AdminAuditCSVHandler downloads every entry matching the same filters as
+// AdminAuditHandler as CSV
+func (h *AuditHandler) AdminAuditCSVHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.store.ListAuditLog(auditFilter(r.URL.Query()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "time", "actor", "actor_id", "ip", "action", "entity_type", "entity_id", "before", "after"})
+	for _, e := range entries {
+		actorID := ""
+		if e.ActorID != nil {
+			actorID = strconv.FormatInt(*e.ActorID, 10)
+		}
+		out.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(time.RFC3339),
+			csvText(e.ActorName),
+			actorID,
+			e.IP,
+			e.Action,
+			e.EntityType,
+			strconv.FormatInt(e.EntityID, 10),
+			csvText(e.Before),
+			csvText(e.After),
+		})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Audit export error: %v", err)
+	}
+}
+
+// csvText keeps spreadsheet applications from evaluating user-supplied text
+// as a formula
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
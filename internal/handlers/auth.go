@@ -3,14 +3,18 @@ package handlers
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/base64"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"voidcase/internal/config"
+	"voidcase/internal/db"
 	"voidcase/internal/models"
+	"voidcase/internal/session"
+	"voidcase/internal/utils"
 
 	"github.com/gorilla/csrf"
 	"golang.org/x/crypto/bcrypt"
@@ -18,25 +22,37 @@ import (
 
 // AuthHandler handles user authentication
 type AuthHandler struct {
-	db       *sql.DB
+	store   db.Store
+	cfg     *config.Config
+	cookies *session.Cookies
+	// now is the clock used for sessions and TOTP codes
+	now      func() time.Time
+	logins   loginLocks
 	shutdown chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
 }
 
-// NewAuthHandler creates a new auth handler instance
-func NewAuthHandler(db *sql.DB) *AuthHandler {
+// NewAuthHandler creates a new auth handler instance and starts the expired
+// session cleanup. Call Shutdown to stop it.
+func NewAuthHandler(store db.Store, cfg *config.Config) *AuthHandler {
 	h := &AuthHandler{
-		db:       db,
+		store:    store,
+		cfg:      cfg,
+		cookies:  session.NewCookies(cfg),
+		now:      time.Now,
 		shutdown: make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
-	// Start cleanup goroutine
 	go h.cleanupRoutine()
 
 	return h
 }
 
-// Add cleanup routine
 func (h *AuthHandler) cleanupRoutine() {
+	defer close(h.stopped)
+
 	ticker := time.NewTicker(6 * time.Hour)
 	defer ticker.Stop()
 
@@ -50,142 +66,151 @@ func (h *AuthHandler) cleanupRoutine() {
 	}
 }
 
-// Add shutdown method
+// Shutdown stops the session cleanup and waits for a running cleanup to
+// finish. It is safe to call more than once.
 func (h *AuthHandler) Shutdown() {
-	close(h.shutdown)
+	h.once.Do(func() { close(h.shutdown) })
+	<-h.stopped
 }
 
-// LoginHandler handles user login requests
+// LoginHandler handles user login requests. Repeated failures for a
+// username or from an address are slowed down and then refused for a while.
 func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		tmpl, err := loadTemplates("", "admin/layout.html", "admin/login.html")
-		if err != nil {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Template error", http.StatusInternalServerError)
-			return
-		}
-
-		data := PageData{
-			Title:     "Login",
-			CSRFToken: csrf.Token(r),
-			IsAdmin:   false, // Not admin on login page
-		}
-
-		if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		message := ""
+		if r.URL.Query().Get("error") != "" {
+			message = "Invalid username or password"
 		}
+		h.renderLogin(w, r, http.StatusOK, message)
 		return
 	}
 
 	username := strings.ToLower(r.FormValue("username"))
 	password := r.FormValue("password")
+	ip := utils.ClientIP(r)
 
-	var user models.User
-	var hashedPw string
-	err := h.db.QueryRow(`
-        SELECT id, password_hash FROM users 
-        WHERE LOWER(username) = ?
-    `, username).Scan(&user.ID, &hashedPw)
+	unlock := h.lockLogin(username, ip)
+	defer unlock()
 
-	if err != nil || bcrypt.CompareHashAndPassword(
-		[]byte(hashedPw), []byte(password)) != nil {
-		http.Redirect(w, r, "/admin/login?error=1", http.StatusSeeOther)
+	if wait, err := h.loginWait(username, ip); err != nil {
+		log.Printf("Login attempt lookup error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	} else if wait > 0 {
+		h.renderLogin(w, r, http.StatusTooManyRequests, refuseLogin(w, wait))
 		return
 	}
 
-	sessionID := make([]byte, 32)
-	if _, err := rand.Read(sessionID); err != nil {
-		log.Printf("Session generation error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	// Unknown usernames are checked against a stand-in hash so they fail
+	// no faster than wrong passwords
+	user, err := h.store.GetUserByUsername(username)
+	hash := unknownUserHash
+	if err == nil {
+		hash = user.PasswordHash
+	}
+	matched := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	if err != nil || !matched || user.Disabled() {
+		h.recordLogin(r, username, false)
+		http.Redirect(w, r, "/login?error=1", http.StatusSeeOther)
 		return
 	}
-	session := base64.URLEncoding.EncodeToString(sessionID)
 
-	// Begin transaction for session management
-	tx, err := h.db.Begin()
-	if err != nil {
-		log.Printf("Transaction error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if user.TOTPEnabled() && !h.trustedDevice(r, user.ID) {
+		if err := h.cookies.SetChallenge(w, user.ID, h.now().Add(challengeTTL)); err != nil {
+			log.Printf("Challenge cookie error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/login/verify", http.StatusSeeOther)
 		return
 	}
-	defer tx.Rollback()
 
-	// Clear old sessions for this user
-	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ?", user.ID)
+	h.startSession(w, r, user)
+}
+
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, r *http.Request, status int, message string) {
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/login.html")
 	if err != nil {
-		log.Printf("Session cleanup error: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
 	}
 
-	// Create new session
-	_, err = tx.Exec(`
-        INSERT INTO sessions (id, user_id, created_at, expires_at)
-        VALUES (?, ?, ?, ?)
-    `, session, user.ID, time.Now(),
-		time.Now().Add(24*time.Hour))
+	data := PageData{
+		Title:     "Login",
+		Error:     message,
+		CSRFToken: csrf.Token(r),
+		IsAdmin:   false, // Not admin on login page
+	}
 
-	if err != nil {
+	w.WriteHeader(status)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// startSession signs user in, records the successful attempt and sends
+// them to the admin
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Session generation error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	token := base64.URLEncoding.EncodeToString(tokenBytes)
+
+	// Sessions on the user's other devices are left signed in
+	now := h.now()
+	expires := now.Add(h.cfg.SessionIdleTimeout.Duration)
+	if err := h.store.CreateSession(&models.Session{
+		UserID:     user.ID,
+		TokenHash:  session.HashToken(token),
+		UserAgent:  truncate(r.UserAgent(), maxUserAgentLength),
+		IP:         utils.ClientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expires,
+	}); err != nil {
 		log.Printf("Session creation error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("Transaction commit error: %v", err)
+	if err := h.cookies.Set(w, token, expires); err != nil {
+		log.Printf("Session cookie error: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    session,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
-
+	h.recordLogin(r, user.Username, true)
 	http.Redirect(w, r, "/admin", http.StatusSeeOther)
 }
 
 // LogoutHandler handles user logout requests
 func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	if cookie, err := r.Cookie("session"); err == nil {
-		if _, err := h.db.Exec("DELETE FROM sessions WHERE id = ?", cookie.Value); err != nil {
+	if token, err := h.cookies.Token(r); err == nil {
+		if err := h.store.DeleteSession(session.HashToken(token)); err != nil {
 			log.Printf("Session deletion error: %v", err)
 		}
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		MaxAge:   -1,
-	})
+	h.cookies.Clear(w)
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// ValidateSession checks if a session is valid
-func (h *AuthHandler) ValidateSession(session string) bool {
-	var exists bool
-	err := h.db.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM sessions 
-            WHERE id = ? AND expires_at > datetime('now')
-        )`, session).Scan(&exists)
-	return err == nil && exists
+// ValidateSession checks if a session token is valid for an enabled account
+func (h *AuthHandler) ValidateSession(token string) bool {
+	return activeSession(h.store, token)
 }
 
 func (h *AuthHandler) cleanupExpiredSessions() {
-	_, err := h.db.Exec("DELETE FROM sessions WHERE expires_at < datetime('now')")
-	if err != nil {
+	now := h.now()
+	if err := h.store.DeleteExpiredSessions(now); err != nil {
 		log.Printf("Session cleanup error: %v", err)
 	}
+	if err := h.store.DeleteLoginAttemptsBefore(now.Add(-loginAttemptRetention)); err != nil {
+		log.Printf("Login attempt cleanup error: %v", err)
+	}
 }
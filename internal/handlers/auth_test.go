@@ -0,0 +1,83 @@
+// internal/handlers/auth_test.go
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/session"
+)
+
+func TestLogin(t *testing.T) {
+	store := db.NewMemoryStore()
+	h, _ := newAuthHandler(t, store, time.Now())
+
+	res := login(h, "Ada", "correct horse")
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/admin" {
+		t.Fatalf("login = %d to %q; want 303 to /admin", res.StatusCode, res.Header.Get("Location"))
+	}
+	var token string
+	for _, c := range res.Cookies() {
+		if c.Name == session.CookieName {
+			r := request("GET", "/admin", nil, nil)
+			r.AddCookie(c)
+			token, _ = h.cookies.Token(r)
+		}
+	}
+	if token == "" || !h.ValidateSession(token) {
+		t.Fatal("login did not start a valid session")
+	}
+
+	for _, c := range []struct{ username, password string }{
+		{"ada", "wrong"},
+		{"grace", "correct horse"},
+	} {
+		res := login(h, c.username, c.password)
+		if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/login?error=1" {
+			t.Errorf("login as %s/%s = %d to %q; want 303 to /login?error=1",
+				c.username, c.password, res.StatusCode, res.Header.Get("Location"))
+		}
+	}
+}
+
+func TestLoginRefusesDisabledUser(t *testing.T) {
+	store := db.NewMemoryStore()
+	h, user := newAuthHandler(t, store, time.Now())
+	if err := store.SetUserDisabled(user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if res := login(h, "ada", "correct horse"); res.Header.Get("Location") != "/login?error=1" {
+		t.Errorf("disabled login sent to %q; want /login?error=1", res.Header.Get("Location"))
+	}
+}
+
+func TestLoginRecordsAttempts(t *testing.T) {
+	store := db.NewMemoryStore()
+	h, _ := newAuthHandler(t, store, time.Now())
+
+	login(h, "ada", "correct horse")
+	login(h, "ada", "wrong")
+	login(h, "grace", "correct horse")
+
+	attempts, err := store.RecentLoginAttempts(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 3 || !attempts[2].Success || attempts[1].Success || attempts[0].Success {
+		t.Errorf("attempts = %+v; want a success then two failures", attempts)
+	}
+}
+
+func TestLoginLocksOutAfterFailures(t *testing.T) {
+	store := db.NewMemoryStore()
+	h, _ := newAuthHandler(t, store, time.Now())
+
+	for i := 0; i < h.cfg.LoginMaxFailures; i++ {
+		login(h, "ada", "wrong")
+	}
+	if res := login(h, "ada", "correct horse"); res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("login after %d failures = %d; want 429", h.cfg.LoginMaxFailures, res.StatusCode)
+	}
+}
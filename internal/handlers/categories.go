@@ -0,0 +1,179 @@
+// internal/handlers/categories.go
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+type CategoryHandler struct {
+	store db.Store
+	cfg   *config.Config
+}
+
+func NewCategoryHandler(store db.Store, cfg *config.Config) *CategoryHandler {
+	return &CategoryHandler{store: store, cfg: cfg}
+}
+
+// AdminCategoriesHandler lists the categories for editing and reordering
+func (h *CategoryHandler) AdminCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderCategories(w, r, PageData{})
+}
+
+func (h *CategoryHandler) renderCategories(w http.ResponseWriter, r *http.Request, data PageData) {
+	categories, err := h.store.ListCategories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/categories.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Title = "Categories"
+	data.ProjectCategories = categories
+	data.CSRFToken = csrf.Token(r)
+	data.IsAdmin = true
+	data.CurrentUser = session.CurrentUser(r.Context())
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// categoryFromForm reads a category's name, slug and visibility
+func categoryFromForm(r *http.Request) *models.Category {
+	return &models.Category{
+		Name:    strings.TrimSpace(r.FormValue("name")),
+		Slug:    strings.TrimSpace(r.FormValue("slug")),
+		Visible: r.FormValue("visible") != "",
+	}
+}
+
+// saveCategory reports the outcome of creating or updating a category,
+// re-rendering the list with a message when the form was rejected
+func (h *CategoryHandler) saveCategory(w http.ResponseWriter, r *http.Request, err error) {
+	if err == db.ErrCategoryExists {
+		h.renderCategories(w, r, PageData{Error: "Another category already has that name or slug"})
+		return
+	} else if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+// AdminCreateCategoryHandler adds a category at the end of the order
+func (h *CategoryHandler) AdminCreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	category := categoryFromForm(r)
+	if category.Name == "" {
+		h.renderCategories(w, r, PageData{Error: "Category name is required"})
+		return
+	}
+	h.saveCategory(w, r, h.store.As(auditActor(r)).CreateCategory(category))
+}
+
+// AdminUpdateCategoryHandler saves a category's name, slug and visibility
+func (h *CategoryHandler) AdminUpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	category := categoryFromForm(r)
+	category.ID = id
+	if category.Name == "" {
+		h.renderCategories(w, r, PageData{Error: "Category name is required"})
+		return
+	}
+	h.saveCategory(w, r, h.store.As(auditActor(r)).UpdateCategory(category))
+}
+
+// AdminReorderCategoriesHandler saves the category order. The form carries
+// category IDs as category_ids[] in display order.
+func (h *CategoryHandler) AdminReorderCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var categoryIDs []int64
+	for _, raw := range r.PostForm["category_ids[]"] {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			return
+		}
+		categoryIDs = append(categoryIDs, id)
+	}
+
+	if err := h.store.As(auditActor(r)).ReorderCategories(categoryIDs); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+// AdminDeleteCategoryHandler removes a category. Its tag stays on projects
+// as an ordinary tag.
+func (h *CategoryHandler) AdminDeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).DeleteCategory(id); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
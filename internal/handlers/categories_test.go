@@ -0,0 +1,69 @@
+// internal/handlers/categories_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+)
+
+func TestCategoryHandlers(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := NewCategoryHandler(store, testConfig(t))
+	editor := &models.User{ID: 7, Username: "ada", Role: models.RoleEditor}
+
+	r := asUser(request("POST", "/admin/categories/new", nil, url.Values{"name": {"Music Video"}, "visible": {"on"}}), editor)
+	if w := do(h.AdminCreateCategoryHandler, r); w.Code != http.StatusSeeOther {
+		t.Fatalf("create status = %d; want 303", w.Code)
+	}
+	category, err := store.GetCategoryBySlug("music-video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !category.Visible {
+		t.Error("created category is hidden")
+	}
+
+	entries, err := store.ListAuditLog(models.AuditFilter{Action: "category.create"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ActorName != "ada" {
+		t.Errorf("audit entries = %+v; want one by ada", entries)
+	}
+
+	r = asUser(request("POST", "/admin/categories/new", nil, url.Values{"name": {"music video"}}), editor)
+	w := do(h.AdminCreateCategoryHandler, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Another category already has that name or slug") {
+		t.Errorf("duplicate create = %d; want the form again with an error", w.Code)
+	}
+
+	id := strconv.FormatInt(category.ID, 10)
+	r = asUser(request("POST", "/admin/categories/"+id, map[string]string{"id": id}, url.Values{"name": {"Music Videos"}, "slug": {"music"}}), editor)
+	if w := do(h.AdminUpdateCategoryHandler, r); w.Code != http.StatusSeeOther {
+		t.Fatalf("update status = %d; want 303", w.Code)
+	}
+	if category, err = store.GetCategory(category.ID); err != nil {
+		t.Fatal(err)
+	}
+	if category.Name != "Music Videos" || category.Slug != "music" || category.Visible {
+		t.Errorf("updated category = %+v", category)
+	}
+
+	if w := do(h.AdminCategoriesHandler, asUser(request("GET", "/admin/categories", nil, nil), editor)); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Music Videos") {
+		t.Errorf("category list = %d; want it to show Music Videos", w.Code)
+	}
+
+	r = asUser(request("POST", "/admin/categories/"+id+"/delete", map[string]string{"id": id}, url.Values{}), editor)
+	if w := do(h.AdminDeleteCategoryHandler, r); w.Code != http.StatusSeeOther {
+		t.Fatalf("delete status = %d; want 303", w.Code)
+	}
+	if w := do(h.AdminDeleteCategoryHandler, r); w.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d; want 404", w.Code)
+	}
+}
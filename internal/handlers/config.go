@@ -2,44 +2,47 @@
 package handlers
 
 import (
-	"database/sql"
 	"html/template"
 	"net/http"
 	"path/filepath"
 	"time"
 
+	"voidcase/internal/config"
 	"voidcase/internal/db"
 	"voidcase/internal/models"
+	"voidcase/internal/session"
 
 	"github.com/gorilla/csrf"
 )
 
 type ConfigHandler struct {
-	db *db.DB
+	config db.Store
+	cfg    *config.Config
 }
 
-func NewConfigHandler(sqlDB *sql.DB) *ConfigHandler {
-	return &ConfigHandler{db: db.New(sqlDB)}
+func NewConfigHandler(store db.Store, cfg *config.Config) *ConfigHandler {
+	return &ConfigHandler{config: store, cfg: cfg}
 }
 
 func (h *ConfigHandler) AdminConfigHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		config, err := h.db.GetSiteConfig()
+		config, err := h.config.GetSiteConfig()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		tmpl := template.Must(template.ParseFiles(
-			filepath.Join("templates", "admin", "layout.html"),
-			filepath.Join("templates", "admin", "config.html"),
+			filepath.Join(h.cfg.TemplateDir, "admin", "layout.html"),
+			filepath.Join(h.cfg.TemplateDir, "admin", "config.html"),
 		))
 
 		data := PageData{
-			Title:      "Site Configuration",
-			SiteConfig: config,
-			CSRFToken:  csrf.Token(r),
-			IsAdmin:    true,
+			Title:       "Site Configuration",
+			SiteConfig:  config,
+			CSRFToken:   csrf.Token(r),
+			IsAdmin:     true,
+			CurrentUser: session.CurrentUser(r.Context()),
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
@@ -62,7 +65,7 @@ func (h *ConfigHandler) AdminConfigHandler(w http.ResponseWriter, r *http.Reques
 		UpdatedAt:    time.Now(),
 	}
 
-	if err := h.db.UpdateSiteConfig(config); err != nil {
+	if err := h.config.As(auditActor(r)).UpdateSiteConfig(config); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -2,39 +2,107 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
+	"net/http"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+	"voidcase/internal/utils"
 )
 
-func loadTemplates(themePath string, baseTemplate string, pageTemplates ...string) (*template.Template, error) {
+// loadTemplates parses an admin page or a themed page from dir, falling back
+// to the default theme when the requested theme lacks a template
+func loadTemplates(dir, themePath string, baseTemplate string, pageTemplates ...string) (*template.Template, error) {
 	var templates []string
 
 	// For admin templates, use admin directory directly
 	if strings.Contains(baseTemplate, "admin/") {
-		templates = append(templates, filepath.Join("templates", "admin", "layout.html"))
+		templates = append(templates, filepath.Join(dir, "admin", "layout.html"))
 		for _, tmpl := range pageTemplates {
-			templates = append(templates, filepath.Join("templates", "admin", strings.TrimPrefix(tmpl, "admin/")))
+			templates = append(templates, filepath.Join(dir, "admin", strings.TrimPrefix(tmpl, "admin/")))
 		}
 		return template.New("layout").Funcs(templateFuncs).ParseFiles(templates...)
 	}
 
 	// For themed templates, try theme path first
-	templates = append(templates, filepath.Join("templates/themes", themePath, "base.html"))
+	templates = append(templates, filepath.Join(dir, "themes", themePath, "base.html"))
 	for _, tmpl := range pageTemplates {
-		templates = append(templates, filepath.Join("templates/themes", themePath, tmpl))
+		templates = append(templates, filepath.Join(dir, "themes", themePath, tmpl))
 	}
 
 	tmpl, err := template.New("layout").Funcs(templateFuncs).ParseFiles(templates...)
 	if err != nil && themePath != "default" {
 		// Fallback to default theme
 		templates = []string{
-			filepath.Join("templates/themes/default/base.html"),
+			filepath.Join(dir, "themes", "default", "base.html"),
 		}
 		for _, tmpl := range pageTemplates {
-			templates = append(templates, filepath.Join("templates/themes/default", tmpl))
+			templates = append(templates, filepath.Join(dir, "themes", "default", tmpl))
 		}
 		return template.New("layout").Funcs(templateFuncs).ParseFiles(templates...)
 	}
 	return tmpl, err
 }
+
+// isAdmin reports whether the request carries a valid session cookie for
+// an account that is still enabled
+func isAdmin(store db.Store, cookies *session.Cookies, r *http.Request) bool {
+	token, err := cookies.Token(r)
+	if err != nil {
+		return false
+	}
+	return activeSession(store, token)
+}
+
+// activeSession reports whether token names an unexpired session whose
+// account is still enabled
+func activeSession(store db.Store, token string) bool {
+	s, err := store.GetSession(session.HashToken(token))
+	if err != nil {
+		return false
+	}
+	user, err := store.GetUser(s.UserID)
+	return err == nil && !user.Disabled()
+}
+
+// auditActor identifies the signed-in user making r for the audit log
+func auditActor(r *http.Request) models.Actor {
+	actor := models.Actor{IP: utils.ClientIP(r)}
+	if user := session.CurrentUser(r.Context()); user != nil {
+		id := user.ID
+		actor.UserID = &id
+		actor.Username = user.Username
+	}
+	return actor
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// parseUploadForm parses a multipart request and checks it against the
+// configured file count. The body size is bounded by middleware.LimitBody,
+// which also parses upload forms before csrf.Protect reads them. field
+// names the file input whose count is bounded.
+func parseUploadForm(r *http.Request, cfg *config.Config, field string) error {
+	if err := r.ParseMultipartForm(config.UploadMemory); err != nil {
+		return err
+	}
+	if n := len(r.MultipartForm.File[field]); n > cfg.MaxUploadFiles {
+		return fmt.Errorf("too many files: %d uploaded, at most %d allowed", n, cfg.MaxUploadFiles)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// internal/handlers/helpers_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/imaging"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testConfig returns a configuration that renders the server's templates
+// and keeps uploads in a temporary directory
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	cfg.TemplateDir = "../../cmd/server/templates"
+	cfg.CSRFKey = strings.Repeat("ab", 32)
+	cfg.SessionKey = strings.Repeat("cd", 32)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// request builds a request with the given route variables. A non-nil form
+// is sent as a urlencoded body.
+func request(method, target string, vars map[string]string, form url.Values) *http.Request {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	return r
+}
+
+// do serves r with handler and returns the response
+func do(handler http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// asUser returns r as made by the signed-in user u
+func asUser(r *http.Request, u *models.User) *http.Request {
+	return r.WithContext(session.WithUser(r.Context(), u))
+}
+
+// newProjectHandler returns a ProjectHandler on store that keeps uploads in
+// a temporary directory and stops its background jobs when the test ends
+func newProjectHandler(t *testing.T, store db.Store) *ProjectHandler {
+	t.Helper()
+	cfg := testConfig(t)
+	images, err := imaging.New(cfg.UploadsDir(), imaging.DefaultRenditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewProjectHandler(store, images, cfg)
+	t.Cleanup(h.Shutdown)
+	return h
+}
+
+// newAuthHandler returns an AuthHandler on store with its clock set to now
+// and an editor named ada with password "correct horse"
+func newAuthHandler(t *testing.T, store db.Store, now time.Time) (*AuthHandler, *models.User) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Username: "ada", PasswordHash: string(hash), Role: models.RoleEditor, CreatedAt: now}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewAuthHandler(store, testConfig(t))
+	t.Cleanup(h.Shutdown)
+	h.now = func() time.Time { return now }
+	return h, user
+}
+
+// login posts a username and password to h
+func login(h *AuthHandler, username, password string) *http.Response {
+	form := url.Values{"username": {username}, "password": {password}}
+	return do(h.LoginHandler, request("POST", "/login", nil, form)).Result()
+}
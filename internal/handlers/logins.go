@@ -0,0 +1,139 @@
+// internal/handlers/logins.go
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"voidcase/internal/models"
+	"voidcase/internal/utils"
+)
+
+// loginAttemptWindow is how far back failed sign-ins count towards a delay
+const loginAttemptWindow = 24 * time.Hour
+
+// loginAttemptRetention is how long sign-in attempts are kept for review
+const loginAttemptRetention = 30 * 24 * time.Hour
+
+// loginFreeFailures is how many failures are allowed before each further
+// attempt must wait
+const loginFreeFailures = 3
+
+// unknownUserHash is checked against the password given for a username with
+// no usable account, so that failing takes as long as for a real one. Its
+// cost matches the hashes of real passwords.
+const unknownUserHash = "$2a$12$QUetx9FVojkWJcrABvFYueETdQvQ8PvY4ST4p1CK4uwn4kav4sCbK"
+
+// loginLocks serializes sign-in attempts that share a username or address,
+// so parallel guesses cannot all pass the throttle before any failure is
+// recorded. The zero value is ready to use.
+type loginLocks struct {
+	mu    sync.Mutex
+	locks map[string]*loginLock
+}
+
+type loginLock struct {
+	sync.Mutex
+	// users counts the attempts holding or waiting for the lock
+	users int
+}
+
+// lock takes the lock of every key, in sorted order so two attempts cannot
+// each hold a lock the other waits for, and returns a func releasing them
+func (l *loginLocks) lock(keys ...string) func() {
+	sort.Strings(keys)
+	held := make([]*loginLock, len(keys))
+	for i, key := range keys {
+		l.mu.Lock()
+		if l.locks == nil {
+			l.locks = make(map[string]*loginLock)
+		}
+		lk := l.locks[key]
+		if lk == nil {
+			lk = &loginLock{}
+			l.locks[key] = lk
+		}
+		lk.users++
+		l.mu.Unlock()
+
+		lk.Lock()
+		held[i] = lk
+	}
+
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+			l.mu.Lock()
+			if held[i].users--; held[i].users == 0 {
+				delete(l.locks, keys[i])
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// lockLogin holds the sign-in lock for username and ip from checking the
+// throttle until the attempt is recorded, when the returned func is called
+func (h *AuthHandler) lockLogin(username, ip string) func() {
+	return h.logins.lock("user:"+strings.ToLower(username), "ip:"+ip)
+}
+
+// loginWait returns how long the next sign-in for username from ip must
+// wait, based on recent failures for either
+func (h *AuthHandler) loginWait(username, ip string) (time.Duration, error) {
+	now := h.now()
+	f, err := h.store.LoginFailures(strings.ToLower(username), ip, now.Add(-loginAttemptWindow))
+	if err != nil {
+		return 0, err
+	}
+	wait := h.backoff(f.Username, f.LastUsername, now)
+	if byIP := h.backoff(f.IP, f.LastIP, now); byIP > wait {
+		wait = byIP
+	}
+	return wait, nil
+}
+
+// backoff returns the wait remaining after failures, the latest at last.
+// The delay doubles from one second with each failure past the free ones,
+// and becomes the full lockout once the configured maximum is reached.
+func (h *AuthHandler) backoff(failures int, last, now time.Time) time.Duration {
+	if failures < loginFreeFailures {
+		return 0
+	}
+	lockout := h.cfg.LoginLockout.Duration
+	delay := lockout
+	if failures < h.cfg.LoginMaxFailures {
+		shift := failures - loginFreeFailures
+		if shift < 30 && time.Second<<shift < lockout {
+			delay = time.Second << shift
+		}
+	}
+	if wait := last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// refuseLogin reports a throttled attempt, rounding the wait up to whole
+// seconds
+func refuseLogin(w http.ResponseWriter, wait time.Duration) string {
+	wait = (wait + time.Second - 1).Truncate(time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)))
+	return "Too many failed attempts. Try again in " + wait.String() + "."
+}
+
+func (h *AuthHandler) recordLogin(r *http.Request, username string, success bool) {
+	if err := h.store.RecordLoginAttempt(&models.LoginAttempt{
+		Username:  strings.ToLower(username),
+		IP:        utils.ClientIP(r),
+		Success:   success,
+		CreatedAt: h.now(),
+	}); err != nil {
+		log.Printf("Login attempt error: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+// internal/handlers/media.go
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/imaging"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+// MediaHandler serves the admin media library, where stored assets can be
+// uploaded, attached to any project and deleted once nothing uses them
+type MediaHandler struct {
+	store  db.Store
+	images *imaging.Service
+	cfg    *config.Config
+}
+
+func NewMediaHandler(store db.Store, images *imaging.Service, cfg *config.Config) *MediaHandler {
+	return &MediaHandler{store: store, images: images, cfg: cfg}
+}
+
+// uploadMedia returns the library media for an uploaded file, generating
+// renditions only when the content has not been stored before. New media
+// has no ID until it is saved.
+func uploadMedia(store db.MediaStore, images *imaging.Service, fileHeader *multipart.FileHeader) (*models.Media, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if media, err := store.GetMediaByHash(imaging.Hash(data)); err == nil {
+		return media, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	media, err := images.Process(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileHeader.Filename, err)
+	}
+	return media, nil
+}
+
+// AdminMediaHandler lists the library. ?project={id} preselects the project
+// that attached media goes to.
+func (h *MediaHandler) AdminMediaHandler(w http.ResponseWriter, r *http.Request) {
+	media, err := h.store.ListMedia()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	projects, err := h.store.ListProjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var project *models.Project
+	if id, err := strconv.ParseInt(r.URL.Query().Get("project"), 10, 64); err == nil {
+		for i := range projects {
+			if projects[i].ID == id {
+				project = &projects[i]
+			}
+		}
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/media.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "Media Library",
+		Media:       media,
+		Projects:    projects,
+		Project:     project,
+		CSRFToken:   csrf.Token(r),
+		IsAdmin:     true,
+		CurrentUser: session.CurrentUser(r.Context()),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// AdminUploadMediaHandler adds the files in media[] to the library without
+// attaching them to a project
+func (h *MediaHandler) AdminUploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := parseUploadForm(r, h.cfg, "media[]"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, fileHeader := range r.MultipartForm.File["media[]"] {
+		media, err := uploadMedia(h.store, h.images, fileHeader)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if media.ID != 0 {
+			continue
+		}
+		if err := h.store.As(auditActor(r)).CreateMedia(media); err != nil {
+			h.images.Remove(media)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
+}
+
+// AdminAttachMediaHandler attaches the checked media_ids[] to project_id and
+// returns to that project's editor
+func (h *MediaHandler) AdminAttachMediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projectID, err := strconv.ParseInt(r.PostForm.Get("project_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	var mediaIDs []int64
+	for _, raw := range r.PostForm["media_ids[]"] {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid media ID", http.StatusBadRequest)
+			return
+		}
+		mediaIDs = append(mediaIDs, id)
+	}
+
+	if err := h.store.As(auditActor(r)).AttachMedia(projectID, mediaIDs); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectToEditor(w, r, projectID)
+}
+
+// AdminDeleteMediaHandler deletes media that no project or tag cover uses,
+// along with its files
+func (h *MediaHandler) AdminDeleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.store.As(auditActor(r)).DeleteMedia(id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err == db.ErrMediaInUse {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Delete files after successful DB transaction
+	h.images.Remove(media)
+
+	http.Redirect(w, r, "/admin/media", http.StatusSeeOther)
+}
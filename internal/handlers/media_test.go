@@ -0,0 +1,57 @@
+// internal/handlers/media_test.go
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/imaging"
+	"voidcase/internal/models"
+)
+
+func TestDeleteMediaUsedAsTagCover(t *testing.T) {
+	store := db.NewMemoryStore()
+	cfg := testConfig(t)
+	images, err := imaging.New(cfg.UploadsDir(), imaging.DefaultRenditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewMediaHandler(store, images, cfg)
+
+	media := &models.Media{Hash: "cover", Path: "media/cover.jpg", Format: "jpeg", CreatedAt: time.Now()}
+	if err := store.CreateMedia(media); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.CreateProject(t, store, "Night Drive", "Film")
+	tag, err := store.GetTagByName("Film")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.CoverMediaID = &media.ID
+	if err := store.UpdateTag(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	id := strconv.FormatInt(media.ID, 10)
+	del := request("POST", "/admin/media/"+id+"/delete", map[string]string{"id": id}, url.Values{})
+	if w := do(h.AdminDeleteMediaHandler, del); w.Code != http.StatusConflict {
+		t.Fatalf("deleting a tag cover status = %d; want 409", w.Code)
+	}
+
+	tag.CoverMediaID = nil
+	if err := store.UpdateTag(tag); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(h.AdminDeleteMediaHandler, del); w.Code != http.StatusSeeOther {
+		t.Fatalf("deleting unused media status = %d; want 303", w.Code)
+	}
+	if _, err := store.GetMediaByHash(media.Hash); err != sql.ErrNoRows {
+		t.Errorf("deleted media error = %v; want sql.ErrNoRows", err)
+	}
+}
@@ -2,71 +2,41 @@
 package handlers
 
 import (
-	"database/sql"
 	"html/template"
 	"log"
 	"net/http"
 	"path/filepath"
 
-	"voidcase/internal/models"
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/session"
 )
 
 type PageHandler struct {
-	db *sql.DB
+	store   db.Store
+	cfg     *config.Config
+	cookies *session.Cookies
 }
 
-func NewPageHandler(db *sql.DB) *PageHandler {
-	return &PageHandler{db: db}
-}
-
-func (h *PageHandler) isAdmin(r *http.Request) bool {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		return false
-	}
-	var exists bool
-	err = h.db.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM sessions 
-            WHERE id = ? AND expires_at > datetime('now')
-        )`, cookie.Value).Scan(&exists)
-	return err == nil && exists
-}
-
-func (h *PageHandler) getConfig() (*models.SiteConfig, error) {
-	config := &models.SiteConfig{}
-	err := h.db.QueryRow(`
-        SELECT about_text, contact_info, tracking_code, theme_name, updated_at 
-        FROM site_config WHERE id = 1
-    `).Scan(&config.AboutText, &config.ContactInfo, &config.TrackingCode,
-		&config.ThemeName, &config.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return &models.SiteConfig{ThemeName: "default"}, nil
-	}
-	return config, err
-}
-
-func (h *PageHandler) getNavigation() ([]string, error) {
-	// Reuse existing NavigationHandler
-	nav := NewNavigationHandler(h.db)
-	return nav.GetNavigation()
+func NewPageHandler(store db.Store, cfg *config.Config) *PageHandler {
+	return &PageHandler{store: store, cfg: cfg, cookies: session.NewCookies(cfg)}
 }
 
 func (h *PageHandler) AboutHandler(w http.ResponseWriter, r *http.Request) {
-	config, err := h.getConfig()
+	config, err := h.store.GetSiteConfig()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	nav, err := h.getNavigation()
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Use loadTemplates helper instead of direct template parsing
-	tmpl, err := loadTemplates(config.ThemeName, "base.html", "about.html")
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "about.html")
 	if err != nil {
 		log.Printf("Template error: %v", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -79,7 +49,7 @@ func (h *PageHandler) AboutHandler(w http.ResponseWriter, r *http.Request) {
 		Contact:    config.ContactInfo,
 		Navigation: nav,
 		Theme:      config.ThemeName,
-		IsAdmin:    h.isAdmin(r),
+		IsAdmin:    isAdmin(h.store, h.cookies, r),
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
@@ -88,15 +58,15 @@ func (h *PageHandler) AboutHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PageHandler) ContactHandler(w http.ResponseWriter, r *http.Request) {
-	config, err := h.getConfig()
+	config, err := h.store.GetSiteConfig()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	tmpl := template.Must(template.ParseFiles(
-		filepath.Join("templates", "themes", config.ThemeName, "base.html"),
-		filepath.Join("templates", "contact.html"),
+		filepath.Join(h.cfg.TemplateDir, "themes", config.ThemeName, "base.html"),
+		filepath.Join(h.cfg.TemplateDir, "contact.html"),
 	))
 
 	data := PageData{
@@ -104,7 +74,7 @@ func (h *PageHandler) ContactHandler(w http.ResponseWriter, r *http.Request) {
 		Theme:        config.ThemeName,
 		Contact:      config.ContactInfo,
 		TrackingCode: template.HTML(config.TrackingCode),
-		IsAdmin:      h.isAdmin(r),
+		IsAdmin:      isAdmin(h.store, h.cookies, r),
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
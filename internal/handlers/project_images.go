@@ -0,0 +1,161 @@
+// internal/handlers/project_images.go
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// imageRouteIDs parses the {id} and {imageID} route variables
+func imageRouteIDs(r *http.Request) (projectID, imageID int64, err error) {
+	vars := mux.Vars(r)
+	if projectID, err = strconv.ParseInt(vars["id"], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if raw, ok := vars["imageID"]; ok {
+		if imageID, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	return projectID, imageID, nil
+}
+
+// redirectToEditor sends the browser back to the project's edit page
+func redirectToEditor(w http.ResponseWriter, r *http.Request, projectID int64) {
+	http.Redirect(w, r, fmt.Sprintf("/admin/project/%d/edit", projectID), http.StatusSeeOther)
+}
+
+// AdminReorderImagesHandler saves a new image order. The form carries every
+// image ID of the project as image_ids[] in display order.
+func (h *ProjectHandler) AdminReorderImagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectID, _, err := imageRouteIDs(r)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var imageIDs []int64
+	for _, raw := range r.PostForm["image_ids[]"] {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid image ID", http.StatusBadRequest)
+			return
+		}
+		imageIDs = append(imageIDs, id)
+	}
+
+	if err := h.store.As(auditActor(r)).ReorderImages(projectID, imageIDs); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err == db.ErrImageOrder {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectToEditor(w, r, projectID)
+}
+
+// AdminUpdateImageHandler saves an image's caption and alt text
+func (h *ProjectHandler) AdminUpdateImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectID, imageID, err := imageRouteIDs(r)
+	if err != nil {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
+
+	img := &models.Image{
+		ID:        imageID,
+		ProjectID: projectID,
+		Caption:   r.FormValue("caption"),
+		AltText:   r.FormValue("alt_text"),
+	}
+	if err := h.store.As(auditActor(r)).UpdateImage(img); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectToEditor(w, r, projectID)
+}
+
+// AdminSetCoverImageHandler makes an image the project's cover
+func (h *ProjectHandler) AdminSetCoverImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectID, imageID, err := imageRouteIDs(r)
+	if err != nil {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).SetCoverImage(projectID, imageID); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectToEditor(w, r, projectID)
+}
+
+// AdminDeleteImageHandler detaches a single image from the project, deleting
+// its files if no other project uses the media
+func (h *ProjectHandler) AdminDeleteImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	projectID, imageID, err := imageRouteIDs(r)
+	if err != nil {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
+
+	orphan, err := h.store.As(auditActor(r)).DeleteImage(projectID, imageID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Delete files after successful DB transaction
+	if orphan != nil {
+		h.images.Remove(orphan)
+	}
+
+	redirectToEditor(w, r, projectID)
+}
@@ -2,48 +2,88 @@
 package handlers
 
 import (
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
-	"image"
-	"image/jpeg"
-	_ "image/png"
-	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/imaging"
 	"voidcase/internal/models"
+	"voidcase/internal/session"
 	"voidcase/internal/utils"
 
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
-	"github.com/nfnt/resize"
 )
 
 type ProjectHandler struct {
-	db *sql.DB
+	store    db.Store
+	images   *imaging.Service
+	cfg      *config.Config
+	cookies  *session.Cookies
+	previews *session.Previews
+	shutdown chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
 }
 
-func NewProjectHandler(db *sql.DB) *ProjectHandler {
-	return &ProjectHandler{db: db}
+// NewProjectHandler creates a project handler and starts publishing
+// scheduled projects and purging projects that have been in the trash longer
+// than the configured retention. Call Shutdown to stop it.
+func NewProjectHandler(store db.Store, images *imaging.Service, cfg *config.Config) *ProjectHandler {
+	h := &ProjectHandler{
+		store:    store,
+		images:   images,
+		cfg:      cfg,
+		cookies:  session.NewCookies(cfg),
+		previews: session.NewPreviews(cfg),
+		shutdown: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go h.backgroundRoutine()
+
+	return h
 }
 
+// AdminProjectsHandler lists every project in manual order, or the projects
+// matching the search box and filters when any is set
 func (h *ProjectHandler) AdminProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	projects, err := h.getAllProjects()
+	search, err := adminProjectSearch(r)
+	if err != nil {
+		http.Error(w, "Invalid year", http.StatusBadRequest)
+		return
+	}
+
+	projects, err := h.store.ListProjects()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	filterTags, filterYears := projectFilters(projects)
+
+	if search != nil {
+		results, err := h.store.SearchProjects(*search)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		projects = make([]models.Project, len(results))
+		for i, result := range results {
+			projects[i] = result.Project
+		}
+	}
 
-	tmpl, err := loadTemplates("", "admin/layout.html", "admin/projects.html")
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/projects.html")
 	if err != nil {
 		log.Printf("Template error: %v", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
@@ -51,10 +91,15 @@ func (h *ProjectHandler) AdminProjectsHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	data := PageData{
-		Title:     "Manage Projects",
-		Projects:  projects,
-		CSRFToken: csrf.Token(r),
-		IsAdmin:   true,
+		Title:           "Manage Projects",
+		Projects:        projects,
+		Search:          search,
+		FilterTags:      filterTags,
+		FilterYears:     filterYears,
+		ProjectStatuses: models.ProjectStatuses,
+		CSRFToken:       csrf.Token(r),
+		IsAdmin:         true,
+		CurrentUser:     session.CurrentUser(r.Context()),
 	}
 
 	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
@@ -62,9 +107,49 @@ func (h *ProjectHandler) AdminProjectsHandler(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// AdminReorderProjectsHandler saves the manual project order. The form
+// carries project IDs as project_ids[] in display order.
+func (h *ProjectHandler) AdminReorderProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var projectIDs []int64
+	for _, raw := range r.PostForm["project_ids[]"] {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		projectIDs = append(projectIDs, id)
+	}
+
+	if err := h.store.As(auditActor(r)).ReorderProjects(projectIDs); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+}
+
 func (h *ProjectHandler) AdminNewProjectHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "GET" {
-		tmpl, err := loadTemplates("", "admin/layout.html", "admin/project_form.html")
+		categories, err := h.store.ListCategories()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/project_form.html")
 		if err != nil {
 			log.Printf("Template error: %v", err)
 			http.Error(w, "Template error", http.StatusInternalServerError)
@@ -72,11 +157,13 @@ func (h *ProjectHandler) AdminNewProjectHandler(w http.ResponseWriter, r *http.R
 		}
 
 		data := PageData{
-			Title:          "New Project",
-			CSRFToken:      csrf.Token(r),
-			IsAdmin:        true,
-			CoreCategories: models.CoreCategories,
-			Project:        &models.Project{}, // Initialize empty project
+			Title:             "New Project",
+			CSRFToken:         csrf.Token(r),
+			IsAdmin:           true,
+			CurrentUser:       session.CurrentUser(r.Context()),
+			ProjectCategories: categories,
+			ProjectStatuses:   models.ProjectStatuses,
+			Project:           &models.Project{Status: models.StatusPublished},
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
@@ -87,65 +174,26 @@ func (h *ProjectHandler) AdminNewProjectHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	if err := parseUploadForm(r, h.cfg, "images[]"); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	project := &models.Project{
-		Title:       r.FormValue("title"),
-		Description: template.HTMLEscapeString(r.FormValue("description")),
-		VideoEmbed:  utils.SanitizeVideoEmbed(r.FormValue("video_embed")),
-		Date:        time.Now(),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	tx, err := h.db.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	// Insert project
-	result, err := tx.Exec(`
-        INSERT INTO projects (title, description, video_embed, date, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?)`,
-		project.Title, project.Description, project.VideoEmbed,
-		project.Date, project.CreatedAt, project.UpdatedAt)
+	project, err := projectFromForm(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	project.CreatedAt = project.UpdatedAt
 
-	projectID, err := result.LastInsertId()
+	images, err := h.processUploads(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	project.ID = projectID
-
-	// Handle tags
-	tags := strings.Split(r.FormValue("tags"), ",")
-	for _, tag := range tags {
-		tag = strings.TrimSpace(tag)
-		if tag == "" {
-			continue
-		}
-		if err := h.addProjectTag(tx, projectID, tag); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Handle images
-	if err := saveProjectWithImages(tx, r, project); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	if err := tx.Commit(); err != nil {
+	if err := h.store.As(auditActor(r)).CreateProject(project, images); err != nil {
+		h.discardUploads(images)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -153,129 +201,105 @@ func (h *ProjectHandler) AdminNewProjectHandler(w http.ResponseWriter, r *http.R
 	http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
 }
 
-func (h *ProjectHandler) addProjectTag(tx *sql.Tx, projectID int64, tagName string) error {
-	var tagID int64
-	err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
-	if err == sql.ErrNoRows {
-		result, err := tx.Exec("INSERT INTO tags (name) VALUES (?)", tagName)
-		if err != nil {
-			return err
-		}
-		tagID, err = result.LastInsertId()
-		if err != nil {
-			return err
-		}
-	} else if err != nil {
-		return err
+// projectFromForm builds a project from the submitted editor form, edited
+// by the signed-in user. The description is stored as entered and escaped
+// by the templates on output.
+func projectFromForm(r *http.Request) (*models.Project, error) {
+	var updatedBy *int64
+	if user := session.CurrentUser(r.Context()); user != nil {
+		updatedBy = &user.ID
 	}
-
-	_, err = tx.Exec("INSERT INTO project_tags (project_id, tag_id) VALUES (?, ?)", projectID, tagID)
-	return err
+	status, publishAt, err := formStatus(r)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Project{
+		Title:       r.FormValue("title"),
+		Slug:        r.FormValue("slug"),
+		Description: r.FormValue("description"),
+		VideoEmbed:  utils.SanitizeVideoEmbed(r.FormValue("video_embed")),
+		Date:        parseDate(r.FormValue("date")),
+		UpdatedAt:   time.Now(),
+		UpdatedBy:   updatedBy,
+		Status:      status,
+		PublishAt:   publishAt,
+		Featured:    r.FormValue("featured") != "",
+		Tags:        formTags(r),
+	}, nil
 }
 
-func sanitizeEmbed(embed string) string {
-	if !strings.Contains(embed, "youtube.com/embed") &&
-		!strings.Contains(embed, "player.vimeo.com/video") {
-		return ""
+// publishAtLayout is the format of the datetime-local publish time input,
+// in the server's time zone
+const publishAtLayout = "2006-01-02T15:04"
+
+// formStatus reads the publication status and, for scheduled projects, the
+// publish time
+func formStatus(r *http.Request) (models.ProjectStatus, *time.Time, error) {
+	status := models.ProjectStatus(r.FormValue("status"))
+	if status == "" {
+		status = models.StatusPublished
+	}
+	if !status.Valid() {
+		return "", nil, fmt.Errorf("unknown status %q", status)
+	}
+	if status != models.StatusScheduled {
+		return status, nil, nil
 	}
-	return embed
-}
 
-func parseDate(dateStr string) time.Time {
-	t, err := time.Parse("2006-01-02", dateStr)
+	publishAt, err := time.ParseInLocation(publishAtLayout, r.FormValue("publish_at"), time.Local)
 	if err != nil {
-		return time.Now()
+		return "", nil, errors.New("scheduled projects need a publish date and time")
 	}
-	return t
+	return status, &publishAt, nil
 }
 
-func (h *ProjectHandler) getAllProjects() ([]models.Project, error) {
-	rows, err := h.db.Query(`
-        SELECT id, title, description, video_embed, date, created_at, updated_at
-        FROM projects
-        ORDER BY date DESC`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// formTags collects the checked categories followed by any additional
+// comma-separated tags not already among them
+func formTags(r *http.Request) []string {
+	tags := append([]string(nil), r.PostForm["categories[]"]...)
 
-	var projects []models.Project
-	for rows.Next() {
-		var p models.Project
-		err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.VideoEmbed,
-			&p.Date, &p.CreatedAt, &p.UpdatedAt)
-		if err != nil {
-			return nil, err
+	for _, tag := range strings.Split(r.FormValue("custom_tags"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsFold(tags, tag) {
+			tags = append(tags, tag)
 		}
-		projects = append(projects, p)
 	}
-	return projects, nil
+	return tags
 }
 
-func (h *ProjectHandler) GetProjectByID(id int64) (*models.Project, error) {
-	project, err := h.getProjectWithTags(id)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get images
-	rows, err := h.db.Query(`
-        SELECT hash, path FROM images 
-        WHERE project_id = ? 
-        ORDER BY created_at DESC`, id)
-	if err != nil {
-		return nil, err
+// containsFold reports whether names holds name, ignoring case
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
 	}
-	defer rows.Close()
+	return false
+}
 
-	var images []models.Image
-	for rows.Next() {
-		var img models.Image
-		if err := rows.Scan(&img.Hash, &img.Path); err != nil {
-			return nil, err
+// discardUploads deletes the files of uploaded images whose media never
+// made it into the library. Media that was already stored is left alone.
+func (h *ProjectHandler) discardUploads(images []models.Image) {
+	for i := range images {
+		if _, err := h.store.GetMediaByHash(images[i].Hash); err == sql.ErrNoRows {
+			h.images.Remove(&images[i].Media)
 		}
-		images = append(images, img)
 	}
-	project.Images = images
-
-	return project, nil
 }
 
-func (h *ProjectHandler) getProjectWithTags(id int64) (*models.Project, error) {
-	project := &models.Project{}
-	err := h.db.QueryRow(`
-        SELECT id, title, description, video_embed, date, created_at, updated_at 
-        FROM projects WHERE id = ?`, id).Scan(
-		&project.ID, &project.Title, &project.Description, &project.VideoEmbed,
-		&project.Date, &project.CreatedAt, &project.UpdatedAt)
-	if err != nil {
-		return nil, err
+// removeMedia deletes the files of media removed from the library
+func (h *ProjectHandler) removeMedia(media []models.Media) {
+	for i := range media {
+		h.images.Remove(&media[i])
 	}
+}
 
-	// Get tags
-	rows, err := h.db.Query(`
-        SELECT t.name FROM tags t
-        JOIN project_tags pt ON t.id = pt.tag_id
-        WHERE pt.project_id = ?
-        ORDER BY CASE 
-            WHEN t.name = 'Commercial' THEN 1
-            WHEN t.name = 'Narrative' THEN 2
-            WHEN t.name = 'Music Video' THEN 3
-            WHEN t.name = 'Documentary' THEN 4
-            ELSE 5 END, t.name`, id)
+func parseDate(dateStr string) time.Time {
+	t, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return nil, err
-		}
-		project.Tags = append(project.Tags, tag)
+		return time.Now()
 	}
-	return project, nil
+	return t
 }
 
 func (h *ProjectHandler) AdminEditProjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -287,13 +311,28 @@ func (h *ProjectHandler) AdminEditProjectHandler(w http.ResponseWriter, r *http.
 	}
 
 	if r.Method == "GET" {
-		project, err := h.GetProjectByID(id)
+		project, err := h.store.GetProject(id)
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		revisions, err := h.store.ListProjectRevisions(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		categories, err := h.store.ListCategories()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		tmpl, err := loadTemplates("", "admin/layout.html", "admin/project_form.html")
+		tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/project_form.html")
 		if err != nil {
 			log.Printf("Template error: %v", err)
 			http.Error(w, "Template error", http.StatusInternalServerError)
@@ -301,11 +340,22 @@ func (h *ProjectHandler) AdminEditProjectHandler(w http.ResponseWriter, r *http.
 		}
 
 		data := PageData{
-			Title:          "Edit Project",
-			Project:        project,
-			CSRFToken:      csrf.Token(r),
-			CoreCategories: models.CoreCategories,
-			IsAdmin:        true,
+			Title:             "Edit Project",
+			Project:           project,
+			Revisions:         revisionViews(revisions, project),
+			CSRFToken:         csrf.Token(r),
+			ProjectCategories: categories,
+			ProjectStatuses:   models.ProjectStatuses,
+			IsAdmin:           true,
+			CurrentUser:       session.CurrentUser(r.Context()),
+		}
+		if project.Status != models.StatusPublished && project.Slug != "" {
+			token, err := h.previews.Token(project.ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data.PreviewURL = "/work/" + url.PathEscape(project.Slug) + "?preview=" + url.QueryEscape(token)
 		}
 
 		if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
@@ -317,66 +367,31 @@ func (h *ProjectHandler) AdminEditProjectHandler(w http.ResponseWriter, r *http.
 	}
 
 	// Handle POST - Update project
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
+	if err := parseUploadForm(r, h.cfg, "images[]"); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	tx, err := h.db.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	// Update project
-	_, err = tx.Exec(`
-        UPDATE projects 
-        SET title = ?, description = ?, video_embed = ?, date = ?, updated_at = ?
-        WHERE id = ?`,
-		r.FormValue("title"),
-		r.FormValue("description"),
-		sanitizeEmbed(r.FormValue("video_embed")),
-		parseDate(r.FormValue("date")),
-		time.Now(),
-		id)
+	project, err := projectFromForm(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	if err := h.handleProjectTags(tx, id, r); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	project.ID = id
 
-	// Delete existing tags
-	_, err = tx.Exec("DELETE FROM project_tags WHERE project_id = ?", id)
+	// Handle new images if any
+	images, err := h.processUploads(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Add new tags
-	tags := strings.Split(r.FormValue("tags"), ",")
-	for _, tag := range tags {
-		tag = strings.TrimSpace(tag)
-		if tag == "" {
-			continue
-		}
-		if err := h.addProjectTag(tx, id, tag); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Handle new images if any
-	if err := saveProjectWithImages(tx, r, &models.Project{ID: id}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.As(auditActor(r)).UpdateProject(project, images); err == sql.ErrNoRows {
+		h.discardUploads(images)
+		http.NotFound(w, r)
 		return
-	}
-
-	if err := tx.Commit(); err != nil {
+	} else if err != nil {
+		h.discardUploads(images)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -397,274 +412,158 @@ func (h *ProjectHandler) AdminDeleteProjectHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	tx, err := h.db.Begin()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.store.As(auditActor(r)).DeleteProject(id); err == sql.ErrNoRows {
+		http.NotFound(w, r)
 		return
-	}
-	defer tx.Rollback()
-
-	// Get image paths before deletion
-	rows, err := tx.Query("SELECT path FROM images WHERE project_id = ?", id)
-	if err != nil {
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var imagePaths []string
-	for rows.Next() {
-		var path string
-		if err := rows.Scan(&path); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		imagePaths = append(imagePaths, path)
-	}
+	http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+}
 
-	// Delete in order: project_tags, images, project
-	_, err = tx.Exec("DELETE FROM project_tags WHERE project_id = ?", id)
+func (h *ProjectHandler) HomeHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := h.store.GetSiteConfig()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = tx.Exec("DELETE FROM images WHERE project_id = ?", id)
+	projects, err := h.store.ListPublishedProjects(models.GetTheme(config.ThemeName).HomeOrder)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = tx.Exec("DELETE FROM projects WHERE id = ?", id)
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "home.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
 	}
 
-	// Delete image files after successful DB transaction
-	for _, path := range imagePaths {
-		if err := os.Remove(path); err != nil {
-			log.Printf("Failed to delete image file %s: %v", path, err)
-		}
+	data := PageData{
+		Title:        "Portfolio",
+		Projects:     projects,
+		Navigation:   nav,
+		Theme:        config.ThemeName,
+		About:        config.AboutText,
+		TrackingCode: template.HTML(config.TrackingCode),
+		IsAdmin:      isAdmin(h.store, h.cookies, r),
 	}
 
-	http.Redirect(w, r, "/admin/projects", http.StatusSeeOther)
+	// Change "base" to "layout" to match the base template definition
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+		return
+	}
 }
 
-func (h *ProjectHandler) HomeHandler(w http.ResponseWriter, r *http.Request) {
-	projects, err := h.getAllProjects()
-	if err != nil {
+// ProjectDetailHandler renders a single project at its canonical /work/{slug}
+// URL. Projects that are not published or unlisted are shown only with a
+// valid ?preview= token.
+func (h *ProjectHandler) ProjectDetailHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.store.GetProjectBySlug(mux.Vars(r)["slug"])
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !project.Status.Viewable() && !h.previews.Valid(r.URL.Query().Get("preview"), project.ID) {
+		http.NotFound(w, r)
+		return
+	}
+	if project.Status != models.StatusPublished {
+		// Keep previews and unlisted projects out of search engines
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
 
-	nav, err := NewNavigationHandler(h.db).GetNavigation()
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	config, err := h.getConfig()
+	config, err := h.store.GetSiteConfig()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Use themed template loading
-	themePath := filepath.Join("templates", "themes", config.ThemeName)
-	tmpl, err := template.ParseFiles(
-		filepath.Join(themePath, "base.html"),
-		filepath.Join(themePath, "home.html"),
-	)
-
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "project.html")
 	if err != nil {
-		if config.ThemeName != "default" {
-			// Fallback to default theme
-			themePath = filepath.Join("templates", "themes", "default")
-			tmpl, err = template.ParseFiles(
-				filepath.Join(themePath, "base.html"),
-				filepath.Join(themePath, "home.html"),
-			)
-			if err != nil {
-				log.Printf("Template error: %v", err)
-				http.Error(w, "Template error", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			log.Printf("Template error: %v", err)
-			http.Error(w, "Template error", http.StatusInternalServerError)
-			return
-		}
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
 	}
 
 	data := PageData{
-		Title:      "Portfolio",
-		Projects:   projects,
-		Navigation: nav,
-		Theme:      config.ThemeName,
-		About:      config.AboutText,
-		IsAdmin:    h.isAdmin(r),
+		Title:        project.Title,
+		Project:      project,
+		Navigation:   nav,
+		Theme:        config.ThemeName,
+		TrackingCode: template.HTML(config.TrackingCode),
+		IsAdmin:      isAdmin(h.store, h.cookies, r),
 	}
 
-	// Change "base" to "layout" to match the base template definition
 	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
 		log.Printf("Template execution error: %v", err)
 		http.Error(w, "Template execution error", http.StatusInternalServerError)
-		return
 	}
 }
 
-func (h *ProjectHandler) handleProjectTags(tx *sql.Tx, projectID int64, r *http.Request) error {
-	// Delete existing tags
-	if _, err := tx.Exec("DELETE FROM project_tags WHERE project_id = ?", projectID); err != nil {
-		return err
-	}
-
-	// Handle core categories first
-	for _, cat := range r.PostForm["categories[]"] {
-		if err := h.addProjectTag(tx, projectID, cat); err != nil {
-			return err
-		}
-	}
-
-	// Handle custom tags
-	customTags := strings.Split(r.FormValue("custom_tags"), ",")
-	for _, tag := range customTags {
-		tag = strings.TrimSpace(tag)
-		if tag != "" && !models.IsCoreCategory(tag) {
-			if err := h.addProjectTag(tx, projectID, tag); err != nil {
-				return err
-			}
-		}
+// ProjectRedirectHandler permanently redirects legacy /project/{id} links to
+// the project's slug URL
+func (h *ProjectHandler) ProjectRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
 	}
-	return nil
-}
 
-func (h *ProjectHandler) getConfig() (*models.SiteConfig, error) {
-	config := &models.SiteConfig{}
-	err := h.db.QueryRow(`
-        SELECT about_text, contact_info, tracking_code, theme_name, updated_at 
-        FROM site_config WHERE id = 1
-    `).Scan(&config.AboutText, &config.ContactInfo, &config.TrackingCode,
-		&config.ThemeName, &config.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return &models.SiteConfig{ThemeName: "default"}, nil
+	project, err := h.store.GetProject(id)
+	if err == sql.ErrNoRows || (err == nil && (project.Slug == "" || !project.Status.Viewable())) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	return config, err
-}
 
-func (h *ProjectHandler) isAdmin(r *http.Request) bool {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		return false
-	}
-	// Verify session in database
-	var exists bool
-	err = h.db.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM sessions 
-            WHERE id = ? AND expires_at > datetime('now')
-        )`, cookie.Value).Scan(&exists)
-	return err == nil && exists
+	http.Redirect(w, r, "/work/"+url.PathEscape(project.Slug), http.StatusMovedPermanently)
 }
 
-func saveProjectWithImages(tx *sql.Tx, r *http.Request, project *models.Project) error {
+// processUploads runs every file in the images[] form field through the
+// imaging pipeline and returns the image records to attach. Files whose
+// content is already in the media library reuse the stored media.
+func (h *ProjectHandler) processUploads(r *http.Request) ([]models.Image, error) {
 	if r.MultipartForm == nil || r.MultipartForm.File["images[]"] == nil {
-		return nil
-	}
-
-	files := r.MultipartForm.File["images[]"]
-	uploadDir := filepath.Join("data", "uploads", "images")
-	thumbsDir := filepath.Join("data", "uploads", "thumbnails")
-
-	// Create directories if they don't exist
-	for _, dir := range []string{uploadDir, thumbsDir} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
-		}
+		return nil, nil
 	}
 
-	// Process all images first
-	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
+	var images []models.Image
+	seen := make(map[string]bool)
+	for _, fileHeader := range r.MultipartForm.File["images[]"] {
+		media, err := uploadMedia(h.store, h.images, fileHeader)
 		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
-		}
-		defer file.Close()
-
-		// Hash the file contents
-		hash := sha256.New()
-		if _, err := io.Copy(hash, file); err != nil {
-			return fmt.Errorf("failed to hash file: %w", err)
-		}
-		contentHash := hex.EncodeToString(hash.Sum(nil))
-
-		// Reset file pointer
-		if _, err := file.Seek(0, 0); err != nil {
-			return fmt.Errorf("failed to reset file pointer: %w", err)
-		}
-
-		ext := ".jpg" // Force jpg extension for consistency
-		newPath := filepath.Join(uploadDir, contentHash+ext)
-		thumbPath := filepath.Join(thumbsDir, contentHash+ext)
-
-		// Save original
-		if err := saveOriginalFile(file, newPath); err != nil {
-			return fmt.Errorf("failed to save original file: %w", err)
-		}
-
-		// Reset file pointer for thumbnail
-		if _, err := file.Seek(0, 0); err != nil {
-			return fmt.Errorf("failed to reset file pointer: %w", err)
-		}
-
-		// Create thumbnail
-		if err := createThumbnail(file, thumbPath); err != nil {
-			return fmt.Errorf("failed to create thumbnail: %w", err)
+			h.discardUploads(images)
+			return nil, err
 		}
-
-		// Save image record in database
-		_, err = tx.Exec(`
-            INSERT INTO images (project_id, hash, path, created_at)
-            VALUES (?, ?, ?, ?)`,
-			project.ID, contentHash, newPath, time.Now())
-		if err != nil {
-			return fmt.Errorf("failed to save image record: %w", err)
+		if seen[media.Hash] {
+			continue
 		}
+		seen[media.Hash] = true
+		images = append(images, models.Image{CreatedAt: time.Now(), Media: *media})
 	}
-
-	return nil
-}
-
-func saveOriginalFile(src multipart.File, destPath string) error {
-	dst, err := os.Create(destPath)
-	if err != nil {
-		return err
-	}
-	defer dst.Close()
-
-	_, err = io.Copy(dst, src)
-	return err
-}
-
-func createThumbnail(src multipart.File, destPath string) error {
-	img, _, err := image.Decode(src)
-	if err != nil {
-		return err
-	}
-
-	thumbnail := resize.Resize(300, 0, img, resize.Lanczos3)
-
-	dst, err := os.Create(destPath)
-	if err != nil {
-		return err
-	}
-	defer dst.Close()
-
-	return jpeg.Encode(dst, thumbnail, &jpeg.Options{Quality: 85})
+	return images, nil
 }
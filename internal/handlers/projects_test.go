@@ -0,0 +1,85 @@
+// internal/handlers/projects_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestHomeHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newProjectHandler(t, store)
+	dbtest.CreateProject(t, store, "Night Drive", "Film")
+	dbtest.CreateProject(t, store, "Harbour", "Stills")
+
+	w := do(h.HomeHandler, request("GET", "/", nil, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Night Drive", `href="/work/night-drive"`, "Harbour", "Stills"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("home page is missing %q", want)
+		}
+	}
+}
+
+func TestProjectDetailHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newProjectHandler(t, store)
+	p := dbtest.CreateProject(t, store, "Night Drive", "Film")
+
+	w := do(h.ProjectDetailHandler, request("GET", "/work/night-drive", map[string]string{"slug": "night-drive"}, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Night Drive") {
+		t.Fatalf("detail page = %d; want 200 showing the project", w.Code)
+	}
+	if w := do(h.ProjectDetailHandler, request("GET", "/work/missing", map[string]string{"slug": "missing"}, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("missing slug status = %d; want 404", w.Code)
+	}
+
+	id := strconv.FormatInt(p.ID, 10)
+	res := do(h.ProjectRedirectHandler, request("GET", "/project/"+id, map[string]string{"id": id}, nil)).Result()
+	if res.StatusCode != http.StatusMovedPermanently || res.Header.Get("Location") != "/work/night-drive" {
+		t.Errorf("legacy link = %d to %q; want 301 to /work/night-drive", res.StatusCode, res.Header.Get("Location"))
+	}
+	missing := strconv.FormatInt(p.ID+1, 10)
+	if w := do(h.ProjectRedirectHandler, request("GET", "/project/"+missing, map[string]string{"id": missing}, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("legacy link to a missing project status = %d; want 404", w.Code)
+	}
+}
+
+func TestProjectDetailHandlerPreview(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newProjectHandler(t, store)
+	draft := &models.Project{Title: "Night Drive", Status: models.StatusDraft}
+	if err := store.CreateProject(draft, nil); err != nil {
+		t.Fatal(err)
+	}
+	other := dbtest.CreateProject(t, store, "Harbour")
+
+	detail := func(token string) int {
+		r := request("GET", "/work/night-drive?preview="+url.QueryEscape(token), map[string]string{"slug": "night-drive"}, nil)
+		return do(h.ProjectDetailHandler, r).Code
+	}
+	if code := detail(""); code != http.StatusNotFound {
+		t.Errorf("draft without a preview token = %d; want 404", code)
+	}
+	token, err := h.previews.Token(draft.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := detail(token); code != http.StatusOK {
+		t.Errorf("draft with its preview token = %d; want 200", code)
+	}
+	otherToken, _ := h.previews.Token(other.ID)
+	if code := detail(otherToken); code != http.StatusNotFound {
+		t.Errorf("draft with another project's token = %d; want 404", code)
+	}
+}
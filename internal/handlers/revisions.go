@@ -0,0 +1,114 @@
+// internal/handlers/revisions.go
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/mux"
+)
+
+// FieldChange is a field that differs between a revision and the current
+// project
+type FieldChange struct {
+	Field    string
+	Revision string
+	Current  string
+}
+
+// RevisionView is a project revision with what restoring it would change
+type RevisionView struct {
+	models.ProjectRevision
+	Changes []FieldChange
+}
+
+// revisionViews compares each revision with the current state of p
+func revisionViews(revisions []models.ProjectRevision, p *models.Project) []RevisionView {
+	views := make([]RevisionView, len(revisions))
+	for i, rev := range revisions {
+		views[i] = RevisionView{ProjectRevision: rev, Changes: revisionChanges(&rev, p)}
+	}
+	return views
+}
+
+func revisionChanges(rev *models.ProjectRevision, p *models.Project) []FieldChange {
+	var changes []FieldChange
+	add := func(field, revision, current string) {
+		if revision != current {
+			changes = append(changes, FieldChange{Field: field, Revision: revision, Current: current})
+		}
+	}
+	add("Title", rev.Title, p.Title)
+	add("URL Slug", rev.Slug, p.Slug)
+	add("Date", rev.Date.Format("2006-01-02"), p.Date.Format("2006-01-02"))
+	add("Description", rev.Description, p.Description)
+	add("Video Embed", rev.VideoEmbed, p.VideoEmbed)
+	add("Tags", strings.Join(rev.Tags, ", "), strings.Join(p.Tags, ", "))
+	revImages, currentImages := imagePositions(rev.ImageIDs, p.Images)
+	add("Images", revImages, currentImages)
+	return changes
+}
+
+// imagePositions describes an image order by the current position of each
+// image, numbered from 1, so orders can be compared at a glance. Images no
+// longer attached are shown as removed.
+func imagePositions(order []int64, images []models.Image) (string, string) {
+	position := make(map[int64]int, len(images))
+	current := make([]string, len(images))
+	for i, img := range images {
+		position[img.ID] = i + 1
+		current[i] = strconv.Itoa(i + 1)
+	}
+
+	revision := make([]string, len(order))
+	for i, id := range order {
+		if n, ok := position[id]; ok {
+			revision[i] = strconv.Itoa(n)
+		} else {
+			revision[i] = "removed"
+		}
+	}
+	return strings.Join(revision, ", "), strings.Join(current, ", ")
+}
+
+// AdminRestoreRevisionHandler saves a revision as the project's current
+// state
+func (h *ProjectHandler) AdminRestoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vars := mux.Vars(r)
+	projectID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	revisionID, err := strconv.ParseInt(vars["revisionID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision ID", http.StatusBadRequest)
+		return
+	}
+
+	var updatedBy *int64
+	if user := session.CurrentUser(r.Context()); user != nil {
+		updatedBy = &user.ID
+	}
+	if err := h.store.As(auditActor(r)).RestoreProjectRevision(
+		projectID, revisionID, time.Now(), updatedBy); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	redirectToEditor(w, r, projectID)
+}
@@ -0,0 +1,70 @@
+// internal/handlers/revisions_test.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestAdminRestoreRevisionHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newProjectHandler(t, store)
+	ada := store.AddUser(models.User{Username: "ada", Role: models.RoleEditor})
+	p := dbtest.CreateProject(t, store, "Night Drive", "Film")
+	edited, _ := store.GetProject(p.ID)
+	edited.Title = "Pier"
+	if err := store.UpdateProject(edited, nil); err != nil {
+		t.Fatal(err)
+	}
+	revisions, _ := store.ListProjectRevisions(p.ID)
+
+	restore := func(revisionID int64) *http.Response {
+		vars := map[string]string{"id": fmt.Sprint(p.ID), "revisionID": fmt.Sprint(revisionID)}
+		target := fmt.Sprintf("/admin/project/%d/revisions/%d/restore", p.ID, revisionID)
+		return do(h.AdminRestoreRevisionHandler, asUser(request("POST", target, vars, nil), &ada)).Result()
+	}
+
+	if res := restore(revisions[0].ID + 100); res.StatusCode != http.StatusNotFound {
+		t.Errorf("restoring a missing revision = %d; want 404", res.StatusCode)
+	}
+
+	res := restore(revisions[0].ID)
+	if want := fmt.Sprintf("/admin/project/%d/edit", p.ID); res.StatusCode != http.StatusSeeOther ||
+		res.Header.Get("Location") != want {
+		t.Fatalf("restore = %d to %q; want 303 to %s", res.StatusCode, res.Header.Get("Location"), want)
+	}
+	got, _ := store.GetProject(p.ID)
+	if got.Title != "Night Drive" || got.UpdatedBy == nil || *got.UpdatedBy != ada.ID {
+		t.Errorf("project = %q updated by %v; want Night Drive restored by ada", got.Title, got.UpdatedBy)
+	}
+	entries, _ := store.ListAuditLog(models.AuditFilter{Action: "project.revert"})
+	if len(entries) != 1 || entries[0].ActorID == nil || *entries[0].ActorID != ada.ID {
+		t.Errorf("revert entries = %+v; want one by ada", entries)
+	}
+}
+
+func TestRevisionChanges(t *testing.T) {
+	p := &models.Project{Title: "Pier", Slug: "pier", Tags: []string{"Film"},
+		Images: []models.Image{{ID: 1}, {ID: 2}}}
+	rev := &models.ProjectRevision{Title: "Night Drive", Slug: "pier", Tags: []string{"Film"},
+		ImageIDs: []int64{2, 3, 1}}
+
+	changes := revisionChanges(rev, p)
+	want := []FieldChange{
+		{Field: "Title", Revision: "Night Drive", Current: "Pier"},
+		{Field: "Images", Revision: "2, removed, 1", Current: "1, 2"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v; want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v; want %+v", i, changes[i], want[i])
+		}
+	}
+}
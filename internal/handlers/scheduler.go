@@ -0,0 +1,62 @@
+// internal/handlers/scheduler.go
+package handlers
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// publishInterval is how often scheduled projects are checked, which
+	// bounds how late after its publish time a project goes live
+	publishInterval = time.Minute
+
+	// purgeInterval is how often projects past the trash retention are purged
+	purgeInterval = 6 * time.Hour
+)
+
+// backgroundRoutine publishes scheduled projects and empties the trash until
+// Shutdown is called
+func (h *ProjectHandler) backgroundRoutine() {
+	defer close(h.stopped)
+
+	// Run both once at startup so projects due while the server was down go
+	// live and a server restarted more often than purgeInterval still
+	// empties the trash
+	h.publishScheduled()
+	h.purgeTrash()
+
+	publish := time.NewTicker(publishInterval)
+	defer publish.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-publish.C:
+			h.publishScheduled()
+		case <-purge.C:
+			h.purgeTrash()
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+// Shutdown stops the scheduler and trash purge and waits for a running job to
+// finish. It is safe to call more than once.
+func (h *ProjectHandler) Shutdown() {
+	h.once.Do(func() { close(h.shutdown) })
+	<-h.stopped
+}
+
+func (h *ProjectHandler) publishScheduled() {
+	n, err := h.store.PublishScheduledProjects(time.Now())
+	if err != nil {
+		log.Printf("Scheduled publish error: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Published %d scheduled project(s)", n)
+	}
+}
@@ -0,0 +1,115 @@
+// internal/handlers/search.go
+package handlers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"voidcase/internal/models"
+)
+
+// SearchHandler renders the public search page, listing published projects
+// matching ?q= with highlighted snippets
+func (h *ProjectHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	search := &models.ProjectSearch{
+		Query:  strings.TrimSpace(r.URL.Query().Get("q")),
+		Listed: true,
+	}
+
+	var results []models.SearchResult
+	if search.Query != "" {
+		var err error
+		if results, err = h.store.SearchProjects(*search); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	config, err := h.store.GetSiteConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "search.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	title := "Search"
+	if search.Query != "" {
+		title = "Search - " + search.Query
+	}
+	data := PageData{
+		Title:         title,
+		Search:        search,
+		SearchResults: results,
+		Navigation:    nav,
+		Theme:         config.ThemeName,
+		TrackingCode:  template.HTML(config.TrackingCode),
+		IsAdmin:       isAdmin(h.store, h.cookies, r),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// adminProjectSearch reads the project list's search box and filters,
+// returning nil when none is set
+func adminProjectSearch(r *http.Request) (*models.ProjectSearch, error) {
+	q := r.URL.Query()
+	search := &models.ProjectSearch{
+		Query:  strings.TrimSpace(q.Get("q")),
+		Tag:    q.Get("tag"),
+		Status: models.ProjectStatus(q.Get("status")),
+	}
+	if raw := q.Get("year"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, err
+		}
+		search.Year = year
+	}
+	if search.Query == "" && search.Tag == "" && search.Status == "" && search.Year == 0 {
+		return nil, nil
+	}
+	return search, nil
+}
+
+// projectFilters lists the tags and years of projects to offer as filters,
+// years newest first
+func projectFilters(projects []models.Project) ([]string, []int) {
+	seenTags := make(map[string]bool)
+	seenYears := make(map[int]bool)
+	var tags []string
+	var years []int
+	for _, p := range projects {
+		for _, tag := range p.Tags {
+			if !seenTags[strings.ToLower(tag)] {
+				seenTags[strings.ToLower(tag)] = true
+				tags = append(tags, tag)
+			}
+		}
+		if year := p.Date.Year(); !seenYears[year] {
+			seenYears[year] = true
+			years = append(years, year)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i]) < strings.ToLower(tags[j]) })
+	sort.Sort(sort.Reverse(sort.IntSlice(years)))
+	return tags, years
+}
@@ -0,0 +1,123 @@
+// internal/handlers/sessions.go
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"voidcase/internal/session"
+	"voidcase/internal/utils"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+// maxUserAgentLength bounds the User-Agent kept with a session
+const maxUserAgentLength = 256
+
+// SessionView is a signed-in device as listed on the sessions page
+type SessionView struct {
+	ID       int64
+	Browser  string
+	Platform string
+	IP       string
+	Created  string
+	LastSeen string
+	Current  bool
+}
+
+// AccountSessionsHandler lists the devices the signed-in user is signed in
+// on
+func (h *UserHandler) AccountSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := session.CurrentUser(r.Context())
+	current := session.CurrentSession(r.Context())
+
+	sessions, err := h.store.ListUserSessions(user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]SessionView, len(sessions))
+	for i, s := range sessions {
+		browser, platform := utils.ParseUserAgent(s.UserAgent)
+		views[i] = SessionView{
+			ID:       s.ID,
+			Browser:  browser,
+			Platform: platform,
+			IP:       s.IP,
+			Created:  s.CreatedAt.Format("2006-01-02 15:04"),
+			LastSeen: s.LastSeenAt.Format("2006-01-02 15:04"),
+			Current:  current != nil && s.ID == current.ID,
+		}
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/sessions.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "Sessions",
+		Sessions:    views,
+		CSRFToken:   csrf.Token(r),
+		IsAdmin:     true,
+		CurrentUser: user,
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// RevokeSessionHandler signs the user out on one device. Revoking the
+// current session signs them out here too.
+func (h *UserHandler) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	user := session.CurrentUser(r.Context())
+	if err := h.store.RevokeSession(user.ID, id); err == sql.ErrNoRows {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if current := session.CurrentSession(r.Context()); current != nil && current.ID == id {
+		h.cookies.Clear(w)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/account/sessions", http.StatusSeeOther)
+}
+
+// RevokeAllSessionsHandler signs the user out on every device, including
+// this one
+func (h *UserHandler) RevokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.store.DeleteUserSessions(session.CurrentUser(r.Context()).ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.cookies.Clear(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
@@ -0,0 +1,119 @@
+// internal/handlers/sessions_test.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+)
+
+// startSession saves a session for u under token
+func startSession(t *testing.T, store db.Store, u *models.User, token string) *models.Session {
+	t.Helper()
+	now := time.Now()
+	s := &models.Session{UserID: u.ID, TokenHash: session.HashToken(token),
+		CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := store.CreateSession(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// withSession returns r as made by u on the device holding s
+func withSession(r *http.Request, u *models.User, s *models.Session) *http.Request {
+	return asUser(r.WithContext(session.WithSession(r.Context(), s)), u)
+}
+
+func TestRevokeSessionHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	ada := store.AddUser(models.User{Username: "ada", Role: models.RoleEditor})
+	grace := store.AddUser(models.User{Username: "grace", Role: models.RoleEditor})
+	h := NewUserHandler(store, testConfig(t))
+	current := startSession(t, store, &ada, "laptop")
+	phone := startSession(t, store, &ada, "phone")
+	other := startSession(t, store, &grace, "grace")
+
+	revoke := func(s *models.Session) *http.Response {
+		id := fmt.Sprint(s.ID)
+		r := request("POST", "/admin/account/sessions/"+id+"/revoke", map[string]string{"id": id}, nil)
+		return do(h.RevokeSessionHandler, withSession(r, &ada, current)).Result()
+	}
+
+	if res := revoke(other); res.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking another user's session = %d; want 404", res.StatusCode)
+	}
+	res := revoke(phone)
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/admin/account/sessions" {
+		t.Errorf("revoking another device = %d to %q; want 303 back to the list",
+			res.StatusCode, res.Header.Get("Location"))
+	}
+	if _, err := store.GetSession(phone.TokenHash); err == nil {
+		t.Error("revoked session still valid")
+	}
+	for _, s := range []*models.Session{current, other} {
+		if _, err := store.GetSession(s.TokenHash); err != nil {
+			t.Errorf("session %d ended with another: %v", s.ID, err)
+		}
+	}
+
+	res = revoke(current)
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/login" {
+		t.Errorf("revoking this device = %d to %q; want 303 to /login", res.StatusCode, res.Header.Get("Location"))
+	}
+	if c := res.Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("cookies = %v; want the session cookie cleared", c)
+	}
+}
+
+func TestRevokeAllSessionsHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	ada := store.AddUser(models.User{Username: "ada", Role: models.RoleEditor})
+	grace := store.AddUser(models.User{Username: "grace", Role: models.RoleEditor})
+	h := NewUserHandler(store, testConfig(t))
+	current := startSession(t, store, &ada, "laptop")
+	startSession(t, store, &ada, "phone")
+	startSession(t, store, &grace, "grace")
+
+	r := withSession(request("POST", "/admin/account/sessions/revoke-all", nil, nil), &ada, current)
+	res := do(h.RevokeAllSessionsHandler, r).Result()
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/login" {
+		t.Errorf("revoke all = %d to %q; want 303 to /login", res.StatusCode, res.Header.Get("Location"))
+	}
+	if sessions, _ := store.ListUserSessions(ada.ID); len(sessions) != 0 {
+		t.Errorf("ada has %d sessions; want none", len(sessions))
+	}
+	if sessions, _ := store.ListUserSessions(grace.ID); len(sessions) != 1 {
+		t.Errorf("grace has %d sessions; want hers kept", len(sessions))
+	}
+}
+
+func TestIsAdminRejectsDisabledAccount(t *testing.T) {
+	store := db.NewMemoryStore()
+	cookies := session.NewCookies(testConfig(t))
+	disabledAt := time.Now()
+	for _, tc := range []struct {
+		user models.User
+		want bool
+	}{
+		{models.User{Username: "ada", Role: models.RoleViewer}, true},
+		{models.User{Username: "grace", Role: models.RoleEditor, DisabledAt: &disabledAt}, false},
+	} {
+		u := store.AddUser(tc.user)
+		startSession(t, store, &u, u.Username)
+		rec := httptest.NewRecorder()
+		if err := cookies.Set(rec, u.Username, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		r := request("GET", "/", nil, nil)
+		r.AddCookie(rec.Result().Cookies()[0])
+		if got := isAdmin(store, cookies, r); got != tc.want {
+			t.Errorf("isAdmin for %s = %v; want %v", u.Username, got, tc.want)
+		}
+	}
+}
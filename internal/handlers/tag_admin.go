@@ -0,0 +1,243 @@
+// internal/handlers/tag_admin.go
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+// AdminTagsHandler lists every tag with the number of projects carrying it
+func (h *TagHandler) AdminTagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.store.ListTags()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/tags.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "Tags",
+		Tags:        tags,
+		CSRFToken:   csrf.Token(r),
+		IsAdmin:     true,
+		CurrentUser: session.CurrentUser(r.Context()),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// AdminEditTagHandler shows a tag's editor and saves its name, description,
+// cover image and parent
+func (h *TagHandler) AdminEditTagHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.store.GetTag(id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != "POST" {
+		h.renderTag(w, r, tag, "")
+		return
+	}
+
+	tag.Name = strings.TrimSpace(r.FormValue("name"))
+	tag.Description = strings.TrimSpace(r.FormValue("description"))
+	tag.CoverMediaID = nil
+	if raw := r.FormValue("cover_media_id"); raw != "" {
+		mediaID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid media ID", http.StatusBadRequest)
+			return
+		}
+		tag.CoverMediaID = &mediaID
+	}
+	tag.ParentID = nil
+	if raw := r.FormValue("parent_id"); raw != "" {
+		parentID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid parent tag", http.StatusBadRequest)
+			return
+		}
+		tag.ParentID = &parentID
+	}
+	if tag.Name == "" {
+		h.renderTag(w, r, tag, "Tag name is required")
+		return
+	}
+
+	err = h.store.As(auditActor(r)).UpdateTag(tag)
+	if err == db.ErrTagExists {
+		h.renderTag(w, r, tag, fmt.Sprintf("Another tag is already named %q; merge into it instead", tag.Name))
+		return
+	} else if err == db.ErrCategoryExists {
+		h.renderTag(w, r, tag, fmt.Sprintf("A category is already named %q", tag.Name))
+		return
+	} else if err == db.ErrTagCycle {
+		h.renderTag(w, r, tag, "A tag cannot sit beneath itself or a tag beneath it")
+		return
+	} else if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/tags/%d", id), http.StatusSeeOther)
+}
+
+// renderTag renders a tag's editor, offering the images of its projects as
+// covers and every other tag as a parent or merge target
+func (h *TagHandler) renderTag(w http.ResponseWriter, r *http.Request, tag *models.Tag, message string) {
+	tags, err := h.store.ListTags()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	others := tags[:0]
+	tag.ParentName = ""
+	for _, t := range tags {
+		if t.ID != tag.ID {
+			others = append(others, t)
+		}
+		if tag.ParentID != nil && t.ID == *tag.ParentID {
+			tag.ParentName = t.Name
+		}
+	}
+
+	covers, err := h.tagCovers(tag)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/tag_form.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:       "Edit Tag",
+		Tag:         tag,
+		Tags:        others,
+		Media:       covers,
+		Error:       message,
+		CSRFToken:   csrf.Token(r),
+		IsAdmin:     true,
+		CurrentUser: session.CurrentUser(r.Context()),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// tagCovers returns the media of every image on the tag's projects, plus the
+// current cover if it is no longer among them
+func (h *TagHandler) tagCovers(tag *models.Tag) ([]models.Media, error) {
+	results, err := h.store.SearchProjects(models.ProjectSearch{Tag: tag.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	var covers []models.Media
+	seen := make(map[int64]bool)
+	for _, result := range results {
+		for _, img := range result.Images {
+			if !seen[img.Media.ID] {
+				seen[img.Media.ID] = true
+				covers = append(covers, img.Media)
+			}
+		}
+	}
+	if tag.Cover != nil && !seen[tag.Cover.ID] {
+		covers = append([]models.Media{*tag.Cover}, covers...)
+	}
+	return covers, nil
+}
+
+// AdminMergeTagHandler moves a tag's projects to the tag chosen as
+// target_id and deletes it
+func (h *TagHandler) AdminMergeTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+	targetID, err := strconv.ParseInt(r.FormValue("target_id"), 10, 64)
+	if err != nil || targetID == id {
+		http.Error(w, "Invalid target tag", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).MergeTags(id, targetID); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/tags/%d", targetID), http.StatusSeeOther)
+}
+
+// AdminDeleteTagHandler removes a tag from every project and deletes it
+func (h *TagHandler) AdminDeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tag ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).DeleteTag(id); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
@@ -6,138 +6,124 @@ import (
 	"html/template"
 	"log"
 	"net/http"
-	"path/filepath"
+	"strconv"
 
+	"voidcase/internal/config"
+	"voidcase/internal/db"
 	"voidcase/internal/models"
+	"voidcase/internal/session"
 
 	"github.com/gorilla/mux"
 )
 
 type TagHandler struct {
-	db *sql.DB
+	store   db.Store
+	cfg     *config.Config
+	cookies *session.Cookies
 }
 
-func NewTagHandler(db *sql.DB) *TagHandler {
-	return &TagHandler{db: db}
+func NewTagHandler(store db.Store, cfg *config.Config) *TagHandler {
+	return &TagHandler{store: store, cfg: cfg, cookies: session.NewCookies(cfg)}
 }
 
-// isAdmin checks if the current request is from an admin user
-func (h *TagHandler) isAdmin(r *http.Request) bool {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		return false
-	}
-	// Verify session in database
-	var exists bool
-	err = h.db.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM sessions 
-            WHERE id = ? AND expires_at > datetime('now')
-        )`, cookie.Value).Scan(&exists)
-	return err == nil && exists
-}
+// tagPageSize is the number of projects shown per page of a tag page
+const tagPageSize = 12
 
+// TagHandler renders a tag's page: its cover and description, then its
+// published projects and those of the tags beneath it, tagPageSize per
+// ?page=. Categories are linked by slug and other tags by name.
 func (h *TagHandler) TagHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	tag := vars["tag"]
+	slug := mux.Vars(r)["tag"]
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
 
-	projects, err := h.getProjectsByTag(tag)
+	name := slug
+	if category, err := h.store.GetCategoryBySlug(slug); err == nil {
+		name = category.Name
+	} else if err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tag, err := h.store.GetTagByName(name)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	config, err := h.store.GetSiteConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch one extra project to learn whether there is a next page
+	projects, err := h.store.ListProjectsByTag(tag.Name, models.GetTheme(config.ThemeName).TagOrder,
+		tagPageSize+1, (page-1)*tagPageSize)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if page > 1 && len(projects) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var prevURL, nextURL string
+	if len(projects) > tagPageSize {
+		projects = projects[:tagPageSize]
+		query.Set("page", strconv.Itoa(page+1))
+		nextURL = r.URL.Path + "?" + query.Encode()
+	}
+	if page > 1 {
+		// The first page is linked without a page number
+		query.Set("page", strconv.Itoa(page-1))
+		if page == 2 {
+			query.Del("page")
+		}
+		prevURL = r.URL.Path
+		if len(query) > 0 {
+			prevURL += "?" + query.Encode()
+		}
+	}
 
-	config, err := h.getConfig()
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Use theme-specific template path
-	themePath := filepath.Join("templates", "themes", config.ThemeName)
-	tmpl, err := template.ParseFiles(
-		filepath.Join(themePath, "base.html"),
-		filepath.Join(themePath, "tag.html"),
-	)
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "tag.html")
 	if err != nil {
 		log.Printf("Template error: %v", err)
 		http.Error(w, "Template error", http.StatusInternalServerError)
 		return
 	}
 
+	title := "Projects - " + tag.Name
+	if page > 1 {
+		title += " - Page " + strconv.Itoa(page)
+	}
 	data := PageData{
-		Title:        "Projects - " + tag,
+		Title:        title,
 		Projects:     projects,
-		Navigation:   []string{tag},
+		Navigation:   nav,
+		CurrentTag:   tag.Name,
+		Tag:          tag,
+		PrevURL:      prevURL,
+		NextURL:      nextURL,
 		Theme:        config.ThemeName,
 		TrackingCode: template.HTML(config.TrackingCode),
-		IsAdmin:      h.isAdmin(r),
+		IsAdmin:      isAdmin(h.store, h.cookies, r),
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
 		log.Printf("Template execution error: %v", err)
 		http.Error(w, "Template execution error", http.StatusInternalServerError)
 	}
 }
-
-func (h *TagHandler) getConfig() (*models.SiteConfig, error) {
-	config := &models.SiteConfig{}
-	err := h.db.QueryRow(`
-        SELECT about_text, contact_info, tracking_code, theme_name, updated_at 
-        FROM site_config WHERE id = 1
-    `).Scan(&config.AboutText, &config.ContactInfo, &config.TrackingCode,
-		&config.ThemeName, &config.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return &models.SiteConfig{ThemeName: "default"}, nil
-	}
-	return config, err
-}
-
-func (h *TagHandler) getProjectsByTag(tag string) ([]models.Project, error) {
-	// Get projects with basic info
-	rows, err := h.db.Query(`
-        SELECT DISTINCT p.id, p.title, p.description, p.video_embed, p.date, 
-               p.created_at, p.updated_at
-        FROM projects p
-        JOIN project_tags pt ON p.id = pt.project_id
-        JOIN tags t ON pt.tag_id = t.id
-        WHERE t.name = ?
-        ORDER BY p.date DESC`, tag)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var projects []models.Project
-	for rows.Next() {
-		var p models.Project
-		if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.VideoEmbed,
-			&p.Date, &p.CreatedAt, &p.UpdatedAt); err != nil {
-			return nil, err
-		}
-
-		// Get images for each project
-		imgRows, err := h.db.Query(`
-            SELECT id, hash, path, created_at 
-            FROM images 
-            WHERE project_id = ?
-            ORDER BY created_at`, p.ID)
-		if err != nil {
-			return nil, err
-		}
-		defer imgRows.Close()
-
-		var images []models.Image
-		for imgRows.Next() {
-			var img models.Image
-			if err := imgRows.Scan(&img.ID, &img.Hash, &img.Path, &img.CreatedAt); err != nil {
-				return nil, err
-			}
-			images = append(images, img)
-		}
-		p.Images = images
-
-		projects = append(projects, p)
-	}
-	return projects, nil
-}
@@ -0,0 +1,85 @@
+// internal/handlers/tags_test.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+func TestTagHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := NewTagHandler(store, testConfig(t))
+	for i := 1; i <= tagPageSize+1; i++ {
+		dbtest.CreateProject(t, store, fmt.Sprintf("Reel %02d", i), "Film", "Night")
+	}
+	dbtest.CreateProject(t, store, "Harbour", "Stills")
+	tag, err := store.GetTagByName("Film")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.Description = "Work shot on celluloid"
+	if err := store.UpdateTag(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	page := func(target string) (int, string) {
+		w := do(h.TagHandler, request("GET", target, map[string]string{"tag": "Film"}, nil))
+		return w.Code, w.Body.String()
+	}
+	code, body := page("/tag/Film")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", code)
+	}
+	if n := strings.Count(body, `class="project-card"`); n != tagPageSize {
+		t.Errorf("first page lists %d projects; want %d", n, tagPageSize)
+	}
+	for _, want := range []string{
+		"Work shot on celluloid",        // the tag's description
+		`href="/tag/Night" class="tag"`, // each project's tags
+		`href="/tag/Stills" class=""`,   // navigation beyond the current tag
+		`href="/tag/Film?page=2" rel="next"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("first page is missing %s", want)
+		}
+	}
+	if strings.Contains(body, "Harbour") {
+		t.Error("first page lists a project without the tag")
+	}
+
+	code, body = page("/tag/Film?page=2")
+	if code != http.StatusOK || strings.Count(body, `class="project-card"`) != 1 ||
+		!strings.Contains(body, `href="/tag/Film" rel="prev"`) || strings.Contains(body, `rel="next"`) {
+		t.Errorf("second page = %d; want the last project and a link back to the first page", code)
+	}
+	if code, _ := page("/tag/Film?page=3"); code != http.StatusNotFound {
+		t.Errorf("page past the end = %d; want 404", code)
+	}
+}
+
+func TestTagHandlerFindsCategoryBySlug(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := NewTagHandler(store, testConfig(t))
+	if err := store.CreateCategory(&models.Category{Name: "Music Video", Slug: "music", Visible: true}); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.CreateProject(t, store, "Night Drive", "Music Video")
+
+	w := do(h.TagHandler, request("GET", "/tag/music", map[string]string{"tag": "music"}, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Night Drive") {
+		t.Errorf("category slug = %d; want the category's projects", w.Code)
+	}
+	w = do(h.TagHandler, request("GET", "/tag/Music%20Video", map[string]string{"tag": "Music Video"}, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("tag name = %d; want 200", w.Code)
+	}
+	if w := do(h.TagHandler, request("GET", "/tag/opera", map[string]string{"tag": "opera"}, nil)); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tag = %d; want 404", w.Code)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 	"voidcase/internal/models"
+	"voidcase/internal/utils"
 )
 
 var templateFuncs = template.FuncMap{
@@ -18,16 +19,18 @@ var templateFuncs = template.FuncMap{
 		}
 		return false
 	},
-	"isCoreCategory": func(tag string) bool {
-		for _, cat := range models.CoreCategories {
-			if cat == tag {
-				return true
-			}
-		}
-		return false
+	"isCategory": models.IsCategory,
+	"videoEmbed": func(embed string) template.HTML {
+		return template.HTML(utils.SanitizeVideoEmbed(embed))
 	},
 	"now": time.Now,
 	"formatDate": func(t time.Time) string {
 		return t.Format("2006-01-02")
 	},
+	// highlight escapes a search snippet and marks the matched words
+	"highlight": func(snippet string) template.HTML {
+		escaped := template.HTMLEscapeString(snippet)
+		return template.HTML(strings.NewReplacer(
+			models.MatchStart, "<mark>", models.MatchEnd, "</mark>").Replace(escaped))
+	},
 }
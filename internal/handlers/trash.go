@@ -0,0 +1,107 @@
+// internal/handlers/trash.go
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+)
+
+func (h *ProjectHandler) purgeTrash() {
+	orphans, err := h.store.PurgeDeletedProjects(time.Now().Add(-h.cfg.TrashRetention.Duration))
+	if err != nil {
+		log.Printf("Trash purge error: %v", err)
+		return
+	}
+	h.removeMedia(orphans)
+}
+
+// AdminTrashHandler lists the projects in the trash with the date each will
+// be purged
+func (h *ProjectHandler) AdminTrashHandler(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.store.ListDeletedProjects()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/trash.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:          "Trash",
+		Projects:       projects,
+		TrashRetention: h.cfg.TrashRetention.Duration,
+		CSRFToken:      csrf.Token(r),
+		IsAdmin:        true,
+		CurrentUser:    session.CurrentUser(r.Context()),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// AdminRestoreProjectHandler moves a project out of the trash
+func (h *ProjectHandler) AdminRestoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).RestoreProject(id); err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+}
+
+// AdminPurgeProjectHandler deletes a project in the trash for good, along
+// with the files of media no other project uses
+func (h *ProjectHandler) AdminPurgeProjectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	orphans, err := h.store.As(auditActor(r)).PurgeProject(id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Delete files of media no other project uses after the DB transaction
+	h.removeMedia(orphans)
+
+	http.Redirect(w, r, "/admin/trash", http.StatusSeeOther)
+}
@@ -0,0 +1,319 @@
+// internal/handlers/twofactor.go
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+	"voidcase/internal/totp"
+	"voidcase/internal/utils"
+
+	"github.com/gorilla/csrf"
+	"golang.org/x/crypto/bcrypt"
+	"rsc.io/qr"
+)
+
+// challengeTTL is how long the second login step can take after the
+// password was accepted
+const challengeTTL = 5 * time.Minute
+
+// trustedDeviceTTL is how long a remembered device skips the second step
+const trustedDeviceTTL = 30 * 24 * time.Hour
+
+// recoveryCodeCount is how many recovery codes are issued at a time
+const recoveryCodeCount = 10
+
+// TOTPSetup is shown while a user enrolls an authenticator app
+type TOTPSetup struct {
+	Secret string
+	URI    string
+	QRCode template.URL
+}
+
+// trustedDevice reports whether the request carries a remembered device
+// cookie for userID
+func (h *AuthHandler) trustedDevice(r *http.Request, userID int64) bool {
+	token, err := h.cookies.Device(r)
+	if err != nil {
+		return false
+	}
+	trusted, err := h.store.IsTrustedDevice(userID, session.HashToken(token))
+	if err != nil {
+		log.Printf("Trusted device lookup error: %v", err)
+		return false
+	}
+	return trusted
+}
+
+// VerifyHandler is the second login step for accounts with two-factor
+// authentication. It accepts an authenticator code or an unused recovery
+// code, and can remember the browser so the step is skipped next time.
+func (h *AuthHandler) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.cookies.Challenge(r, h.now())
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	user, err := h.store.GetUser(userID)
+	if err != nil || user.Disabled() || !user.TOTPEnabled() {
+		h.cookies.ClearChallenge(w)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != "POST" {
+		h.renderVerify(w, r, http.StatusOK, "")
+		return
+	}
+
+	// Wrong codes count as failed sign-ins, so guessing them is throttled
+	// like guessing passwords
+	ip := utils.ClientIP(r)
+	unlock := h.lockLogin(user.Username, ip)
+	defer unlock()
+
+	if wait, err := h.loginWait(user.Username, ip); err != nil {
+		log.Printf("Login attempt lookup error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	} else if wait > 0 {
+		h.renderVerify(w, r, http.StatusTooManyRequests, refuseLogin(w, wait))
+		return
+	}
+
+	ok, err := h.checkSecondFactor(user, r.FormValue("code"))
+	if err != nil {
+		log.Printf("Second factor error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.recordLogin(r, user.Username, false)
+		h.renderVerify(w, r, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	h.cookies.ClearChallenge(w)
+	if r.FormValue("remember") != "" {
+		if err := h.rememberDevice(w, user.ID); err != nil {
+			log.Printf("Trusted device error: %v", err)
+		}
+	}
+	h.startSession(w, r, user)
+}
+
+// checkSecondFactor accepts a current authenticator code that has not been
+// used before, or one of the user's unused recovery codes
+func (h *AuthHandler) checkSecondFactor(user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if step, ok := totp.Validate(user.TOTPSecret, code, h.now()); ok {
+		return h.store.UseTOTPStep(user.ID, step)
+	}
+	normalized := totp.NormalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+	return h.store.UseRecoveryCode(user.ID, session.HashToken(normalized))
+}
+
+func (h *AuthHandler) rememberDevice(w http.ResponseWriter, userID int64) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	now := h.now()
+	device := &models.TrustedDevice{
+		UserID:    userID,
+		TokenHash: hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(trustedDeviceTTL),
+	}
+	if err := h.store.CreateTrustedDevice(device); err != nil {
+		return err
+	}
+	return h.cookies.SetDevice(w, token, device.ExpiresAt)
+}
+
+func (h *AuthHandler) renderVerify(w http.ResponseWriter, r *http.Request, status int, message string) {
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/login_verify.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:     "Verify Login",
+		Error:     message,
+		CSRFToken: csrf.Token(r),
+	}
+
+	w.WriteHeader(status)
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// TOTPSetupHandler starts enrollment by showing a new secret as a QR code.
+// The secret is only saved once TOTPEnableHandler sees a valid code for it.
+func (h *UserHandler) TOTPSetupHandler(w http.ResponseWriter, r *http.Request) {
+	user := session.CurrentUser(r.Context())
+	if user.TOTPEnabled() {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setup, err := newTOTPSetup(r.Host, user.Username, secret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.renderAccount(w, r, PageData{TOTPSetup: setup})
+}
+
+// TOTPEnableHandler saves the secret from the setup form once the user
+// proves their app produces codes for it, then shows their recovery codes
+func (h *UserHandler) TOTPEnableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := session.CurrentUser(r.Context())
+	if user.TOTPEnabled() {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+
+	secret := r.FormValue("secret")
+	step, ok := totp.Validate(secret, strings.TrimSpace(r.FormValue("code")), h.now())
+	if !ok {
+		setup, err := newTOTPSetup(r.Host, user.Username, secret)
+		if err != nil {
+			http.Error(w, "Invalid secret", http.StatusBadRequest)
+			return
+		}
+		h.renderAccount(w, r, PageData{TOTPSetup: setup, Error: "Invalid code, try again"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.As(auditActor(r)).EnableTOTP(user.ID, secret, hashes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The code used to enroll cannot also be used to sign in
+	if _, err := h.store.UseTOTPStep(user.ID, step); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user.TOTPSecret = secret
+	h.renderAccount(w, r, PageData{RecoveryCodes: codes})
+}
+
+// TOTPDisableHandler turns two-factor authentication off after checking the
+// current password
+func (h *UserHandler) TOTPDisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := session.CurrentUser(r.Context())
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("current_password"))) != nil {
+		h.renderAccount(w, r, PageData{Error: "Current password is incorrect"})
+		return
+	}
+	if err := h.store.As(auditActor(r)).DisableTOTP(user.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+}
+
+// TOTPRecoveryHandler replaces the user's recovery codes after checking the
+// current password, and shows the new ones once
+func (h *UserHandler) TOTPRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := session.CurrentUser(r.Context())
+	if !user.TOTPEnabled() {
+		http.Redirect(w, r, "/admin/account", http.StatusSeeOther)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("current_password"))) != nil {
+		h.renderAccount(w, r, PageData{Error: "Current password is incorrect"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.As(auditActor(r)).ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.renderAccount(w, r, PageData{RecoveryCodes: codes})
+}
+
+// newTOTPSetup builds the enrollment details for secret, with the site's
+// host name as the issuer shown in authenticator apps
+func newTOTPSetup(host, username, secret string) (*TOTPSetup, error) {
+	if _, err := totp.Code(secret, 0); err != nil {
+		return nil, err
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	uri := totp.URI(host, username, secret)
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return nil, err
+	}
+	code.Scale = 6
+
+	var buf bytes.Buffer
+	buf.WriteString("data:image/png;base64,")
+	buf.WriteString(base64.StdEncoding.EncodeToString(code.PNG()))
+	return &TOTPSetup{
+		Secret: secret,
+		URI:    uri,
+		QRCode: template.URL(buf.String()),
+	}, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes = make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = session.HashToken(totp.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
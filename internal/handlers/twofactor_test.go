@@ -0,0 +1,141 @@
+// internal/handlers/twofactor_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/session"
+	"voidcase/internal/totp"
+)
+
+// testSecret is the RFC 6238 test key
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// codeAt returns the authenticator code offset steps from now
+func codeAt(t *testing.T, now time.Time, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(testSecret, totp.Step(now)+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestVerifyHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Date(2024, 3, 1, 12, 0, 10, 0, time.UTC)
+	h, user := newAuthHandler(t, store, now)
+
+	recovery := "abcde-fghjk"
+	hashes := []string{session.HashToken(totp.NormalizeRecoveryCode(recovery))}
+	if err := store.EnableTOTP(user.ID, testSecret, hashes); err != nil {
+		t.Fatal(err)
+	}
+
+	// The password step leaves a challenge cookie for the second step
+	res := login(h, "ada", "correct horse")
+	if res.Header.Get("Location") != "/login/verify" {
+		t.Fatalf("login with two-factor sent to %q; want /login/verify", res.Header.Get("Location"))
+	}
+	challenge := res.Cookies()
+
+	verify := func(code string) int {
+		t.Helper()
+		r := request("POST", "/login/verify", nil, url.Values{"code": {code}})
+		for _, c := range challenge {
+			r.AddCookie(c)
+		}
+		return do(h.VerifyHandler, r).Code
+	}
+
+	// Codes outside the window are refused, and each accepted step can only
+	// be used once and never after a later one
+	for _, c := range []struct {
+		name string
+		code string
+		want int
+	}{
+		{"two steps behind", codeAt(t, now, -2), http.StatusUnauthorized},
+		{"two steps ahead", codeAt(t, now, 2), http.StatusUnauthorized},
+		{"one step behind", codeAt(t, now, -1), http.StatusSeeOther},
+		{"one step behind again", codeAt(t, now, -1), http.StatusUnauthorized},
+		{"current step", codeAt(t, now, 0), http.StatusSeeOther},
+		{"current step again", codeAt(t, now, 0), http.StatusUnauthorized},
+		{"earlier step after a later one", codeAt(t, now, -1), http.StatusUnauthorized},
+		{"one step ahead", codeAt(t, now, 1), http.StatusSeeOther},
+		{"recovery code", "ABCDE FGHJK", http.StatusSeeOther},
+		{"recovery code again", recovery, http.StatusUnauthorized},
+		{"wrong code", "000000", http.StatusUnauthorized},
+	} {
+		if got := verify(c.code); got != c.want {
+			t.Errorf("%s: status = %d; want %d", c.name, got, c.want)
+		}
+	}
+	if n, err := store.RecoveryCodesLeft(user.ID); err != nil || n != 0 {
+		t.Errorf("RecoveryCodesLeft = %d, %v; want 0", n, err)
+	}
+
+	// The challenge expires with the clock
+	h.now = func() time.Time { return now.Add(challengeTTL + time.Second) }
+	r := request("POST", "/login/verify", nil, url.Values{"code": {codeAt(t, h.now(), 0)}})
+	for _, c := range challenge {
+		r.AddCookie(c)
+	}
+	if res := do(h.VerifyHandler, r).Result(); res.Header.Get("Location") != "/login" {
+		t.Errorf("expired challenge sent to %q; want /login", res.Header.Get("Location"))
+	}
+}
+
+func TestTOTPEnableHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Date(2024, 3, 1, 12, 0, 10, 0, time.UTC)
+	_, user := newAuthHandler(t, store, now)
+	h := NewUserHandler(store, testConfig(t))
+	h.now = func() time.Time { return now }
+
+	enable := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		current, err := store.GetUser(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := request("POST", "/admin/account/totp/enable", nil, url.Values{"secret": {testSecret}, "code": {code}})
+		return do(h.TOTPEnableHandler, asUser(r, current))
+	}
+
+	w := enable(codeAt(t, now, 2))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid code, try again") {
+		t.Fatalf("enrolling with a code two steps ahead = %d; want 400 with the form again", w.Code)
+	}
+	if current, _ := store.GetUser(user.ID); current.TOTPEnabled() {
+		t.Fatal("two-factor enabled with an invalid code")
+	}
+
+	w = enable(codeAt(t, now, 1))
+	if w.Code != http.StatusOK {
+		t.Fatalf("enroll status = %d; want 200", w.Code)
+	}
+	current, err := store.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.TOTPSecret != testSecret {
+		t.Fatal("two-factor was not enabled")
+	}
+	if n, err := store.RecoveryCodesLeft(user.ID); err != nil || n != recoveryCodeCount {
+		t.Errorf("RecoveryCodesLeft = %d, %v; want %d", n, err, recoveryCodeCount)
+	}
+
+	// The enrollment code, and those before it, cannot be used to sign in
+	for offset := int64(-1); offset <= 1; offset++ {
+		if ok, err := store.UseTOTPStep(user.ID, totp.Step(now)+offset); err != nil || ok {
+			t.Errorf("step %+d after enrolling with step +1 accepted = %v, %v", offset, ok, err)
+		}
+	}
+}
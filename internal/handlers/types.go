@@ -3,31 +3,59 @@ package handlers
 
 import (
 	"html/template"
+	"time"
+
 	"voidcase/internal/models"
 )
 
 // PageData represents the data passed to templates
 type PageData struct {
-	Title          string
-	Projects       []models.Project
-	Project        *models.Project
-	Navigation     []string
-	Categories     []CategoryCount
-	Theme          string
-	About          string
-	Contact        string
-	TrackingCode   template.HTML
-	Analytics      *models.AnalyticsSummary
-	RecentProjects []models.Project
-	CSRFToken      string
-	Error          string
-	IsAdmin        bool
-	SiteConfig     *models.SiteConfig
-	CoreCategories []string
-}
-
-// CategoryCount tracks number of projects per category
-type CategoryCount struct {
-	Name  string
-	Count int
+	Title             string
+	Projects          []models.Project
+	Project           *models.Project
+	Revisions         []RevisionView
+	ProjectStatuses   []models.ProjectStatus
+	Search            *models.ProjectSearch
+	SearchResults     []models.SearchResult
+	FilterTags        []string
+	TagOptions        []TagOption
+	FilterYears       []int
+	PreviewURL        string
+	PrevURL           string
+	NextURL           string
+	Media             []models.Media
+	Navigation        []models.NavTag
+	CurrentTag        string
+	Tag               *models.Tag
+	Tags              []models.Tag
+	Categories        []models.CategoryCount
+	Theme             string
+	About             string
+	Contact           string
+	TrackingCode      template.HTML
+	Analytics         *models.AnalyticsSummary
+	ProjectViews      []ProjectViews
+	Days              int
+	AnalyticsRange    []int
+	RecentProjects    []models.Project
+	LoginAttempts     []models.LoginAttempt
+	CSRFToken         string
+	Error             string
+	IsAdmin           bool
+	CurrentUser       *models.User
+	Users             []models.User
+	Invites           []models.Invite
+	Roles             []models.Role
+	InviteURL         string
+	ResetURL          string
+	ResetUser         string
+	TOTPSetup         *TOTPSetup
+	RecoveryCodes     []string
+	RecoveryCodesLeft int
+	Sessions          []SessionView
+	Audit             *AuditPage
+	TrashRetention    time.Duration
+	SiteConfig        *models.SiteConfig
+	// ProjectCategories are the categories managed in the admin
+	ProjectCategories []models.Category
 }
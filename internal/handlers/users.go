@@ -0,0 +1,557 @@
+// internal/handlers/users.go
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// inviteTTL is how long an invite link can be used
+const inviteTTL = 7 * 24 * time.Hour
+
+// resetTTL is how long a password reset link can be used
+const resetTTL = 24 * time.Hour
+
+// minPasswordLength is the shortest password accepted for a new account
+const minPasswordLength = 8
+
+// ErrPasswordTooShort is returned by HashPassword for passwords shorter
+// than minPasswordLength
+var ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+
+// UserHandler lets owners manage admin accounts and invites, and lets
+// invited people create their account
+type UserHandler struct {
+	store   db.Store
+	cfg     *config.Config
+	cookies *session.Cookies
+	// now is the clock used for link expiry and TOTP codes
+	now func() time.Time
+}
+
+func NewUserHandler(store db.Store, cfg *config.Config) *UserHandler {
+	return &UserHandler{store: store, cfg: cfg, cookies: session.NewCookies(cfg), now: time.Now}
+}
+
+// HashPassword validates and hashes a new password
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// newToken returns a random URL-safe token and the hash to store for it
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, session.HashToken(token), nil
+}
+
+// absoluteURL turns path into a link that can be sent to someone
+func (h *UserHandler) absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || h.cfg.Secure() {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// AdminUsersHandler lists accounts and pending invites on GET and creates
+// an account from username, password and role on POST
+func (h *UserHandler) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		h.renderUsers(w, r, PageData{})
+		return
+	}
+
+	role := models.Role(r.FormValue("role"))
+	if !role.Valid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	username := strings.ToLower(strings.TrimSpace(r.FormValue("username")))
+	if username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+	hash, err := HashPassword(r.FormValue("password"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    h.now(),
+	}
+	if err := h.store.As(auditActor(r)).CreateUser(user); err == db.ErrUsernameTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// renderUsers shows the user list, along with any one-time link in data
+func (h *UserHandler) renderUsers(w http.ResponseWriter, r *http.Request, data PageData) {
+	users, err := h.store.ListUsers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invites, err := h.store.ListInvites()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/users.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Title = "Users"
+	data.Users = users
+	data.Invites = invites
+	data.Roles = models.Roles
+	data.CSRFToken = csrf.Token(r)
+	data.IsAdmin = true
+	data.CurrentUser = session.CurrentUser(r.Context())
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
+
+// AdminCreateInviteHandler creates an invite for role and shows its link.
+// The link is only displayed on this response.
+func (h *UserHandler) AdminCreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	role := models.Role(r.FormValue("role"))
+	if !role.Valid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := h.now()
+	invite := &models.Invite{
+		TokenHash: tokenHash,
+		Role:      role,
+		CreatedBy: session.CurrentUser(r.Context()).ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(inviteTTL),
+	}
+	if err := h.store.As(auditActor(r)).CreateInvite(invite); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.renderUsers(w, r, PageData{InviteURL: h.absoluteURL(r, "/invite/"+token)})
+}
+
+// AdminDeleteInviteHandler revokes an unused invite
+func (h *UserHandler) AdminDeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.As(auditActor(r)).DeleteInvite(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// userFromVars parses the {id} route variable and refuses changes to the
+// signed-in account, so owners cannot lock themselves out
+func userFromVars(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, false
+	}
+	if id == session.CurrentUser(r.Context()).ID {
+		http.Error(w, "You cannot change your own account here", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// userUpdated redirects back to the user list or reports why a change
+// failed
+func userUpdated(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case err == nil:
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+	case err == sql.ErrNoRows:
+		http.NotFound(w, r)
+	case err == db.ErrLastOwner:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// AdminSetUserRoleHandler changes a user's role
+func (h *UserHandler) AdminSetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := userFromVars(w, r)
+	if !ok {
+		return
+	}
+	role := models.Role(r.FormValue("role"))
+	if !role.Valid() {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	userUpdated(w, r, h.store.As(auditActor(r)).SetUserRole(id, role))
+}
+
+// AdminSetUserDisabledHandler disables a user when disabled=true, signing
+// them out, and re-enables them otherwise
+func (h *UserHandler) AdminSetUserDisabledHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := userFromVars(w, r)
+	if !ok {
+		return
+	}
+
+	userUpdated(w, r, h.store.As(auditActor(r)).SetUserDisabled(id, r.FormValue("disabled") == "true"))
+}
+
+// AdminDeleteUserHandler deletes a user account
+func (h *UserHandler) AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := userFromVars(w, r)
+	if !ok {
+		return
+	}
+
+	userUpdated(w, r, h.store.As(auditActor(r)).DeleteUser(id))
+}
+
+// AdminCreateResetHandler creates a one-time password reset link for a
+// user and shows it. Earlier unused links for the user stop working.
+func (h *UserHandler) AdminCreateResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := userFromVars(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.store.GetUser(id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := h.now()
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(resetTTL),
+	}
+	if err := h.store.As(auditActor(r)).CreatePasswordReset(reset); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.renderUsers(w, r, PageData{
+		ResetURL:  h.absoluteURL(r, "/reset/"+token),
+		ResetUser: user.Username,
+	})
+}
+
+// AccountHandler lets the signed-in user change their password. The current
+// password is required, and every session, including this one, ends once
+// it changes.
+func (h *UserHandler) AccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := session.CurrentUser(r.Context())
+	if r.Method != "POST" {
+		h.renderAccount(w, r, PageData{})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("current_password"))) != nil {
+		h.renderAccount(w, r, PageData{Error: "Current password is incorrect"})
+		return
+	}
+	password := r.FormValue("new_password")
+	if password != r.FormValue("confirm_password") {
+		h.renderAccount(w, r, PageData{Error: "Passwords do not match"})
+		return
+	}
+	hash, err := HashPassword(password)
+	if err == ErrPasswordTooShort {
+		h.renderAccount(w, r, PageData{Error: err.Error()})
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.As(auditActor(r)).SetUserPassword(user.ID, hash); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderAccount(w http.ResponseWriter, r *http.Request, data PageData) {
+	user := session.CurrentUser(r.Context())
+	if user.TOTPEnabled() {
+		left, err := h.store.RecoveryCodesLeft(user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.RecoveryCodesLeft = left
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/account.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Title = "Account"
+	data.CSRFToken = csrf.Token(r)
+	data.IsAdmin = true
+	data.CurrentUser = user
+
+	if data.Error != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// ResetHandler shows the new password form for /reset/{token} and sets the
+// password on POST
+func (h *UserHandler) ResetHandler(w http.ResponseWriter, r *http.Request) {
+	tokenHash := session.HashToken(mux.Vars(r)["token"])
+	reset, err := h.store.GetPasswordReset(tokenHash)
+	if err == sql.ErrNoRows {
+		http.Error(w, "This reset link is invalid or has expired", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != "POST" {
+		h.renderReset(w, r, "")
+		return
+	}
+
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm_password") {
+		h.renderReset(w, r, "Passwords do not match")
+		return
+	}
+	hash, err := HashPassword(password)
+	if err == ErrPasswordTooShort {
+		h.renderReset(w, r, err.Error())
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The link's owner is not signed in, so record them as the actor
+	actor := auditActor(r)
+	actor.UserID = &reset.UserID
+	if user, err := h.store.GetUser(reset.UserID); err == nil {
+		actor.Username = user.Username
+	}
+	if err := h.store.As(actor).ResetPassword(tokenHash, hash); err == sql.ErrNoRows {
+		http.Error(w, "This reset link is invalid or has expired", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderReset(w http.ResponseWriter, r *http.Request, message string) {
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/reset.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:     "Reset Password",
+		Error:     message,
+		CSRFToken: csrf.Token(r),
+	}
+
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
+
+// InviteHandler shows the sign-up form for /invite/{token} and creates the
+// account on POST
+func (h *UserHandler) InviteHandler(w http.ResponseWriter, r *http.Request) {
+	tokenHash := session.HashToken(mux.Vars(r)["token"])
+	invite, err := h.store.GetInvite(tokenHash)
+	if err == sql.ErrNoRows {
+		http.Error(w, "This invite is invalid or has expired", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method != "POST" {
+		h.renderInvite(w, r, invite, "")
+		return
+	}
+
+	username := strings.ToLower(strings.TrimSpace(r.FormValue("username")))
+	password := r.FormValue("password")
+	if username == "" {
+		h.renderInvite(w, r, invite, "Username is required")
+		return
+	}
+	if password != r.FormValue("confirm_password") {
+		h.renderInvite(w, r, invite, "Passwords do not match")
+		return
+	}
+	hash, err := HashPassword(password)
+	if err == ErrPasswordTooShort {
+		h.renderInvite(w, r, invite, err.Error())
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		CreatedAt:    h.now(),
+	}
+	actor := auditActor(r)
+	actor.Username = username
+	if err := h.store.As(actor).AcceptInvite(tokenHash, user); err == db.ErrUsernameTaken {
+		h.renderInvite(w, r, invite, err.Error())
+		return
+	} else if err == sql.ErrNoRows {
+		http.Error(w, "This invite is invalid or has expired", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (h *UserHandler) renderInvite(w http.ResponseWriter, r *http.Request, invite *models.Invite, message string) {
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, "", "admin/layout.html", "admin/invite.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	data := PageData{
+		Title:     "Create Account",
+		Roles:     []models.Role{invite.Role},
+		Error:     message,
+		CSRFToken: csrf.Token(r),
+	}
+
+	if message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// internal/handlers/users_test.go
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResetHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	ada := store.AddUser(models.User{Username: "ada", PasswordHash: "x", Role: models.RoleEditor})
+	grace := store.AddUser(models.User{Username: "grace", PasswordHash: "x", Role: models.RoleEditor})
+	h := NewUserHandler(store, testConfig(t))
+	now := time.Now()
+	// A new link replaces the user's unused ones, so each link is for
+	// another user
+	for token, r := range map[string]models.PasswordReset{
+		"valid":   {UserID: ada.ID, ExpiresAt: now.Add(time.Hour)},
+		"expired": {UserID: grace.ID, ExpiresAt: now.Add(-time.Minute)},
+	} {
+		r.TokenHash, r.CreatedAt = session.HashToken(token), now
+		if err := store.CreatePasswordReset(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reset := func(method, token string, form url.Values) *http.Response {
+		vars := map[string]string{"token": token}
+		return do(h.ResetHandler, request(method, "/reset/"+token, vars, form)).Result()
+	}
+	password := url.Values{"password": {"correct horse battery"}, "confirm_password": {"correct horse battery"}}
+
+	if res := reset("GET", "valid", nil); res.StatusCode != http.StatusOK {
+		t.Errorf("GET valid link = %d; want 200", res.StatusCode)
+	}
+	if res := reset("GET", "expired", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET expired link = %d; want 404", res.StatusCode)
+	}
+	if res := reset("POST", "expired", password); res.StatusCode != http.StatusNotFound {
+		t.Errorf("POST to expired link = %d; want 404", res.StatusCode)
+	}
+	mismatch := url.Values{"password": {"correct horse battery"}, "confirm_password": {"correct horse"}}
+	if res := reset("POST", "valid", mismatch); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST with mismatched passwords = %d; want 400", res.StatusCode)
+	}
+
+	res := reset("POST", "valid", password)
+	if res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != "/login" {
+		t.Fatalf("POST valid link = %d to %q; want 303 to /login", res.StatusCode, res.Header.Get("Location"))
+	}
+	u, err := store.GetUser(ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("correct horse battery")) != nil {
+		t.Error("reset did not set the new password")
+	}
+
+	if res := reset("POST", "valid", password); res.StatusCode != http.StatusNotFound {
+		t.Errorf("reusing a link = %d; want 404", res.StatusCode)
+	}
+}
@@ -0,0 +1,147 @@
+// internal/handlers/work.go
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"voidcase/internal/models"
+)
+
+// TagOption is a tag offered as a filter on the work page, at its depth in
+// the tag tree
+type TagOption struct {
+	models.NavTag
+	Depth    int
+	Selected bool
+}
+
+// tagOptions flattens the navigation tree into filter options, marking the
+// tags being filtered by
+func tagOptions(nav []models.NavTag, depth int, selected []string) []TagOption {
+	var options []TagOption
+	for _, tag := range nav {
+		options = append(options, TagOption{
+			NavTag:   tag,
+			Depth:    depth,
+			Selected: containsFold(selected, tag.Name),
+		})
+		options = append(options, tagOptions(tag.Children, depth+1, selected)...)
+	}
+	return options
+}
+
+// tagName resolves a tag given in a URL, by category slug or by name, to
+// the tag's name. Unknown tags are returned as given and match nothing.
+func (h *ProjectHandler) tagName(term string) (string, error) {
+	if category, err := h.store.GetCategoryBySlug(term); err == nil {
+		return category.Name, nil
+	} else if err != sql.ErrNoRows {
+		return "", err
+	}
+	if tag, err := h.store.GetTagByName(term); err == nil {
+		return tag.Name, nil
+	} else if err != sql.ErrNoRows {
+		return "", err
+	}
+	return term, nil
+}
+
+// WorkHandler renders the public project listing at /work. It is filtered
+// by ?tags=, given comma-separated or repeated, matching projects with all
+// of the tags or, with ?match=any, any of them, and by ?year=.
+func (h *ProjectHandler) WorkHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := h.store.GetSiteConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	search := &models.ProjectSearch{
+		MatchAll: q.Get("match") != "any",
+		Listed:   true,
+		Order:    models.GetTheme(config.ThemeName).TagOrder,
+	}
+	for _, raw := range q["tags"] {
+		for _, term := range strings.Split(raw, ",") {
+			if term = strings.TrimSpace(term); term == "" {
+				continue
+			}
+			name, err := h.tagName(term)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !containsFold(search.Tags, name) {
+				search.Tags = append(search.Tags, name)
+			}
+		}
+	}
+	if raw := q.Get("year"); raw != "" {
+		year, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid year", http.StatusBadRequest)
+			return
+		}
+		search.Year = year
+	}
+
+	results, err := h.store.SearchProjects(*search)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	published, err := h.store.ListPublishedProjects(models.OrderManual)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, years := projectFilters(published)
+
+	nav, err := NewNavigationHandler(h.store).GetNavigation()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplates(h.cfg.TemplateDir, config.ThemeName, "base.html", "work.html")
+	if err != nil {
+		log.Printf("Template error: %v", err)
+		http.Error(w, "Template error", http.StatusInternalServerError)
+		return
+	}
+
+	join := " + "
+	if !search.MatchAll {
+		join = " or "
+	}
+	title := "Work"
+	if len(search.Tags) > 0 {
+		title += " - " + strings.Join(search.Tags, join)
+	}
+	if search.Year != 0 {
+		title += " - " + strconv.Itoa(search.Year)
+	}
+	data := PageData{
+		Title:         title,
+		Search:        search,
+		SearchResults: results,
+		TagOptions:    tagOptions(nav, 0, search.Tags),
+		FilterYears:   years,
+		Navigation:    nav,
+		Theme:         config.ThemeName,
+		TrackingCode:  template.HTML(config.TrackingCode),
+		IsAdmin:       isAdmin(h.store, h.cookies, r),
+	}
+
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("Template execution error: %v", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,59 @@
+// internal/handlers/work_test.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+)
+
+func TestWorkHandlerFilters(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newProjectHandler(t, store)
+
+	// project saves a published project dated in year
+	project := func(title string, year int, tags ...string) {
+		p := dbtest.CreateProject(t, store, title, tags...)
+		p.Date = time.Date(year, 3, 1, 0, 0, 0, 0, time.UTC)
+		if err := store.UpdateProject(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	project("Night Drive", 2023, "Film", "Night")
+	project("Harbour", 2024, "Film")
+	project("Neon Signs", 2024, "Stills", "Night")
+
+	for _, c := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Night Drive", "Harbour", "Neon Signs"}},
+		{"?tags=Film,Night", []string{"Night Drive"}},
+		{"?tags=Film&tags=Night&match=any", []string{"Night Drive", "Harbour", "Neon Signs"}},
+		{"?tags=film", []string{"Night Drive", "Harbour"}},
+		{"?year=2024", []string{"Harbour", "Neon Signs"}},
+		{"?tags=Night&year=2024", []string{"Neon Signs"}},
+		{"?tags=Unknown", nil},
+	} {
+		w := do(h.WorkHandler, request("GET", "/work"+c.query, nil, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d; want 200", c.query, w.Code)
+			continue
+		}
+		body := w.Body.String()
+		for _, title := range []string{"Night Drive", "Harbour", "Neon Signs"} {
+			listed := strings.Contains(body, title)
+			if want := containsFold(c.want, title); listed != want {
+				t.Errorf("/work%s lists %q = %v; want %v", c.query, title, listed, want)
+			}
+		}
+	}
+
+	if w := do(h.WorkHandler, request("GET", "/work?year=soon", nil, nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid year status = %d; want 400", w.Code)
+	}
+}
@@ -0,0 +1,235 @@
+// internal/imaging/imaging.go
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"voidcase/internal/models"
+	"voidcase/internal/utils"
+
+	"github.com/nfnt/resize"
+)
+
+// Rendition describes one named output generated for every upload
+type Rendition struct {
+	Name string
+	// MaxWidth bounds the output width; 0 keeps the source dimensions.
+	// Images are never upscaled.
+	MaxWidth int
+	// Format is the encoder to use ("jpeg", "png", or any format added with
+	// RegisterEncoder). Empty keeps the source format.
+	Format  string
+	Quality int
+}
+
+// DefaultRenditions are generated when no other set is configured. The
+// original is stored byte-for-byte in its uploaded format.
+var DefaultRenditions = []Rendition{
+	{Name: "thumb", MaxWidth: 300, Quality: 85},
+	{Name: "medium", MaxWidth: 1024, Quality: 85},
+	{Name: "large", MaxWidth: 2048, Quality: 85},
+	{Name: "original"},
+}
+
+// mediaDir is the directory under the uploads root holding the
+// content-addressed store
+const mediaDir = "media"
+
+// Encoder writes img to w. quality is a 1-100 hint that lossless encoders
+// may ignore.
+type Encoder func(w io.Writer, img image.Image, quality int) error
+
+type encoder struct {
+	ext    string
+	encode Encoder
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]encoder{
+		"jpeg": {".jpg", func(w io.Writer, img image.Image, quality int) error {
+			if quality <= 0 {
+				quality = 85
+			}
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		}},
+		"png": {".png", func(w io.Writer, img image.Image, _ int) error {
+			return png.Encode(w, img)
+		}},
+		"gif": {".gif", func(w io.Writer, img image.Image, _ int) error {
+			return gif.Encode(w, img, nil)
+		}},
+	}
+)
+
+// RegisterEncoder makes an output format such as "webp" or "avif" available
+// to renditions. Encoders for those formats need cgo libraries, so they are
+// plugged in by the build that links them rather than bundled here.
+func RegisterEncoder(format, ext string, enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[format] = encoder{ext: ext, encode: enc}
+}
+
+func lookupEncoder(format string) (encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[format]
+	return enc, ok
+}
+
+// Service turns uploaded image bytes into the configured renditions, stored
+// side by side in the content-addressed media store
+type Service struct {
+	root       string
+	storage    *utils.Storage
+	renditions []Rendition
+}
+
+// New creates a service writing under root (the directory served at
+// /uploads/). It fails if a rendition asks for a format with no encoder.
+func New(root string, renditions []Rendition) (*Service, error) {
+	seen := make(map[string]bool)
+	for _, r := range renditions {
+		if r.Name == "" {
+			return nil, fmt.Errorf("imaging: rendition needs a name")
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("imaging: duplicate rendition %q", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Format != "" {
+			if _, ok := lookupEncoder(r.Format); !ok {
+				return nil, fmt.Errorf("imaging: rendition %q: no encoder for %q", r.Name, r.Format)
+			}
+		}
+	}
+	return &Service{
+		root:       root,
+		storage:    utils.NewStorage(filepath.Join(root, mediaDir)),
+		renditions: renditions,
+	}, nil
+}
+
+// Hash returns the content hash Process will store data under, so callers
+// can look for an existing copy before processing
+func Hash(data []byte) string {
+	return utils.ContentHash(data)
+}
+
+// Process stores data as every configured rendition and returns the media
+// record, keyed by the SHA-256 of the uploaded bytes, ready to be saved
+func (s *Service) Process(data []byte) (*models.Media, error) {
+	hash := Hash(data)
+
+	decoded, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := decoded.Bounds()
+	media := &models.Media{
+		Hash:      hash,
+		Format:    format,
+		Width:     bounds.Dx(),
+		Height:    bounds.Dy(),
+		Bytes:     int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	for _, r := range s.renditions {
+		rendition, err := s.write(r, hash, data, decoded, format)
+		if err != nil {
+			s.Remove(media)
+			return nil, fmt.Errorf("failed to create %s rendition: %w", r.Name, err)
+		}
+		media.Renditions = append(media.Renditions, *rendition)
+		if r.Name == "original" || media.Path == "" {
+			media.Path = rendition.Path
+		}
+	}
+	return media, nil
+}
+
+func (s *Service) write(r Rendition, hash string, data []byte, src image.Image, srcFormat string) (*models.ImageRendition, error) {
+	format := r.Format
+	if format == "" {
+		format = srcFormat
+	}
+	enc, ok := lookupEncoder(format)
+	if !ok {
+		return nil, fmt.Errorf("no encoder for %q", format)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	passthrough := format == srcFormat && (r.MaxWidth == 0 || width <= r.MaxWidth)
+
+	var out []byte
+	if passthrough {
+		out = data
+	} else {
+		resized := src
+		if r.MaxWidth > 0 && width > r.MaxWidth {
+			resized = resize.Resize(uint(r.MaxWidth), 0, src, resize.Lanczos3)
+			width, height = resized.Bounds().Dx(), resized.Bounds().Dy()
+		}
+		var buf bytes.Buffer
+		if err := enc.encode(&buf, resized, r.Quality); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	}
+
+	rel, err := s.storage.Put(hash, r.Name+enc.ext, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ImageRendition{
+		Name:   r.Name,
+		Path:   path.Join(mediaDir, rel),
+		Format: format,
+		Width:  width,
+		Height: height,
+		Bytes:  int64(len(out)),
+	}, nil
+}
+
+// Remove deletes every file belonging to media. Media saved before the
+// content-addressed store lives in the legacy per-rendition directories, and
+// media saved before renditions were recorded only has an original and a
+// thumbnail.
+func (s *Service) Remove(media *models.Media) {
+	paths := make([]string, 0, len(media.Renditions)+2)
+	for _, r := range media.Renditions {
+		paths = append(paths, filepath.Join(s.root, filepath.FromSlash(r.Path)))
+	}
+	if len(media.Renditions) == 0 && media.Hash != "" {
+		paths = append(paths,
+			filepath.Join(s.root, filepath.FromSlash(media.Path)),
+			filepath.Join(s.root, "thumbnails", media.Hash+".jpg"))
+	}
+
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete image file %s: %v", p, err)
+		}
+	}
+	if media.Hash != "" {
+		if err := s.storage.Remove(media.Hash); err != nil {
+			log.Printf("Failed to delete media %s: %v", media.Hash, err)
+		}
+	}
+}
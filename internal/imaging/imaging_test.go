@@ -0,0 +1,174 @@
+// internal/imaging/imaging_test.go
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"voidcase/internal/models"
+)
+
+// testPNG encodes a width x height gradient so resizing has something to do
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newService(t *testing.T, renditions []Rendition) (*Service, string) {
+	t.Helper()
+	root := t.TempDir()
+	s, err := New(root, renditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, root
+}
+
+func renditionNamed(media *models.Media, name string) *models.ImageRendition {
+	for i := range media.Renditions {
+		if media.Renditions[i].Name == name {
+			return &media.Renditions[i]
+		}
+	}
+	return nil
+}
+
+func TestProcessWritesRenditions(t *testing.T) {
+	s, root := newService(t, []Rendition{
+		{Name: "thumb", MaxWidth: 40, Format: "jpeg", Quality: 80},
+		{Name: "medium", MaxWidth: 400},
+		{Name: "original"},
+	})
+	data := testPNG(t, 200, 100)
+
+	media, err := s.Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.Hash != Hash(data) || media.Format != "png" || media.Width != 200 || media.Height != 100 {
+		t.Errorf("media = %s %s %dx%d; want %s png 200x100",
+			media.Hash, media.Format, media.Width, media.Height, Hash(data))
+	}
+	if len(media.Renditions) != 3 {
+		t.Fatalf("got %d renditions; want 3", len(media.Renditions))
+	}
+
+	thumb := renditionNamed(media, "thumb")
+	if thumb.Format != "jpeg" || thumb.Width != 40 || thumb.Height != 20 {
+		t.Errorf("thumb = %s %dx%d; want jpeg 40x20", thumb.Format, thumb.Width, thumb.Height)
+	}
+	if filepath.Ext(thumb.Path) != ".jpg" {
+		t.Errorf("thumb path = %s; want a .jpg", thumb.Path)
+	}
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(thumb.Path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || cfg.Width != 40 || cfg.Height != 20 {
+		t.Errorf("thumb file = %s %dx%d; want jpeg 40x20", format, cfg.Width, cfg.Height)
+	}
+
+	if media.Path != renditionNamed(media, "original").Path {
+		t.Errorf("media path = %s; want the original rendition", media.Path)
+	}
+}
+
+func TestProcessPassesOriginalThrough(t *testing.T) {
+	s, root := newService(t, []Rendition{
+		{Name: "medium", MaxWidth: 400},
+		{Name: "original"},
+	})
+	data := testPNG(t, 200, 100)
+
+	media, err := s.Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither rendition needs resizing or re-encoding, so both keep the
+	// uploaded bytes
+	for _, r := range media.Renditions {
+		got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(r.Path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s rendition was re-encoded; want the uploaded bytes", r.Name)
+		}
+		if r.Width != 200 || r.Height != 100 || r.Bytes != int64(len(data)) {
+			t.Errorf("%s rendition = %dx%d, %d bytes; want 200x100, %d bytes",
+				r.Name, r.Width, r.Height, r.Bytes, len(data))
+		}
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	s, root := newService(t, DefaultRenditions)
+	if _, err := s.Process([]byte("not an image")); err == nil {
+		t.Fatal("processed a text file; want a decode error")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, mediaDir)); len(entries) != 0 {
+		t.Errorf("media directory has %d entries; want nothing written", len(entries))
+	}
+}
+
+func TestRemoveDeletesMediaFiles(t *testing.T) {
+	s, root := newService(t, DefaultRenditions)
+	media, err := s.Process(testPNG(t, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Remove(media)
+	for _, r := range media.Renditions {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(r.Path))); !os.IsNotExist(err) {
+			t.Errorf("%s rendition still exists after Remove: %v", r.Name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, mediaDir, filepath.FromSlash(s.storage.RelPath(media.Hash, "")))); !os.IsNotExist(err) {
+		t.Errorf("media directory still exists after Remove: %v", err)
+	}
+}
+
+func TestRemoveDeletesLegacyFiles(t *testing.T) {
+	s, root := newService(t, DefaultRenditions)
+	media := &models.Media{Hash: "abcdef", Path: "images/abcdef.png"}
+	legacy := []string{
+		filepath.Join(root, "images", "abcdef.png"),
+		filepath.Join(root, "thumbnails", "abcdef.jpg"),
+	}
+	for _, p := range legacy {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.Remove(media)
+	for _, p := range legacy {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Remove: %v", p, err)
+		}
+	}
+}
@@ -2,131 +2,290 @@
 package middleware
 
 import (
-	"database/sql"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"voidcase/internal/models" // Add models import
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/utils"
 )
 
+// untrackedPrefixes are paths that are not page views
+var untrackedPrefixes = []string{"/admin", "/static/", "/uploads/", "/login", "/logout", "/invite/", "/reset/"}
+
+// untrackedExtensions are file types requested alongside pages, such as
+// favicons and robots.txt, rather than pages themselves
+var untrackedExtensions = map[string]bool{
+	".css": true, ".js": true, ".map": true, ".ico": true, ".png": true,
+	".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+	".txt": true, ".xml": true, ".json": true, ".woff": true, ".woff2": true,
+}
+
+// AnalyticsOptions tunes the page view collector
+type AnalyticsOptions struct {
+	// QueueSize bounds the number of views waiting to be written. Views
+	// arriving while the queue is full are dropped and counted.
+	QueueSize int
+	// BatchSize is the number of views that triggers a write
+	BatchSize int
+	// FlushInterval is the longest a queued view waits to be written
+	FlushInterval time.Duration
+}
+
+// DefaultAnalyticsOptions suit a single SQLite writer
+var DefaultAnalyticsOptions = AnalyticsOptions{
+	QueueSize:     1024,
+	BatchSize:     100,
+	FlushInterval: 500 * time.Millisecond,
+}
+
+// pageEvent is a view waiting for its project and visitor hash to be
+// resolved by the collector
+type pageEvent struct {
+	view models.PageView
+	ip   string
+}
+
+// AnalyticsMiddleware records page views through a single collector
+// goroutine that writes them in batches
 type AnalyticsMiddleware struct {
-	db *sql.DB
+	store db.Store
+	opts  AnalyticsOptions
+
+	events  chan pageEvent
+	done    chan struct{}
+	stopped chan struct{}
+	dropped atomic.Uint64
+	once    sync.Once
+
+	// day and salt are only used by the collector goroutine
+	day  string
+	salt string
 }
 
-func NewAnalyticsMiddleware(db *sql.DB) *AnalyticsMiddleware {
-	return &AnalyticsMiddleware{db: db}
+// NewAnalyticsMiddleware starts the collector. Call Close to flush queued
+// views before exiting.
+func NewAnalyticsMiddleware(store db.Store, opts AnalyticsOptions) *AnalyticsMiddleware {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultAnalyticsOptions.QueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultAnalyticsOptions.BatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultAnalyticsOptions.FlushInterval
+	}
+
+	am := &AnalyticsMiddleware{
+		store:   store,
+		opts:    opts,
+		events:  make(chan pageEvent, opts.QueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go am.run()
+	return am
+}
+
+// Dropped returns the number of views discarded because the queue was full
+func (am *AnalyticsMiddleware) Dropped() uint64 {
+	return am.dropped.Load()
+}
+
+// Close stops the collector after writing every queued view
+func (am *AnalyticsMiddleware) Close() {
+	am.once.Do(func() { close(am.done) })
+	<-am.stopped
+}
+
+// statusRecorder captures the status code written by the next handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
 }
 
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// TrackPageView records successful public GET requests from browsers
 func (am *AnalyticsMiddleware) TrackPageView(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Don't track admin pages
-		if !strings.HasPrefix(r.URL.Path, "/admin") {
-			go am.savePageView(r.URL.Path, r.Header.Get("Referer"))
+		if !trackable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status != http.StatusOK {
+			return
 		}
-		next.ServeHTTP(w, r)
+
+		browser, platform := utils.ParseUserAgent(r.UserAgent())
+		view := models.PageView{
+			PagePath: r.URL.Path,
+			Referrer: externalReferrer(r),
+			ViewedAt: time.Now().UTC(),
+			Browser:  browser,
+			Platform: platform,
+		}
+		am.enqueue(pageEvent{view: view, ip: utils.ClientIP(r)})
 	})
 }
 
-func (am *AnalyticsMiddleware) savePageView(path, referrer string) {
-	_, err := am.db.Exec(`
-        INSERT INTO analytics (page_path, referrer, viewed_at)
-        VALUES (?, ?, ?)`,
-		path, referrer, time.Now())
-	if err != nil {
-		log.Printf("Failed to save analytics: %v", err)
+// enqueue hands a view to the collector without blocking the request
+func (am *AnalyticsMiddleware) enqueue(e pageEvent) {
+	select {
+	case <-am.done:
+		return
+	default:
+	}
+
+	select {
+	case am.events <- e:
+	default:
+		am.dropped.Add(1)
 	}
 }
 
-func (am *AnalyticsMiddleware) GetAnalyticsSummary(days int) (*models.AnalyticsSummary, error) {
-	summary := &models.AnalyticsSummary{
-		TopPages:     make(map[string]int),
-		TopReferrers: make(map[string]int),
-		ProjectViews: make(map[int64]int),
-		DailyViews:   make([]models.DailyViews, 0),
+func (am *AnalyticsMiddleware) run() {
+	defer close(am.stopped)
+
+	ticker := time.NewTicker(am.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]pageEvent, 0, am.opts.BatchSize)
+	var reported uint64
+	flush := func() {
+		if dropped := am.Dropped(); dropped != reported {
+			log.Printf("Analytics queue full: dropped %d page views", dropped-reported)
+			reported = dropped
+		}
+		if len(batch) == 0 {
+			return
+		}
+		am.saveBatch(batch)
+		batch = batch[:0]
 	}
 
-	// Get total views
-	err := am.db.QueryRow(`
-        SELECT COUNT(*) FROM analytics 
-        WHERE viewed_at >= datetime('now', ?)
-    `, fmt.Sprintf("-%d days", days)).Scan(&summary.TotalViews)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get top pages
-	rows, err := am.db.Query(`
-        SELECT page_path, COUNT(*) as count
-        FROM analytics
-        WHERE viewed_at >= datetime('now', ?)
-        GROUP BY page_path
-        ORDER BY count DESC
-        LIMIT 10
-    `, fmt.Sprintf("-%d days", days))
-	if err != nil {
-		return nil, err
+	for {
+		select {
+		case e := <-am.events:
+			batch = append(batch, e)
+			if len(batch) >= am.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-am.done:
+			for {
+				select {
+				case e := <-am.events:
+					batch = append(batch, e)
+					if len(batch) >= am.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
 	}
-	defer rows.Close()
+}
 
-	for rows.Next() {
-		var path string
-		var count int
-		if err := rows.Scan(&path, &count); err != nil {
-			return nil, err
+func trackable(r *http.Request) bool {
+	if r.Method != http.MethodGet || utils.IsBot(r.UserAgent()) {
+		return false
+	}
+	if untrackedExtensions[strings.ToLower(path.Ext(r.URL.Path))] {
+		return false
+	}
+	for _, prefix := range untrackedPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
 		}
-		summary.TopPages[path] = count
-	}
-
-	// Get top referrers
-	rows, err = am.db.Query(`
-        SELECT referrer, COUNT(*) as count
-        FROM analytics
-        WHERE viewed_at >= datetime('now', ?)
-        AND referrer != ''
-        GROUP BY referrer
-        ORDER BY count DESC
-        LIMIT 10`,
-		fmt.Sprintf("-%d days", days))
-	if err != nil {
-		return nil, err
 	}
-	defer rows.Close()
+	return true
+}
+
+// externalReferrer returns the referring URL, or "" for internal navigation
+func externalReferrer(r *http.Request) string {
+	ref := r.Referer()
+	if u, err := url.Parse(ref); err != nil || u.Host == "" || u.Host == r.Host {
+		return ""
+	}
+	return ref
+}
 
-	for rows.Next() {
-		var referrer string
-		var count int
-		if err := rows.Scan(&referrer, &count); err != nil {
-			return nil, err
+// saveBatch resolves projects and visitor hashes for a batch of events and
+// writes them in one transaction. Each path is looked up once per batch, and
+// an event whose visitor cannot be hashed is dropped on its own.
+func (am *AnalyticsMiddleware) saveBatch(batch []pageEvent) {
+	projects := make(map[string]*int64)
+	views := make([]models.PageView, 0, len(batch))
+	for _, e := range batch {
+		view := e.view
+		hash, err := am.visitorHash(e.ip, view.ViewedAt)
+		if err != nil {
+			log.Printf("Failed to hash visitor for %s: %v", view.PagePath, err)
+			continue
 		}
-		summary.TopReferrers[referrer] = count
+		view.IPHash = hash
+
+		id, ok := projects[view.PagePath]
+		if !ok {
+			id = am.projectID(view.PagePath)
+			projects[view.PagePath] = id
+		}
+		view.ProjectID = id
+		views = append(views, view)
+	}
+	if len(views) == 0 {
+		return
 	}
 
-	// Get daily views
-	rows, err = am.db.Query(`
-        SELECT date(viewed_at) as view_date, COUNT(*) as views
-        FROM analytics
-        WHERE viewed_at > datetime('now', '-? days')
-        GROUP BY date(viewed_at)
-        ORDER BY view_date`, days)
+	if err := am.store.SavePageViews(views); err != nil {
+		log.Printf("Failed to save analytics: %v", err)
+	}
+}
+
+// projectID resolves the project behind a /work/{slug} path
+func (am *AnalyticsMiddleware) projectID(pagePath string) *int64 {
+	slug, ok := strings.CutPrefix(pagePath, "/work/")
+	if !ok {
+		return nil
+	}
+	id, err := am.store.ProjectIDBySlug(slug)
 	if err != nil {
-		return nil, err
+		return nil
 	}
-	defer rows.Close()
+	return &id
+}
+
+// visitorHash hashes ip with the current day's salt, so a visitor counts
+// once per day and hashes cannot be linked across days
+func (am *AnalyticsMiddleware) visitorHash(ip string, at time.Time) (string, error) {
+	day := at.UTC().Format("2006-01-02")
 
-	for rows.Next() {
-		var viewDate time.Time
-		var count int
-		if err := rows.Scan(&viewDate, &count); err != nil {
-			return nil, err
+	if am.day != day {
+		salt, err := am.store.DailySalt(day)
+		if err != nil {
+			return "", err
 		}
-		summary.DailyViews = append(summary.DailyViews, models.DailyViews{
-			Date:  viewDate,
-			Count: count,
-		})
+		am.day, am.salt = day, salt
 	}
 
-	return summary, nil
+	hash := sha256.Sum256([]byte(am.salt + ip))
+	return hex.EncodeToString(hash[:]), nil
 }
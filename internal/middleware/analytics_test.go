@@ -0,0 +1,184 @@
+// internal/middleware/analytics_test.go
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"voidcase/internal/db"
+	"voidcase/internal/db/dbtest"
+	"voidcase/internal/models"
+)
+
+const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+// analyticsStore records the batches written by the collector. When saving
+// is set, each write announces itself there and then waits on release.
+type analyticsStore struct {
+	db.Store
+
+	mu      sync.Mutex
+	batches [][]models.PageView
+	lookups int
+	badDay  string
+
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func newAnalyticsStore() *analyticsStore {
+	return &analyticsStore{Store: db.NewMemoryStore()}
+}
+
+func (s *analyticsStore) SavePageViews(views []models.PageView) error {
+	if s.saving != nil {
+		s.saving <- struct{}{}
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]models.PageView(nil), views...))
+	return s.Store.SavePageViews(views)
+}
+
+func (s *analyticsStore) ProjectIDBySlug(slug string) (int64, error) {
+	s.mu.Lock()
+	s.lookups++
+	s.mu.Unlock()
+	return s.Store.ProjectIDBySlug(slug)
+}
+
+func (s *analyticsStore) DailySalt(day string) (string, error) {
+	if day == s.badDay {
+		return "", errors.New("salt unavailable")
+	}
+	return s.Store.DailySalt(day)
+}
+
+func (s *analyticsStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+// visit sends a browser's GET for target through the tracker
+func visit(am *AnalyticsMiddleware, target string) {
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header.Set("User-Agent", firefox)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	am.TrackPageView(ok).ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func equalSizes(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAnalyticsWritesFullBatches(t *testing.T) {
+	store := newAnalyticsStore()
+	p := dbtest.CreateProject(t, store, "Night Drive")
+	am := NewAnalyticsMiddleware(store, AnalyticsOptions{BatchSize: 3, FlushInterval: time.Hour})
+	defer am.Close()
+
+	for i := 0; i < 3; i++ {
+		visit(am, "/work/night-drive")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if sizes := store.batchSizes(); !equalSizes(sizes, []int{3}) {
+		t.Fatalf("batch sizes = %v; want one batch of 3 before the flush interval", sizes)
+	}
+	for _, v := range store.batches[0] {
+		if v.ProjectID == nil || *v.ProjectID != p.ID || v.Browser != "Firefox" || v.IPHash == "" {
+			t.Errorf("view = %+v; want project %d from a hashed Firefox visitor", v, p.ID)
+		}
+	}
+	if store.lookups != 1 {
+		t.Errorf("resolved the slug %d times; want once per batch", store.lookups)
+	}
+}
+
+func TestAnalyticsFlushesOnClose(t *testing.T) {
+	store := newAnalyticsStore()
+	am := NewAnalyticsMiddleware(store, AnalyticsOptions{BatchSize: 100, FlushInterval: time.Hour})
+
+	visit(am, "/")
+	visit(am, "/about")
+	visit(am, "/admin")
+	am.Close()
+
+	if sizes := store.batchSizes(); !equalSizes(sizes, []int{2}) {
+		t.Errorf("batch sizes after Close = %v; want the 2 public views written", sizes)
+	}
+	visit(am, "/")
+	if sizes := store.batchSizes(); !equalSizes(sizes, []int{2}) {
+		t.Errorf("batch sizes = %v; want views after Close ignored", sizes)
+	}
+}
+
+func TestAnalyticsCountsDroppedViews(t *testing.T) {
+	store := newAnalyticsStore()
+	store.saving = make(chan struct{})
+	store.release = make(chan struct{})
+	am := NewAnalyticsMiddleware(store, AnalyticsOptions{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour})
+
+	// The collector takes the first view and blocks writing it, leaving
+	// room for one more in the queue
+	visit(am, "/")
+	<-store.saving
+	for i := 0; i < 3; i++ {
+		visit(am, "/")
+	}
+	if n := am.Dropped(); n != 2 {
+		t.Errorf("Dropped = %d; want 2", n)
+	}
+
+	close(store.release)
+	go func() {
+		for range store.saving {
+		}
+	}()
+	am.Close()
+	close(store.saving)
+	if sizes := store.batchSizes(); !equalSizes(sizes, []int{1, 1}) {
+		t.Errorf("batch sizes = %v; want the 2 queued views written", sizes)
+	}
+}
+
+func TestSaveBatchSkipsViewsThatFailToHash(t *testing.T) {
+	store := newAnalyticsStore()
+	store.badDay = "2024-03-05"
+	am := &AnalyticsMiddleware{store: store}
+
+	monday := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	am.saveBatch([]pageEvent{
+		{view: models.PageView{PagePath: "/", ViewedAt: monday}, ip: "192.0.2.1"},
+		{view: models.PageView{PagePath: "/", ViewedAt: monday.AddDate(0, 0, 1)}, ip: "192.0.2.2"},
+		{view: models.PageView{PagePath: "/about", ViewedAt: monday}, ip: "192.0.2.3"},
+	})
+
+	if sizes := store.batchSizes(); !equalSizes(sizes, []int{2}) {
+		t.Fatalf("batch sizes = %v; want the 2 views that could be hashed", sizes)
+	}
+	if got := store.batches[0][1].PagePath; got != "/about" {
+		t.Errorf("second saved view = %s; want /about", got)
+	}
+}
@@ -2,37 +2,102 @@
 package middleware
 
 import (
-	"database/sql"
+	"log"
 	"net/http"
+	"time"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+	"voidcase/internal/utils"
 )
 
+// authStore is the part of the store needed to resolve a session's user
+type authStore interface {
+	db.SessionStore
+	db.UserStore
+}
+
+// touchInterval is how often a session in use has its expiry extended, so
+// that not every request writes to the database
+const touchInterval = time.Minute
+
 type AuthMiddleware struct {
-	db *sql.DB
+	store   authStore
+	cfg     *config.Config
+	cookies *session.Cookies
+	// now is the clock used for session expiry
+	now func() time.Time
 }
 
-func NewAuthMiddleware(db *sql.DB) *AuthMiddleware {
-	return &AuthMiddleware{db: db}
+func NewAuthMiddleware(store authStore, cfg *config.Config) *AuthMiddleware {
+	return &AuthMiddleware{store: store, cfg: cfg, cookies: session.NewCookies(cfg), now: time.Now}
 }
 
+// RequireAuth redirects to the login page unless the request has a valid
+// session for an enabled account. Using a session extends it by the idle
+// timeout, up to its maximum age. The account and session are added to the
+// request context for session.CurrentUser and session.CurrentSession.
 func (am *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		session, err := r.Cookie("session")
+		token, err := am.cookies.Token(r)
 		if err != nil {
-			http.Redirect(w, r, "/admin/login", http.StatusFound)
+			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
 
-		var userID int64
-		err = am.db.QueryRow(`
-            SELECT user_id FROM sessions 
-            WHERE id = ? AND expires_at > datetime('now')
-        `, session.Value).Scan(&userID)
-
+		s, err := am.store.GetSession(session.HashToken(token))
 		if err != nil {
-			http.Redirect(w, r, "/admin/login", http.StatusFound)
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+
+		user, err := am.store.GetUser(s.UserID)
+		if err != nil || user.Disabled() {
+			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		if now := am.now(); now.Sub(s.LastSeenAt) >= touchInterval {
+			am.renew(w, r, token, s, now)
+		}
+
+		ctx := session.WithSession(session.WithUser(r.Context(), user), s)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// renew moves the session's expiry to the idle timeout from now, capped at
+// its maximum age, and reissues the cookie to match
+func (am *AuthMiddleware) renew(w http.ResponseWriter, r *http.Request, token string, s *models.Session, now time.Time) {
+	expires := now.Add(am.cfg.SessionIdleTimeout.Duration)
+	if limit := s.CreatedAt.Add(am.cfg.SessionMaxAge.Duration); expires.After(limit) {
+		expires = limit
+	}
+	if err := am.store.TouchSession(s.ID, utils.ClientIP(r), now, expires); err != nil {
+		log.Printf("Session renewal error: %v", err)
+		return
+	}
+	s.IP = utils.ClientIP(r)
+	s.LastSeenAt = now
+	s.ExpiresAt = expires
+	if err := am.cookies.Set(w, token, expires); err != nil {
+		log.Printf("Session cookie error: %v", err)
+	}
+}
+
+// RequireRole returns middleware that rejects users below min with 403. It
+// must run after RequireAuth.
+func (am *AuthMiddleware) RequireRole(min models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := session.CurrentUser(r.Context())
+			if user == nil || !user.Role.AtLeast(min) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// internal/middleware/auth_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/config"
+	"voidcase/internal/db"
+	"voidcase/internal/models"
+	"voidcase/internal/session"
+)
+
+// signedIn holds a session for ada that started at start, and the cookie
+// carrying it
+type signedIn struct {
+	store  *db.MemoryStore
+	am     *AuthMiddleware
+	start  time.Time
+	cookie *http.Cookie
+	userID int64
+	id     int64
+}
+
+func signIn(t *testing.T, user models.User) *signedIn {
+	t.Helper()
+	cfg := config.Default()
+	cfg.CSRFKey = strings.Repeat("ab", 32)
+	cfg.SessionKey = strings.Repeat("cd", 32)
+	cfg.SessionIdleTimeout = config.Duration{Duration: 30 * time.Minute}
+	cfg.SessionMaxAge = config.Duration{Duration: time.Hour}
+
+	store := db.NewMemoryStore()
+	u := store.AddUser(user)
+	start := time.Now()
+	s := &models.Session{UserID: u.ID, TokenHash: session.HashToken("token"),
+		CreatedAt: start, LastSeenAt: start, ExpiresAt: start.Add(30 * time.Minute)}
+	if err := store.CreateSession(s); err != nil {
+		t.Fatal(err)
+	}
+
+	am := NewAuthMiddleware(store, cfg)
+	rec := httptest.NewRecorder()
+	if err := am.cookies.Set(rec, "token", s.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	return &signedIn{store: store, am: am, start: start, cookie: rec.Result().Cookies()[0],
+		userID: u.ID, id: s.ID}
+}
+
+// get requests /admin after elapsed and returns the response
+func (s *signedIn) get(elapsed time.Duration) *http.Response {
+	s.am.now = func() time.Time { return s.start.Add(elapsed) }
+	r := httptest.NewRequest("GET", "/admin", nil)
+	r.AddCookie(s.cookie)
+	rec := httptest.NewRecorder()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	s.am.RequireAuth(ok).ServeHTTP(rec, r)
+	return rec.Result()
+}
+
+func (s *signedIn) expires(t *testing.T) time.Time {
+	t.Helper()
+	sessions, err := s.store.ListUserSessions(s.userID)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("sessions = %v, %v; want one", sessions, err)
+	}
+	return sessions[0].ExpiresAt
+}
+
+func TestRequireAuthSlidesExpiry(t *testing.T) {
+	s := signIn(t, models.User{Username: "ada", Role: models.RoleEditor})
+
+	// Within touchInterval of the last use nothing is written
+	res := s.get(touchInterval / 2)
+	if res.StatusCode != http.StatusOK || len(res.Cookies()) != 0 {
+		t.Fatalf("early request = %d with %d cookies; want 200 and no new cookie", res.StatusCode, len(res.Cookies()))
+	}
+	if got := s.expires(t); !got.Equal(s.start.Add(30 * time.Minute)) {
+		t.Errorf("expiry after an early request = %v; want it unchanged", got)
+	}
+
+	// Later uses push the expiry a full idle timeout ahead
+	res = s.get(2 * time.Minute)
+	if res.StatusCode != http.StatusOK || len(res.Cookies()) != 1 {
+		t.Fatalf("request = %d with %d cookies; want 200 and a reissued cookie", res.StatusCode, len(res.Cookies()))
+	}
+	if got, want := s.expires(t), s.start.Add(32*time.Minute); !got.Equal(want) {
+		t.Errorf("expiry = %v; want %v", got, want)
+	}
+
+	// ...but never past the maximum age
+	s.get(45 * time.Minute)
+	if got, want := s.expires(t), s.start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("expiry near the maximum age = %v; want %v", got, want)
+	}
+}
+
+func TestRequireAuthRejectsRevokedAndDisabled(t *testing.T) {
+	s := signIn(t, models.User{Username: "ada", Role: models.RoleEditor})
+	if err := s.store.RevokeSession(s.userID, s.id); err != nil {
+		t.Fatal(err)
+	}
+	if res := s.get(0); res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/login" {
+		t.Errorf("revoked session = %d to %q; want a redirect to /login", res.StatusCode, res.Header.Get("Location"))
+	}
+
+	disabledAt := time.Now()
+	s = signIn(t, models.User{Username: "ada", Role: models.RoleEditor, DisabledAt: &disabledAt})
+	if res := s.get(0); res.StatusCode != http.StatusFound {
+		t.Errorf("disabled account = %d; want a redirect to /login", res.StatusCode)
+	}
+}
@@ -0,0 +1,67 @@
+// internal/middleware/limit.go
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"voidcase/internal/config"
+
+	"github.com/gorilla/mux"
+)
+
+// LimitBody bounds every request body to maxBytes. It must run on the
+// router, after the route is matched, and before csrf.Protect, which reads
+// the whole form to find the token. Multipart bodies are only accepted by
+// the routes whose path templates are listed in uploadRoutes: they are
+// parsed here, under the limit, so an oversized upload is refused before
+// anything else reads it, and every other route refuses them unread.
+func LimitBody(maxBytes int64, uploadRoutes ...string) func(http.Handler) http.Handler {
+	uploads := make(map[string]bool, len(uploadRoutes))
+	for _, route := range uploadRoutes {
+		uploads[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				tooLarge(w, maxBytes)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+				if !uploads[routeTemplate(r)] {
+					http.Error(w, "Uploads are not accepted here", http.StatusUnsupportedMediaType)
+					return
+				}
+				var maxErr *http.MaxBytesError
+				if err := r.ParseMultipartForm(config.UploadMemory); errors.As(err, &maxErr) {
+					tooLarge(w, maxBytes)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeTemplate returns the path template of the route mux matched, or ""
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, _ := route.GetPathTemplate()
+	return template
+}
+
+func tooLarge(w http.ResponseWriter, maxBytes int64) {
+	limit := fmt.Sprintf("%d MB", maxBytes>>20)
+	if maxBytes < 1<<20 {
+		limit = fmt.Sprintf("%d KB", maxBytes>>10)
+	}
+	http.Error(w, "Request exceeds the "+limit+" limit", http.StatusRequestEntityTooLarge)
+}
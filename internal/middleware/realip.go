@@ -0,0 +1,59 @@
+// internal/middleware/realip.go
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"voidcase/internal/utils"
+)
+
+// RealIP attributes requests that arrive through a trusted proxy to the
+// client the proxy names in X-Forwarded-For, by rewriting r.RemoteAddr. The
+// header is read from the right, skipping trusted proxies, since clients
+// can put anything at its start. It must run before anything that reads
+// the client address.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedClient(r, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the nearest untrusted address in X-Forwarded-For,
+// or "" if the request did not come from a trusted proxy
+func forwardedClient(r *http.Request, trusted []*net.IPNet) string {
+	peer := net.ParseIP(utils.ClientIP(r))
+	if peer == nil || !inNets(peer, trusted) {
+		return ""
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !inNets(ip, trusted) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+func inNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+// internal/migrate/migrate_test.go
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoadNumbersMigrationsInOrder(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d is %04d_%s; want versions numbered 1 to %d without gaps",
+				i, m.Version, m.Name, len(migrations))
+		}
+		if (m.SQL == "") == (m.Func == nil) {
+			t.Errorf("migration %04d_%s needs exactly one of SQL and Func", m.Version, m.Name)
+		}
+	}
+	if migrations[0].Name != "initial_schema" || migrations[1].Name != "project_slugs" {
+		t.Errorf("first migrations = %s, %s; want initial_schema, project_slugs",
+			migrations[0].Name, migrations[1].Name)
+	}
+}
+
+// recording returns migrations that append their version to ran when applied
+func recording(ran *[]int, versions ...int) []Migration {
+	var migrations []Migration
+	for _, v := range versions {
+		v := v
+		migrations = append(migrations, Migration{Version: v, Name: "step", Func: func(tx *sql.Tx) error {
+			*ran = append(*ran, v)
+			_, err := tx.Exec(fmt.Sprintf("CREATE TABLE step%d (id INTEGER)", v))
+			return err
+		}})
+	}
+	return migrations
+}
+
+func TestApplyRunsPendingMigrationsOnce(t *testing.T) {
+	db := openDB(t)
+	var ran []int
+
+	applied, err := apply(db, recording(&ran, 1, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3 || len(ran) != 3 || ran[0] != 1 || ran[1] != 2 || ran[2] != 3 {
+		t.Fatalf("first run applied %d, ran %v; want 1, 2, 3 in order", len(applied), ran)
+	}
+
+	// A re-run does nothing, and a later run applies only what was added
+	ran = nil
+	if applied, err := apply(db, recording(&ran, 1, 2, 3)); err != nil || len(applied) != 0 || len(ran) != 0 {
+		t.Fatalf("re-run applied %v, ran %v, %v; want nothing", applied, ran, err)
+	}
+	if applied, err := apply(db, recording(&ran, 1, 2, 3, 4)); err != nil || len(applied) != 1 || applied[0].Version != 4 {
+		t.Fatalf("run with a new migration applied %v, %v; want only 4", applied, err)
+	}
+}
+
+func TestApplyStopsAtFailedMigration(t *testing.T) {
+	db := openDB(t)
+	var ran []int
+	failing := Migration{Version: 2, Name: "broken", Func: func(tx *sql.Tx) error {
+		if _, err := tx.Exec("CREATE TABLE partial (id INTEGER)"); err != nil {
+			return err
+		}
+		return errors.New("broken")
+	}}
+	migrations := append(recording(&ran, 1), failing)
+	migrations = append(migrations, recording(&ran, 3)...)
+
+	applied, err := apply(db, migrations)
+	if err == nil {
+		t.Fatal("apply succeeded past a failing migration")
+	}
+	if len(applied) != 1 || len(ran) != 1 {
+		t.Fatalf("applied %v, ran %v; want only migration 1", applied, ran)
+	}
+
+	// The failed migration's changes are rolled back and it stays pending
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'partial')").Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("failed migration's table was kept")
+	}
+	statuses, err := status(db, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false, false} {
+		if statuses[i].Applied != want {
+			t.Errorf("migration %d applied = %v; want %v", statuses[i].Version, statuses[i].Applied, want)
+		}
+	}
+	if statuses[0].AppliedAt.IsZero() || !statuses[1].AppliedAt.IsZero() {
+		t.Errorf("applied times = %v, %v; want only the first set", statuses[0].AppliedAt, statuses[1].AppliedAt)
+	}
+}
+
+func TestProjectSlugsBackfillsBaselineDatabase(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openDB(t)
+
+	// A database created before migrations existed already has the
+	// baseline schema, which 0001 adopts
+	if _, err := db.Exec(migrations[0].SQL); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Hello World", "Hello, world!", "???"} {
+		if _, err := db.Exec("INSERT INTO projects (title, date, created_at, updated_at) VALUES (?, '2024-03-01', datetime('now'), datetime('now'))", title); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := apply(db, migrations[:2]); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT slug FROM projects ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			t.Fatal(err)
+		}
+		slugs = append(slugs, slug)
+	}
+	if len(slugs) != 3 || slugs[0] != "hello-world" || slugs[1] != "hello-world-2" || slugs[2] != "project" {
+		t.Fatalf("slugs = %v; want hello-world, hello-world-2, project", slugs)
+	}
+	if _, err := db.Exec("UPDATE projects SET slug = 'hello-world' WHERE slug = 'project'"); err == nil {
+		t.Error("duplicate slug was accepted")
+	}
+}
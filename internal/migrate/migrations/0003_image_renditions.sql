@@ -0,0 +1,15 @@
+-- 0003_image_renditions.sql
+CREATE TABLE image_renditions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    path TEXT NOT NULL,
+    format TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    bytes INTEGER NOT NULL,
+    FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE,
+    UNIQUE (image_id, name)
+);
+
+CREATE INDEX idx_image_renditions_image_id ON image_renditions(image_id);
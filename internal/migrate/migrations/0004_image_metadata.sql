@@ -0,0 +1,15 @@
+-- 0004_image_metadata.sql
+ALTER TABLE images ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE images ADD COLUMN caption TEXT NOT NULL DEFAULT '';
+ALTER TABLE images ADD COLUMN alt_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE images ADD COLUMN is_cover BOOLEAN NOT NULL DEFAULT 0;
+
+-- Preserve the existing upload order within each project
+UPDATE images SET position = (
+    SELECT COUNT(*) FROM images prior
+    WHERE prior.project_id = images.project_id
+    AND (prior.created_at < images.created_at
+         OR (prior.created_at = images.created_at AND prior.id < images.id))
+);
+
+CREATE INDEX idx_images_project_position ON images(project_id, position);
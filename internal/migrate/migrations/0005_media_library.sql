@@ -0,0 +1,83 @@
+-- 0005_media_library.sql
+-- Media is keyed by content hash and shared between projects through
+-- project_media. Existing images become media plus an attachment that keeps
+-- the image's id, so admin URLs referencing it stay valid.
+CREATE TABLE media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL UNIQUE,
+    path TEXT NOT NULL,
+    format TEXT NOT NULL DEFAULT '',
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+-- Image paths were stored relative to the working directory; media paths
+-- are relative to the uploads directory
+INSERT INTO media (hash, path, created_at)
+SELECT hash,
+       CASE WHEN path LIKE 'data/uploads/%' THEN substr(path, 14) ELSE path END,
+       MIN(created_at)
+FROM images
+GROUP BY hash;
+
+UPDATE media SET (format, width, height, bytes) = (
+    SELECT r.format, r.width, r.height, r.bytes
+    FROM image_renditions r
+    JOIN images i ON i.id = r.image_id
+    WHERE i.hash = media.hash AND r.name = 'original'
+)
+WHERE EXISTS (
+    SELECT 1 FROM image_renditions r
+    JOIN images i ON i.id = r.image_id
+    WHERE i.hash = media.hash AND r.name = 'original'
+);
+
+CREATE TABLE project_media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id INTEGER NOT NULL,
+    media_id INTEGER NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    caption TEXT NOT NULL DEFAULT '',
+    alt_text TEXT NOT NULL DEFAULT '',
+    is_cover BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (media_id) REFERENCES media(id),
+    UNIQUE (project_id, media_id)
+);
+
+INSERT INTO project_media (id, project_id, media_id, position, caption, alt_text, is_cover, created_at)
+SELECT i.id, i.project_id, m.id, i.position, i.caption, i.alt_text, i.is_cover, i.created_at
+FROM images i
+JOIN media m ON m.hash = i.hash;
+
+CREATE INDEX idx_project_media_project_position ON project_media(project_id, position);
+CREATE INDEX idx_project_media_media_id ON project_media(media_id);
+
+-- Renditions belong to the media rather than to an attachment
+CREATE TABLE image_renditions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    media_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    path TEXT NOT NULL,
+    format TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    bytes INTEGER NOT NULL,
+    FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE,
+    UNIQUE (media_id, name)
+);
+
+INSERT INTO image_renditions_new (id, media_id, name, path, format, width, height, bytes)
+SELECT r.id, m.id, r.name, r.path, r.format, r.width, r.height, r.bytes
+FROM image_renditions r
+JOIN images i ON i.id = r.image_id
+JOIN media m ON m.hash = i.hash;
+
+DROP TABLE image_renditions;
+ALTER TABLE image_renditions_new RENAME TO image_renditions;
+CREATE INDEX idx_image_renditions_media_id ON image_renditions(media_id);
+
+DROP TABLE images;
@@ -0,0 +1,21 @@
+-- 0006_analytics_pipeline.sql
+-- page_views becomes the only analytics table. Views recorded in the old
+-- analytics table are carried over without a visitor hash.
+ALTER TABLE page_views ADD COLUMN browser TEXT NOT NULL DEFAULT '';
+ALTER TABLE page_views ADD COLUMN platform TEXT NOT NULL DEFAULT '';
+
+INSERT INTO page_views (page_path, referrer, project_id, ip_hash, browser, platform, created_at)
+SELECT page_path, COALESCE(referrer, ''), project_id, '',
+       COALESCE(browser, ''), COALESCE(platform, ''), viewed_at
+FROM analytics;
+
+DROP TABLE analytics;
+
+CREATE INDEX idx_page_views_project_id ON page_views(project_id);
+
+-- One random salt per UTC day, used to hash visitor IPs. Old salts are
+-- deleted so past hashes cannot be linked to an address.
+CREATE TABLE analytics_salts (
+    day TEXT PRIMARY KEY,
+    salt TEXT NOT NULL
+);
@@ -0,0 +1,19 @@
+-- 0007_user_roles.sql
+-- Accounts created before roles existed were the single admin, so they
+-- become owners. New accounts default to the least privileged role.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'viewer'
+    CHECK (role IN ('owner', 'editor', 'viewer'));
+ALTER TABLE users ADD COLUMN disabled_at DATETIME;
+UPDATE users SET role = 'owner';
+
+ALTER TABLE projects ADD COLUMN updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE invites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME
+);
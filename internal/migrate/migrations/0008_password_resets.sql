@@ -0,0 +1,12 @@
+-- 0008_password_resets.sql
+-- One-time password reset links. Only the SHA-256 of each token is stored.
+CREATE TABLE password_resets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets(user_id);
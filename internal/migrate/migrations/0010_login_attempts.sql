@@ -0,0 +1,14 @@
+-- 0010_login_attempts.sql
+-- Every sign-in attempt, kept for throttling and shown on the dashboard.
+-- username is what was typed, so it may not name an account.
+CREATE TABLE login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    success BOOLEAN NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_username ON login_attempts(username, created_at);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip, created_at);
+CREATE INDEX idx_login_attempts_created_at ON login_attempts(created_at);
@@ -0,0 +1,19 @@
+-- 0011_session_devices.sql
+-- Sessions are now found by the SHA-256 of the cookie token rather than the
+-- token itself, so existing sessions cannot be carried over and everyone
+-- signs in again. Each session also records the device it belongs to.
+DROP TABLE sessions;
+
+CREATE TABLE sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_session_expires ON sessions(expires_at);
@@ -0,0 +1,6 @@
+-- 0013_project_trash.sql
+-- Deleted projects keep their row, tags and images until purged from the
+-- trash, so they can be restored. Live projects have no deleted_at.
+ALTER TABLE projects ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX idx_projects_deleted_at ON projects(deleted_at);
@@ -0,0 +1,20 @@
+-- 0014_project_revisions.sql
+-- Earlier states of a project, one row per save that replaced them. tags and
+-- image_ids are JSON arrays; image_ids holds project_media IDs in display
+-- order. saved_at and saved_by are when and by whom the state was saved.
+CREATE TABLE project_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    video_embed TEXT NOT NULL DEFAULT '',
+    date DATETIME NOT NULL,
+    tags TEXT NOT NULL DEFAULT '[]',
+    image_ids TEXT NOT NULL DEFAULT '[]',
+    saved_at DATETIME NOT NULL,
+    saved_by INTEGER,
+    replaced_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_project_revisions_project ON project_revisions(project_id, id);
@@ -0,0 +1,8 @@
+-- 0015_project_status.sql
+-- Publication state of each project. Existing projects stay published.
+-- publish_at is set only for scheduled projects, which become published once
+-- it has passed.
+ALTER TABLE projects ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE projects ADD COLUMN publish_at DATETIME;
+
+CREATE INDEX idx_projects_status ON projects(status, publish_at);
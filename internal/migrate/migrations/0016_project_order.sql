@@ -0,0 +1,12 @@
+-- 0016_project_order.sql
+-- Manual ordering and featured pinning of projects. Existing projects start
+-- in their current newest-first order.
+ALTER TABLE projects ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN featured BOOLEAN NOT NULL DEFAULT 0;
+
+UPDATE projects SET sort_order = (
+    SELECT COUNT(*) FROM projects q
+    WHERE q.date > projects.date OR (q.date = projects.date AND q.id > projects.id)
+);
+
+CREATE INDEX idx_projects_sort_order ON projects(sort_order);
@@ -0,0 +1,9 @@
+-- 0017_tag_details.sql
+-- Tags get an optional description and cover image for their page. Tags no
+-- project uses are removed, as they now are whenever project tags change.
+ALTER TABLE tags ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE tags ADD COLUMN cover_media_id INTEGER REFERENCES media(id);
+
+DELETE FROM tags WHERE NOT EXISTS (
+    SELECT 1 FROM project_tags pt WHERE pt.tag_id = tags.id
+);
@@ -0,0 +1,19 @@
+-- 0018_categories.sql
+-- Categories are the tags offered as checkboxes on the project form and
+-- listed first wherever tags are shown, in position order. A category
+-- matches the tag of the same name. Hidden categories are left out of the
+-- site navigation. The four categories that used to be built in are kept.
+CREATE TABLE categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    slug TEXT NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    visible BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO categories (name, slug, position) VALUES
+    ('Commercial', 'commercial', 0),
+    ('Narrative', 'narrative', 1),
+    ('Music Video', 'music-video', 2),
+    ('Documentary', 'documentary', 3);
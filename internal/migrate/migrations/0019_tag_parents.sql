@@ -0,0 +1,6 @@
+-- 0019_tag_parents.sql
+-- Tags can sit beneath a parent tag, so filtering by a tag also finds the
+-- projects carrying the tags beneath it.
+ALTER TABLE tags ADD COLUMN parent_id INTEGER REFERENCES tags(id);
+
+CREATE INDEX idx_tags_parent_id ON tags(parent_id);
@@ -0,0 +1,60 @@
+-- 0020_project_search.sql
+-- The search index is an FTS5 table of each project's title, description and
+-- tag names keyed by project ID, kept in step by triggers on projects,
+-- project_tags and tags. It needs the SQLite driver built with
+-- -tags sqlite_fts5.
+DROP TRIGGER IF EXISTS project_search_insert;
+DROP TRIGGER IF EXISTS project_search_update;
+DROP TRIGGER IF EXISTS project_search_delete;
+DROP TRIGGER IF EXISTS project_search_tag_add;
+DROP TRIGGER IF EXISTS project_search_tag_remove;
+DROP TRIGGER IF EXISTS project_search_tag_rename;
+DROP TABLE IF EXISTS project_search;
+
+CREATE VIRTUAL TABLE project_search
+USING fts5(title, description, tags, tokenize = 'unicode61 remove_diacritics 2');
+
+INSERT INTO project_search (rowid, title, description, tags)
+SELECT p.id, p.title, COALESCE(p.description, ''), COALESCE((
+    SELECT group_concat(t.name, ' ') FROM tags t
+    JOIN project_tags pt ON pt.tag_id = t.id
+    WHERE pt.project_id = p.id), '')
+FROM projects p;
+
+CREATE TRIGGER project_search_insert AFTER INSERT ON projects BEGIN
+    INSERT INTO project_search (rowid, title, description, tags)
+    VALUES (new.id, new.title, COALESCE(new.description, ''), '');
+END;
+
+CREATE TRIGGER project_search_update AFTER UPDATE OF title, description ON projects BEGIN
+    UPDATE project_search SET title = new.title, description = COALESCE(new.description, '')
+    WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER project_search_delete AFTER DELETE ON projects BEGIN
+    DELETE FROM project_search WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER project_search_tag_add AFTER INSERT ON project_tags BEGIN
+    UPDATE project_search SET tags = COALESCE((
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN project_tags pt ON pt.tag_id = t.id
+        WHERE pt.project_id = new.project_id), '')
+    WHERE rowid = new.project_id;
+END;
+
+CREATE TRIGGER project_search_tag_remove AFTER DELETE ON project_tags BEGIN
+    UPDATE project_search SET tags = COALESCE((
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN project_tags pt ON pt.tag_id = t.id
+        WHERE pt.project_id = old.project_id), '')
+    WHERE rowid = old.project_id;
+END;
+
+CREATE TRIGGER project_search_tag_rename AFTER UPDATE OF name ON tags BEGIN
+    UPDATE project_search SET tags = COALESCE((
+        SELECT group_concat(t.name, ' ') FROM tags t
+        JOIN project_tags pt ON pt.tag_id = t.id
+        WHERE pt.project_id = project_search.rowid), '')
+    WHERE rowid IN (SELECT project_id FROM project_tags WHERE tag_id = new.id);
+END;
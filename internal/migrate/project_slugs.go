@@ -0,0 +1,60 @@
+// internal/migrate/project_slugs.go
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+
+	"voidcase/internal/utils"
+)
+
+// projectSlugs adds the slug column that project URLs are built from and
+// backfills a unique slug for every existing project. Slugs are derived in
+// Go, as SQL cannot apply utils.Slugify.
+func projectSlugs(tx *sql.Tx) error {
+	if _, err := tx.Exec("ALTER TABLE projects ADD COLUMN slug TEXT"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"CREATE UNIQUE INDEX idx_project_slug ON projects(slug)"); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, title FROM projects ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type project struct {
+		id    int64
+		title string
+	}
+	var projects []project
+	for rows.Next() {
+		var p project
+		if err := rows.Scan(&p.id, &p.title); err != nil {
+			return err
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// Projects are numbered by age, so the oldest keeps the plain slug
+	taken := make(map[string]bool)
+	for _, p := range projects {
+		base := utils.Slugify(p.title)
+		slug := base
+		for i := 2; taken[slug]; i++ {
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		taken[slug] = true
+		if _, err := tx.Exec("UPDATE projects SET slug = ? WHERE id = ?", slug, p.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
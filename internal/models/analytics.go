@@ -7,18 +7,23 @@ type PageView struct {
 	ID        int64     `db:"id"`
 	PagePath  string    `db:"page_path"`
 	Referrer  string    `db:"referrer"`
-	ViewedAt  time.Time `db:"viewed_at"`
+	ViewedAt  time.Time `db:"created_at"`
 	ProjectID *int64    `db:"project_id"`
-	IPHash    string    `db:"ip_hash"`
+	// IPHash identifies a visitor for one day without storing the address
+	IPHash   string `db:"ip_hash"`
+	Browser  string `db:"browser"`
+	Platform string `db:"platform"`
 }
 
 type AnalyticsSummary struct {
-	TotalViews   int            `json:"total_views"`
-	UniqueViews  int            `json:"unique_views"`
-	ProjectViews map[int64]int  `json:"project_views"`
-	TopPages     map[string]int `json:"top_pages"`
-	TopReferrers map[string]int `json:"top_referrers"`
-	DailyViews   []DailyViews   `json:"daily_views"`
+	TotalViews   int             `json:"total_views"`
+	UniqueViews  int             `json:"unique_views"`
+	ProjectViews map[int64]int   `json:"project_views"`
+	TopPages     []PageCount     `json:"top_pages"`
+	TopReferrers []ReferrerCount `json:"top_referrers"`
+	Browsers     []NameCount     `json:"browsers"`
+	Platforms    []NameCount     `json:"platforms"`
+	DailyViews   []DailyViews    `json:"daily_views"`
 }
 
 type DailyViews struct {
@@ -26,7 +31,18 @@ type DailyViews struct {
 	Count int       `json:"count"`
 }
 
+type PageCount struct {
+	Path  string
+	Count int
+}
+
 type ReferrerCount struct {
 	Referrer string
-	Count    int64
+	Count    int
+}
+
+// NameCount is a view count grouped by browser or platform
+type NameCount struct {
+	Name  string
+	Count int
 }
@@ -2,31 +2,315 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
+// Role is the level of access a user has to the admin
+type Role string
+
+const (
+	// RoleViewer can read the admin but not change anything
+	RoleViewer Role = "viewer"
+	// RoleEditor can manage projects and media
+	RoleEditor Role = "editor"
+	// RoleOwner can also manage users and site settings
+	RoleOwner Role = "owner"
+)
+
+// Roles lists every role from least to most privileged
+var Roles = []Role{RoleViewer, RoleEditor, RoleOwner}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast reports whether r grants everything min does
+func (r Role) AtLeast(min Role) bool {
+	return r.Valid() && r.rank() >= min.rank()
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
 type User struct {
 	ID           int64     `db:"id"`
 	Username     string    `db:"username"`
 	PasswordHash string    `db:"password_hash"`
+	Role         Role      `db:"role"`
 	CreatedAt    time.Time `db:"created_at"`
+	// DisabledAt is set while the account is disabled and cannot sign in
+	DisabledAt *time.Time `db:"disabled_at"`
+	// TOTPSecret is the base32 authenticator secret, empty until enrolled
+	TOTPSecret string `db:"totp_secret"`
+	// TOTPLastStep is the last accepted TOTP time step
+	TOTPLastStep int64 `db:"totp_last_step"`
+}
+
+// Disabled reports whether the account has been disabled
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// TOTPEnabled reports whether sign-in requires an authenticator code
+func (u User) TOTPEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// TrustedDevice is a browser remembered after a successful second step.
+// Only the SHA-256 of the cookie token is stored.
+type TrustedDevice struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	TokenHash string    `db:"token_hash"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// Invite lets someone create their own account with a preset role. Only
+// the SHA-256 of the token is stored; the token itself is shown once.
+type Invite struct {
+	ID        int64      `db:"id"`
+	TokenHash string     `db:"token_hash"`
+	Role      Role       `db:"role"`
+	CreatedBy int64      `db:"created_by"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// PasswordReset is a one-time link for setting a new password. Only the
+// SHA-256 of the token is stored.
+type PasswordReset struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// LoginAttempt records one sign-in attempt. A failed password or second
+// factor is a failure; success means a session was started.
+type LoginAttempt struct {
+	ID        int64     `db:"id"`
+	Username  string    `db:"username"`
+	IP        string    `db:"ip"`
+	Success   bool      `db:"success"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LoginFailures counts failed attempts since the last success, separately
+// for a username and for an IP address
+type LoginFailures struct {
+	Username     int
+	LastUsername time.Time
+	IP           int
+	LastIP       time.Time
+}
+
+// Actor is who makes a change, as recorded in the audit log
+type Actor struct {
+	UserID   *int64
+	Username string
+	IP       string
+}
+
+// AuditEntry records one change. Before and After are JSON objects holding
+// the fields that changed; Before is empty for creations and After for
+// deletions.
+type AuditEntry struct {
+	ID         int64     `db:"id"`
+	ActorID    *int64    `db:"actor_id"`
+	ActorName  string    `db:"actor_name"`
+	Action     string    `db:"action"`
+	EntityType string    `db:"entity_type"`
+	EntityID   int64     `db:"entity_id"`
+	Before     string    `db:"before"`
+	After      string    `db:"after"`
+	IP         string    `db:"ip"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// AuditFilter narrows an audit log listing. Zero fields match everything.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   int64
+	Since      time.Time
+	Until      time.Time
+	// Limit of 0 returns every matching entry
+	Limit  int
+	Offset int
+}
+
+// ProjectStatus controls where a project appears on the public site
+type ProjectStatus string
+
+const (
+	// StatusDraft projects are only visible in the admin
+	StatusDraft ProjectStatus = "draft"
+	// StatusPublished projects are listed and can be viewed by anyone
+	StatusPublished ProjectStatus = "published"
+	// StatusUnlisted projects can be viewed by anyone with the link but are
+	// not listed
+	StatusUnlisted ProjectStatus = "unlisted"
+	// StatusScheduled projects are drafts until PublishAt, when they are
+	// published
+	StatusScheduled ProjectStatus = "scheduled"
+)
+
+// ProjectStatuses lists every status in the order offered to editors
+var ProjectStatuses = []ProjectStatus{StatusDraft, StatusPublished, StatusUnlisted, StatusScheduled}
+
+// Valid reports whether s is a known status
+func (s ProjectStatus) Valid() bool {
+	for _, status := range ProjectStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Viewable reports whether a project in status s can be opened on the
+// public site by its URL
+func (s ProjectStatus) Viewable() bool {
+	return s == StatusPublished || s == StatusUnlisted
+}
+
+// ProjectOrder is how a listing of projects is sorted
+type ProjectOrder string
+
+const (
+	// OrderManual follows the order set by dragging projects in the admin
+	OrderManual ProjectOrder = "manual"
+	// OrderDate puts the most recent projects first
+	OrderDate ProjectOrder = "date"
+	// OrderFeatured puts featured projects first in manual order, followed by
+	// the rest by date
+	OrderFeatured ProjectOrder = "featured"
+)
+
+// ProjectSearch selects projects by text and filters. Zero fields match any
+// project.
+type ProjectSearch struct {
+	// Query is matched against titles, descriptions and tag names; a project
+	// must contain every word
+	Query string
+	// Tag limits results to projects carrying the tag or a tag beneath it
+	Tag string
+	// Tags limits results to projects carrying every one of the tags, or
+	// any of them unless MatchAll is set, counting the tags beneath each
+	Tags     []string
+	MatchAll bool
+	Year     int
+	Status   ProjectStatus
+	// Listed limits results to the projects listed on the public site
+	Listed bool
+	// Order sorts results without a query, in the manual order if unset
+	Order ProjectOrder
+}
+
+// Markers around the matched words in a SearchResult snippet, which are
+// replaced with markup after the snippet is escaped
+const (
+	MatchStart = "\x02"
+	MatchEnd   = "\x03"
+)
+
+// SearchResult is a project found by a search, with an excerpt of the text
+// that matched when the search had a query
+type SearchResult struct {
+	Project
+	Snippet string
 }
 
 type Project struct {
 	ID          int64     `db:"id"`
 	Title       string    `db:"title"`
+	Slug        string    `db:"slug"`
 	Description string    `db:"description"`
 	VideoEmbed  string    `db:"video_embed"`
 	Date        time.Time `db:"date"`
 	CreatedAt   time.Time `db:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
-	Tags        []string  `db:"-"`
-	Images      []Image   `db:"-"`
+	// UpdatedBy is the user who last saved the project, if still known
+	UpdatedBy     *int64 `db:"updated_by"`
+	UpdatedByName string `db:"-"`
+	// DeletedAt is when the project was moved to the trash
+	DeletedAt *time.Time    `db:"deleted_at"`
+	Status    ProjectStatus `db:"status"`
+	// PublishAt is when a scheduled project is published
+	PublishAt *time.Time `db:"publish_at"`
+	// SortOrder is the project's position in the manual order, lowest first
+	SortOrder int `db:"sort_order"`
+	// Featured projects are pinned ahead of the rest in featured-first
+	// listings
+	Featured bool     `db:"featured"`
+	Tags     []string `db:"-"`
+	Images   []Image  `db:"-"`
 }
 
+// Cover returns the image chosen as the project's cover, falling back to the
+// first image, or nil when the project has none
+func (p Project) Cover() *Image {
+	for i := range p.Images {
+		if p.Images[i].IsCover {
+			return &p.Images[i]
+		}
+	}
+	if len(p.Images) > 0 {
+		return &p.Images[0]
+	}
+	return nil
+}
+
+// ProjectRevision is an earlier state of a project, kept when a later save
+// replaced it
+type ProjectRevision struct {
+	ID          int64
+	ProjectID   int64
+	Title       string
+	Slug        string
+	Description string
+	VideoEmbed  string
+	Date        time.Time
+	Tags        []string
+	// ImageIDs lists the project's images in display order
+	ImageIDs []int64
+	// SavedAt and SavedBy are when and by whom this state was saved
+	SavedAt     time.Time
+	SavedBy     *int64
+	SavedByName string
+	// ReplacedAt is when a later save replaced this state
+	ReplacedAt time.Time
+}
+
+// Tag labels projects. Its description and cover image are shown on the
+// tag's page.
 type Tag struct {
-	ID   int64  `db:"id"`
-	Name string `db:"name"`
+	ID          int64  `db:"id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	// CoverMediaID is the media library item shown as the tag's cover
+	CoverMediaID *int64 `db:"cover_media_id"`
+	Cover        *Media `db:"-"`
+	// ParentID is the tag this one sits beneath, if any
+	ParentID   *int64 `db:"parent_id"`
+	ParentName string `db:"-"`
+	// ProjectCount is the number of projects out of the trash carrying the
+	// tag
+	ProjectCount int `db:"-"`
 }
 
 type ProjectTag struct {
@@ -34,12 +318,94 @@ type ProjectTag struct {
 	TagID     int64 `db:"tag_id"`
 }
 
+// Media is a stored asset identified by the SHA-256 of its content. The same
+// media can be attached to any number of projects. Path is the original
+// file, relative to the uploads directory.
+type Media struct {
+	ID         int64            `db:"id"`
+	Hash       string           `db:"hash"`
+	Path       string           `db:"path"`
+	Format     string           `db:"format"`
+	Width      int              `db:"width"`
+	Height     int              `db:"height"`
+	Bytes      int64            `db:"bytes"`
+	CreatedAt  time.Time        `db:"created_at"`
+	Renditions []ImageRendition `db:"-"`
+	// RefCount is the number of projects the media is attached to plus the
+	// number of tags using it as their cover
+	RefCount int `db:"-"`
+	// CoverCount is the number of tags using the media as their cover
+	CoverCount int `db:"-"`
+}
+
+// Image is a media item attached to a project, with the per-project
+// ordering, caption and cover choice
 type Image struct {
 	ID        int64     `db:"id"`
 	ProjectID int64     `db:"project_id"`
-	Hash      string    `db:"hash"`
-	Path      string    `db:"path"`
+	Position  int       `db:"position"`
+	Caption   string    `db:"caption"`
+	AltText   string    `db:"alt_text"`
+	IsCover   bool      `db:"is_cover"`
 	CreatedAt time.Time `db:"created_at"`
+	Media
+}
+
+// ImageRendition is one generated size/format of a media item. Path is
+// relative to the uploads directory.
+type ImageRendition struct {
+	ID      int64  `db:"id"`
+	MediaID int64  `db:"media_id"`
+	Name    string `db:"name"`
+	Path    string `db:"path"`
+	Format  string `db:"format"`
+	Width   int    `db:"width"`
+	Height  int    `db:"height"`
+	Bytes   int64  `db:"bytes"`
+}
+
+// URL returns the public URL of the rendition
+func (r ImageRendition) URL() string {
+	return "/uploads/" + r.Path
+}
+
+// Rendition returns the named rendition, or nil if it was not generated
+func (m Media) Rendition(name string) *ImageRendition {
+	for idx := range m.Renditions {
+		if m.Renditions[idx].Name == name {
+			return &m.Renditions[idx]
+		}
+	}
+	return nil
+}
+
+// URL returns the public URL of the named rendition. Media uploaded before
+// renditions were recorded only have the legacy original and thumbnail.
+func (m Media) URL(name string) string {
+	if r := m.Rendition(name); r != nil {
+		return r.URL()
+	}
+	if r := m.Rendition("original"); r != nil {
+		return r.URL()
+	}
+	if name == "thumb" {
+		return "/uploads/thumbnails/" + m.Hash + ".jpg"
+	}
+	return "/uploads/" + m.Path
+}
+
+// SrcSet returns a srcset attribute value listing every rendition by width
+func (m Media) SrcSet() string {
+	var parts []string
+	seen := make(map[int]bool)
+	for _, r := range m.Renditions {
+		if r.Width == 0 || seen[r.Width] {
+			continue
+		}
+		seen[r.Width] = true
+		parts = append(parts, fmt.Sprintf("%s %dw", r.URL(), r.Width))
+	}
+	return strings.Join(parts, ", ")
 }
 
 type CategoryCount struct {
@@ -47,16 +413,35 @@ type CategoryCount struct {
 	Count int
 }
 
-var CoreCategories = []string{
-	"Commercial",
-	"Narrative",
-	"Music Video",
-	"Documentary",
+// Category is a tag offered on the project form and listed ahead of other
+// tags. It applies to the projects carrying the tag of the same name.
+type Category struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+	// Slug is the category's segment in tag page URLs
+	Slug string `db:"slug"`
+	// Position orders categories, lowest first
+	Position int `db:"position"`
+	// Visible categories are shown in the site navigation
+	Visible bool `db:"visible"`
+	// ProjectCount is the number of projects out of the trash in the
+	// category
+	ProjectCount int `db:"-"`
 }
 
-func IsCoreCategory(tag string) bool {
-	for _, cat := range CoreCategories {
-		if cat == tag {
+// NavTag is a tag linked from the site navigation. Slug is its tag page URL
+// segment: the category slug for categories and the tag name otherwise.
+type NavTag struct {
+	Name string
+	Slug string
+	// Children are the tags beneath this one with listed projects
+	Children []NavTag
+}
+
+// IsCategory reports whether tag names one of categories, ignoring case
+func IsCategory(categories []Category, tag string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(c.Name, tag) {
 			return true
 		}
 	}
@@ -72,9 +457,15 @@ type SiteConfig struct {
 	UpdatedAt    time.Time `db:"updated_at"`
 }
 
+// Session is a signed-in browser. Only the SHA-256 of the cookie token is
+// stored.
 type Session struct {
-	ID        string    `db:"id"`
-	UserID    int64     `db:"user_id"`
-	CreatedAt time.Time `db:"created_at"`
-	ExpiresAt time.Time `db:"expires_at"`
+	ID         int64     `db:"id"`
+	UserID     int64     `db:"user_id"`
+	TokenHash  string    `db:"token_hash"`
+	UserAgent  string    `db:"user_agent"`
+	IP         string    `db:"ip"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
 }
@@ -1,7 +0,0 @@
-// internal/models/schema.go
-package models
-
-import _ "embed"
-
-//go:embed schema.sql
-var SchemaSQL string
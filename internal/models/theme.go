@@ -6,6 +6,9 @@ type Theme struct {
 	Name        string
 	TemplateDir string
 	StyleSheet  string
+	// HomeOrder and TagOrder sort the projects on the homepage and tag pages
+	HomeOrder ProjectOrder
+	TagOrder  ProjectOrder
 }
 
 // AvailableThemes defines the supported themes and their configurations
@@ -14,11 +17,15 @@ var AvailableThemes = map[string]Theme{
 		Name:        "Default",
 		TemplateDir: "templates/themes/default",
 		StyleSheet:  "/static/css/default/main.css",
+		HomeOrder:   OrderFeatured,
+		TagOrder:    OrderDate,
 	},
 	"minimal": {
 		Name:        "Minimal",
 		TemplateDir: "templates/themes/minimal",
 		StyleSheet:  "/static/css/minimal/main.css",
+		HomeOrder:   OrderManual,
+		TagOrder:    OrderManual,
 	},
 }
 
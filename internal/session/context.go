@@ -0,0 +1,36 @@
+// internal/session/context.go
+package session
+
+import (
+	"context"
+
+	"voidcase/internal/models"
+)
+
+type userKey struct{}
+
+type sessionKey struct{}
+
+// WithUser returns a copy of ctx carrying the signed-in user
+func WithUser(ctx context.Context, u *models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// CurrentUser returns the signed-in user stored by the auth middleware, or
+// nil outside authenticated routes
+func CurrentUser(ctx context.Context) *models.User {
+	u, _ := ctx.Value(userKey{}).(*models.User)
+	return u
+}
+
+// WithSession returns a copy of ctx carrying the request's session
+func WithSession(ctx context.Context, s *models.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// CurrentSession returns the session stored by the auth middleware, or nil
+// outside authenticated routes
+func CurrentSession(ctx context.Context) *models.Session {
+	s, _ := ctx.Value(sessionKey{}).(*models.Session)
+	return s
+}
@@ -0,0 +1,172 @@
+// internal/session/cookie.go
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"voidcase/internal/config"
+
+	"github.com/gorilla/securecookie"
+)
+
+// CookieName is the name of the admin session cookie
+const CookieName = "session"
+
+// Cookies reads and writes the admin session cookie. The session token is
+// signed with the configured session key so tampered cookies are rejected
+// before the database is consulted.
+type Cookies struct {
+	codec  *securecookie.SecureCookie
+	secure bool
+}
+
+func NewCookies(cfg *config.Config) *Cookies {
+	return &Cookies{
+		codec:  securecookie.New(cfg.SessionKeyBytes(), nil),
+		secure: cfg.Secure(),
+	}
+}
+
+// HashToken returns the stored form of a session, trusted device, invite or
+// reset token or a recovery code, so a copy of the database cannot be used
+// to sign in
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Set stores a signed session token that expires at expires
+func (c *Cookies) Set(w http.ResponseWriter, token string, expires time.Time) error {
+	value, err := c.codec.Encode(CookieName, token)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  expires,
+	})
+	return nil
+}
+
+// Clear removes the session cookie
+func (c *Cookies) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.secure,
+		MaxAge:   -1,
+	})
+}
+
+// Token returns the session token carried by the request's cookie
+func (c *Cookies) Token(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", err
+	}
+	var token string
+	if err := c.codec.Decode(CookieName, cookie.Value, &token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ChallengeCookieName holds the user who passed the password check and still
+// owes a second factor
+const ChallengeCookieName = "login_challenge"
+
+// DeviceCookieName holds the token of a remembered device
+const DeviceCookieName = "trusted_device"
+
+// challenge is the signed content of the challenge cookie
+type challenge struct {
+	UserID  int64
+	Expires int64
+}
+
+// SetChallenge records that userID must finish signing in before expires
+func (c *Cookies) SetChallenge(w http.ResponseWriter, userID int64, expires time.Time) error {
+	value, err := c.codec.Encode(ChallengeCookieName, challenge{UserID: userID, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ChallengeCookieName,
+		Value:    value,
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  expires,
+	})
+	return nil
+}
+
+// Challenge returns the user ID from an unexpired challenge cookie
+func (c *Cookies) Challenge(r *http.Request, now time.Time) (int64, error) {
+	cookie, err := r.Cookie(ChallengeCookieName)
+	if err != nil {
+		return 0, err
+	}
+	var ch challenge
+	if err := c.codec.Decode(ChallengeCookieName, cookie.Value, &ch); err != nil {
+		return 0, err
+	}
+	if now.Unix() >= ch.Expires {
+		return 0, http.ErrNoCookie
+	}
+	return ch.UserID, nil
+}
+
+// ClearChallenge removes the challenge cookie
+func (c *Cookies) ClearChallenge(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ChallengeCookieName,
+		Value:    "",
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   c.secure,
+		MaxAge:   -1,
+	})
+}
+
+// SetDevice stores a remembered device token that expires at expires
+func (c *Cookies) SetDevice(w http.ResponseWriter, token string, expires time.Time) error {
+	value, err := c.codec.Encode(DeviceCookieName, token)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     DeviceCookieName,
+		Value:    value,
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  expires,
+	})
+	return nil
+}
+
+// Device returns the remembered device token carried by the request
+func (c *Cookies) Device(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(DeviceCookieName)
+	if err != nil {
+		return "", err
+	}
+	var token string
+	if err := c.codec.Decode(DeviceCookieName, cookie.Value, &token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
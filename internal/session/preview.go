@@ -0,0 +1,56 @@
+// internal/session/preview.go
+package session
+
+import (
+	"time"
+
+	"voidcase/internal/config"
+
+	"github.com/gorilla/securecookie"
+)
+
+// PreviewTTL is how long a project preview link keeps working
+const PreviewTTL = 7 * 24 * time.Hour
+
+// previewName binds preview tokens so other signed values cannot be passed
+// off as one
+const previewName = "preview"
+
+// previewToken is the signed content of a preview link
+type previewToken struct {
+	ProjectID int64
+	Expires   int64
+}
+
+// Previews signs the tokens in preview links, which show a project on the
+// public site before it is published. A token names one project and expires
+// after PreviewTTL.
+type Previews struct {
+	codec *securecookie.SecureCookie
+	// now is the clock used for expiry
+	now func() time.Time
+}
+
+func NewPreviews(cfg *config.Config) *Previews {
+	codec := securecookie.New(cfg.SessionKeyBytes(), nil)
+	// Tokens carry their own expiry, checked against now
+	codec.MaxAge(0)
+	return &Previews{codec: codec, now: time.Now}
+}
+
+// Token returns a signed preview token for projectID
+func (p *Previews) Token(projectID int64) (string, error) {
+	return p.codec.Encode(previewName, previewToken{
+		ProjectID: projectID,
+		Expires:   p.now().Add(PreviewTTL).Unix(),
+	})
+}
+
+// Valid reports whether token is an unexpired preview token for projectID
+func (p *Previews) Valid(token string, projectID int64) bool {
+	var t previewToken
+	if token == "" || p.codec.Decode(previewName, token, &t) != nil {
+		return false
+	}
+	return t.ProjectID == projectID && p.now().Unix() < t.Expires
+}
@@ -0,0 +1,60 @@
+// internal/session/preview_test.go
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"voidcase/internal/config"
+)
+
+func newPreviews(t *testing.T, key string, now time.Time) *Previews {
+	t.Helper()
+	cfg := config.Default()
+	cfg.SessionKey = strings.Repeat(key, 32)
+	p := NewPreviews(cfg)
+	p.now = func() time.Time { return now }
+	return p
+}
+
+func TestPreviewTokens(t *testing.T) {
+	issued := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := newPreviews(t, "cd", issued)
+	token, err := p.Token(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Valid(token, 7) {
+		t.Error("fresh token is not valid for its project")
+	}
+	if p.Valid(token, 8) {
+		t.Error("token is valid for another project")
+	}
+	if p.Valid("", 7) {
+		t.Error("empty token is valid")
+	}
+
+	// Changing any part of the token breaks the signature
+	i := len(token) / 2
+	flipped := byte('A')
+	if token[i] == 'A' {
+		flipped = 'B'
+	}
+	if p.Valid(token[:i]+string(flipped)+token[i+1:], 7) {
+		t.Error("tampered token is valid")
+	}
+	if newPreviews(t, "ef", issued).Valid(token, 7) {
+		t.Error("token signed with another key is valid")
+	}
+
+	p.now = func() time.Time { return issued.Add(PreviewTTL - time.Second) }
+	if !p.Valid(token, 7) {
+		t.Error("token expired before PreviewTTL")
+	}
+	p.now = func() time.Time { return issued.Add(PreviewTTL) }
+	if p.Valid(token, 7) {
+		t.Error("token still valid after PreviewTTL")
+	}
+}
@@ -0,0 +1,16 @@
+// internal/utils/clientip.go
+package utils
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the address of the client making r, without its port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -7,8 +7,8 @@ import (
 )
 
 var (
-	youtubeRegex = regexp.MustCompile(`(?:youtube\.com/watch\?v=|youtu\.be/)([^&\s]+)`)
-	vimeoRegex   = regexp.MustCompile(`vimeo\.com/(\d+)`)
+	youtubeRegex = regexp.MustCompile(`(?:youtube\.com/watch\?v=|youtube\.com/embed/|youtu\.be/)([\w-]+)`)
+	vimeoRegex   = regexp.MustCompile(`vimeo\.com/(?:video/)?(\d+)`)
 )
 
 func SanitizeVideoEmbed(input string) string {
@@ -0,0 +1,31 @@
+// internal/utils/slug.go
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify converts a title into a lowercase, hyphen-separated URL segment
+func Slugify(input string) string {
+	var b strings.Builder
+	pendingDash := false
+	for _, r := range strings.ToLower(input) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			b.WriteRune(r)
+		default:
+			pendingDash = true
+		}
+	}
+
+	slug := b.String()
+	if slug == "" {
+		return "project"
+	}
+	return slug
+}
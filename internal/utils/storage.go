@@ -5,9 +5,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"os"
+	"path"
 	"path/filepath"
 )
 
+// Storage is a content-addressed file store. Every hash gets its own
+// directory, sharded by the first two byte pairs of the hash, holding any
+// number of named files derived from that content.
 type Storage struct {
 	baseDir string
 }
@@ -16,19 +20,39 @@ func NewStorage(baseDir string) *Storage {
 	return &Storage{baseDir: baseDir}
 }
 
-func (s *Storage) Save(data []byte) (string, error) {
+// ContentHash returns the hex SHA-256 digest used to key stored content
+func ContentHash(data []byte) string {
 	hash := sha256.Sum256(data)
-	hashStr := hex.EncodeToString(hash[:])
+	return hex.EncodeToString(hash[:])
+}
+
+// RelPath returns the slash-separated location of a named file for hash,
+// relative to the storage base directory
+func (s *Storage) RelPath(hash, name string) string {
+	return path.Join(hash[:2], hash[2:4], hash, name)
+}
+
+func (s *Storage) dir(hash string) string {
+	return filepath.Join(s.baseDir, hash[:2], hash[2:4], hash)
+}
 
-	path := filepath.Join(s.baseDir, hashStr[:2], hashStr[2:4], hashStr)
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+// Put writes a named file for hash and returns its relative path
+func (s *Storage) Put(hash, name string, data []byte) (string, error) {
+	dir := s.dir(hash)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
 		return "", err
 	}
+	return s.RelPath(hash, name), nil
+}
 
-	return hashStr, os.WriteFile(path, data, 0644)
+func (s *Storage) Get(hash, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir(hash), name))
 }
 
-func (s *Storage) Get(hash string) ([]byte, error) {
-	path := filepath.Join(s.baseDir, hash[:2], hash[2:4], hash)
-	return os.ReadFile(path)
+// Remove deletes every file stored for hash
+func (s *Storage) Remove(hash string) error {
+	return os.RemoveAll(s.dir(hash))
 }
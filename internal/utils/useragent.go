@@ -0,0 +1,70 @@
+// internal/utils/useragent.go
+package utils
+
+import "strings"
+
+// Tokens are checked in order because most browsers also claim to be the
+// engines they derive from (Edge sends "Chrome", Chrome sends "Safari")
+var browserTokens = []struct{ token, name string }{
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios", "Firefox"},
+	{"crios", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium", "Chrome"},
+	{"safari/", "Safari"},
+	{"msie", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+var platformTokens = []struct{ token, name string }{
+	{"android", "Android"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"windows", "Windows"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-", "go-http-client"}
+
+// ParseUserAgent reduces a User-Agent header to a browser and platform name.
+// Unrecognised values are reported as "Other".
+func ParseUserAgent(ua string) (browser, platform string) {
+	ua = strings.ToLower(ua)
+	browser, platform = "Other", "Other"
+
+	for _, b := range browserTokens {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platformTokens {
+		if strings.Contains(ua, p.token) {
+			platform = p.name
+			break
+		}
+	}
+	return browser, platform
+}
+
+// IsBot reports whether a User-Agent belongs to a crawler or script
+func IsBot(ua string) bool {
+	if ua == "" {
+		return true
+	}
+	ua = strings.ToLower(ua)
+	for _, token := range botTokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
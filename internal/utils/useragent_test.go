@@ -0,0 +1,50 @@
+// internal/utils/useragent_test.go
+package utils
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	for _, tc := range []struct {
+		ua, browser, platform string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			"Chrome", "Windows"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			"Edge", "Windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			"Safari", "macOS"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			"Chrome", "iOS"},
+		{"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			"Samsung Internet", "Android"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			"Firefox", "Linux"},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 OPR/109.0.0.0",
+			"Opera", "ChromeOS"},
+		{"Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko",
+			"Internet Explorer", "Windows"},
+		{"", "Other", "Other"},
+		{"SomethingNew/1.0", "Other", "Other"},
+	} {
+		browser, platform := ParseUserAgent(tc.ua)
+		if browser != tc.browser || platform != tc.platform {
+			t.Errorf("ParseUserAgent(%q) = %s, %s; want %s, %s",
+				tc.ua, browser, platform, tc.browser, tc.platform)
+		}
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	for ua, want := range map[string]bool{
+		"": true,
+		"Googlebot/2.1 (+http://www.google.com/bot.html)": true,
+		"curl/8.5.0":             true,
+		"python-requests/2.31.0": true,
+		"Go-http-client/1.1":     true,
+		"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0": false,
+	} {
+		if got := IsBot(ua); got != want {
+			t.Errorf("IsBot(%q) = %v; want %v", ua, got, want)
+		}
+	}
+}